@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"go_lang/parsing/http_cache"
+)
+
+// runClearCache empties the HTTP cache of -cache, or only drops the answers
+// that are too old to be served any more.
+func runClearCache(args []string) error {
+	fs := newFlagSet("clear-cache", "")
+	dir := fs.String("cache-dir", http_cache.DefaultDir, "directory of the crawl -cache")
+	olderThan := fs.Duration("older-than", 0, `only remove answers cached longer ago than this, e.g. "24h"`)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return usageErrorf("-older-than can't be negative")
+	}
+	removed, err := http_cache.Clear(*dir, *olderThan)
+	if err != nil {
+		return fmt.Errorf("clear cache: %w", err)
+	}
+	fmt.Printf("Removed %d cached answers from %s\n", removed, *dir)
+	return nil
+}
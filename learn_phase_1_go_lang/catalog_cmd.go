@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/output_sink"
+	"path/filepath"
+	"strings"
+)
+
+// runCatalog builds ingredients.json from a finished crawl and writes the
+// meal details back with the catalog ID of every ingredient.
+func runCatalog(args []string) error {
+	fs := newFlagSet("catalog", "")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l]), ingredients.json is written there too")
+	aliasPath := fs.String("aliases", "", "alias file mapping a spelling to its ingredient (default: ingredient_aliases.json in -from)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *aliasPath == "" {
+		*aliasPath = filepath.Join(*from, "ingredient_aliases.json")
+	}
+
+	detailsPath := findCrawlFile(*from, "final_items")
+	details, err := detail_parse.LoadFinalMeals(detailsPath)
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+	aliases, err := ingredient_catalog.LoadAliases(*aliasPath)
+	if err != nil {
+		return fmt.Errorf("load aliases: %w", err)
+	}
+	catalog := ingredient_catalog.Build(details, aliases)
+	catalogPath := filepath.Join(*from, "ingredients.json")
+	if err := catalog.Save(catalogPath); err != nil {
+		return fmt.Errorf("write catalog: %w", err)
+	}
+	if missing := catalog.Apply(details); missing > 0 {
+		fmt.Printf("%d ingredients have no name to file them under\n", missing)
+	}
+
+	format := "json"
+	if strings.HasSuffix(detailsPath, ".jsonl") {
+		format = "jsonl"
+	}
+	sink, err := output_sink.OpenDetails(output_sink.Config{Format: format, Dir: *from})
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	for _, detail := range details {
+		if err := sink.WriteDetail(detail); err != nil {
+			sink.Close()
+			return fmt.Errorf("write meal details: %w", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("write meal details: %w", err)
+	}
+	fmt.Printf("Filed the ingredients of %d meals under %d canonical ingredients (%s)\n", len(details), len(catalog.Entries), catalogPath)
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/pantry"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runCook ranks the meals of a finished crawl by how much of them can be
+// cooked from the ingredients at hand.
+func runCook(args []string) error {
+	fs := newFlagSet("cook", "")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l])")
+	have := fs.String("have", "", "comma separated ingredients at hand, e.g. \"butter,eggs,plain flour\"")
+	pantryPath := fs.String("pantry", "", "file listing the ingredients at hand, one per line")
+	subsPath := fs.String("substitutions", "", "JSON file mapping an ingredient to the ones that can replace it (default: substitutions.json in -from)")
+	staples := fs.String("staples", strings.Join(pantry.DefaultStaples, ","), "comma separated ingredients assumed at hand and not counted")
+	limit := fs.Int("limit", 10, "most meals to print, 0 for all")
+	maxMissing := fs.Int("max-missing", -1, "leave out meals missing more ingredients than this, -1 for no limit")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *subsPath == "" {
+		// The default file may be missing, one given with -substitutions may not
+		if path := filepath.Join(*from, "substitutions.json"); !missing(path) {
+			*subsPath = path
+		}
+	}
+
+	items := splitList(*have)
+	if *pantryPath != "" {
+		listed, err := pantry.LoadList(*pantryPath)
+		if err != nil {
+			return fmt.Errorf("load pantry: %w", err)
+		}
+		items = append(items, listed...)
+	}
+	if len(items) == 0 {
+		return usageErrorf("say what you have with -have or -pantry")
+	}
+	subs, err := pantry.LoadSubstitutions(*subsPath)
+	if err != nil {
+		return fmt.Errorf("load substitutions: %w", err)
+	}
+	var catalog *ingredient_catalog.Catalog
+	if c, err := ingredient_catalog.Load(filepath.Join(*from, "ingredients.json")); err == nil {
+		catalog = c
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load ingredient catalog: %w", err)
+	}
+	meals, err := detail_parse.LoadFinalMeals(findCrawlFile(*from, "final_items"))
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+
+	matcher := pantry.New(items, pantry.Options{Substitutions: subs, Staples: splitList(*staples), Catalog: catalog})
+	printed := 0
+	for _, m := range matcher.Rank(meals) {
+		if *maxMissing >= 0 && len(m.Missing) > *maxMissing {
+			continue
+		}
+		if *limit > 0 && printed == *limit {
+			break
+		}
+		printed++
+		fmt.Printf("%2d. %s %.0f%% (%d of %d)\n", printed, m.Meal.DisplayName(), m.Coverage()*100,
+			len(m.Have)+len(m.Substituted), m.Required)
+		for _, s := range m.Substituted {
+			fmt.Printf("    using %s for %s\n", s.Use, s.Need)
+		}
+		if len(m.Missing) > 0 {
+			fmt.Printf("    missing: %s\n", strings.Join(m.Missing, ", "))
+		}
+	}
+	if printed == 0 {
+		fmt.Println("No meals use what you have")
+	}
+	return nil
+}
+
+// splitList splits a comma separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// missing reports whether there is no file at path. Other errors are left
+// for reading the file to report.
+func missing(path string) bool {
+	_, err := os.Stat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/recipe/cookbook"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runCookbook exports meals of a finished crawl as an EPUB book or a folder
+// of Markdown notes.
+func runCookbook(args []string) error {
+	fs := newFlagSet("cookbook", " [meal name or ID ...]")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l]) and its mirrored images")
+	format := fs.String("format", "epub", "export format: epub or markdown")
+	out := fs.String("out", "", "file of the book, or directory of the notes (default: cookbook.epub or markdown/ in -from)")
+	title := fs.String("title", "Cookbook", "title of the book")
+	flag := fs.String("flag", "", "only meals with this country flag, e.g. gb")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "epub" && *format != "markdown" {
+		return usageErrorf("unknown format %q (want epub or markdown)", *format)
+	}
+
+	path := findCrawlFile(*from, "final_items")
+	meals, err := detail_parse.LoadFinalMeals(path)
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+	if fs.NArg() > 0 {
+		var picked []detail_parse.MealDetail
+		for _, name := range fs.Args() {
+			meal, ok := detail_parse.FindMeal(meals, name)
+			if !ok {
+				return fmt.Errorf("no meal named %q in %s", name, path)
+			}
+			picked = append(picked, meal)
+		}
+		meals = picked
+	}
+	if *flag != "" {
+		var kept []detail_parse.MealDetail
+		for _, meal := range meals {
+			if strings.EqualFold(meal.Flag, *flag) {
+				kept = append(kept, meal)
+			}
+		}
+		meals = kept
+	}
+	if len(meals) == 0 {
+		return fmt.Errorf("no meals to export")
+	}
+
+	opts := cookbook.Options{Title: *title, From: *from}
+	var res cookbook.Result
+	if *format == "markdown" {
+		if *out == "" {
+			*out = filepath.Join(*from, "markdown")
+		}
+		if res, err = cookbook.WriteMarkdown(*out, meals, opts); err != nil {
+			return fmt.Errorf("write markdown: %w", err)
+		}
+		fmt.Printf("Wrote %d meals to %s\n", res.Meals, *out)
+		return nil
+	}
+
+	if *out == "" {
+		*out = filepath.Join(*from, "cookbook.epub")
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	res, err = cookbook.WriteEPUB(file, meals, opts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write epub: %w", err)
+	}
+	fmt.Printf("Wrote %d meals and %d images to %s\n", res.Meals, res.Images, *out)
+	if res.MissingImages > 0 {
+		fmt.Printf("%d images were not mirrored and are left out, crawl with -images to keep them\n", res.MissingImages)
+	}
+	return nil
+}
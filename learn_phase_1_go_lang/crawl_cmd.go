@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go_lang/parsing/crawl_diff"
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/failures"
+	"go_lang/parsing/http_cache"
+	"go_lang/parsing/image_mirror"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/output_sink"
+	"go_lang/parsing/replay"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// outputFlags pick where and in which format results are written.
+type outputFlags struct {
+	sink output_sink.Config
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	f := &outputFlags{}
+	fs.StringVar(&f.sink.Dir, "out", "output", "output directory")
+	fs.StringVar(&f.sink.Format, "format", output_sink.Formats[0], "output format: "+strings.Join(output_sink.Formats, ", "))
+	fs.StringVar(&f.sink.DSN, "pg-dsn", "", "PostgreSQL connection string for -format postgres")
+	return f
+}
+
+// crawlFlags are shared by every command that fetches pages.
+type crawlFlags struct {
+	pool        crawl_pool.Config
+	mode        string
+	fixtures    string
+	site        string
+	verbose     bool
+	quiet       bool
+	logFormat   string
+	metrics     string
+	maxDuration time.Duration
+
+	ignoreRobots bool
+	cache        bool
+}
+
+func addCrawlFlags(fs *flag.FlagSet) *crawlFlags {
+	f := &crawlFlags{pool: crawl_pool.DefaultConfig()}
+	fs.IntVar(&f.pool.Parallelism, "parallelism", f.pool.Parallelism, "number of pages fetched at once")
+	fs.DurationVar(&f.pool.Delay, "delay", f.pool.Delay, "wait between two requests to the same host")
+	fs.DurationVar(&f.pool.RandomDelay, "random-delay", f.pool.RandomDelay, "extra random jitter added to -delay")
+	fs.IntVar(&f.pool.Retries, "retries", f.pool.Retries, "extra attempts for timeouts, 5xx and 429 answers")
+	fs.DurationVar(&f.pool.RetryBaseDelay, "retry-delay", f.pool.RetryBaseDelay, "wait before the first retry, doubled for every next one")
+	fs.DurationVar(&f.pool.RetryMaxDelay, "retry-max-delay", f.pool.RetryMaxDelay, "longest wait between two attempts, also caps Retry-After")
+	fs.StringVar(&f.mode, "replay-mode", string(replay.Live), "live, record (save every page under -fixtures) or replay (read pages from -fixtures, no network)")
+	fs.StringVar(&f.fixtures, "fixtures", "testdata/fixtures", "directory of recorded pages for -replay-mode")
+	fs.StringVar(&f.site, "site", extractor.Names()[0], "how to read the site: "+strings.Join(extractor.Names(), ", "))
+	fs.BoolVar(&f.verbose, "v", false, "verbose: also log every request")
+	fs.BoolVar(&f.quiet, "q", false, "quiet: only print errors")
+	fs.StringVar(&f.logFormat, "log-format", "text", "format of the progress log: text or json")
+	fs.DurationVar(&f.pool.ProgressInterval, "progress", f.pool.ProgressInterval, "how often to log the progress of a stage, 0 to only log its end")
+	fs.StringVar(&f.metrics, "metrics-addr", "", `serve the crawl counters on http://<addr>/metrics while it runs, e.g. "localhost:9090"`)
+	fs.DurationVar(&f.maxDuration, "max-duration", 0, "stop the crawl after this long and keep what was fetched, 0 for no limit")
+	fs.IntVar(&f.pool.MaxPages, "max-pages", 0, "stop the crawl after fetching this many list and meal pages, 0 for no limit")
+	fs.StringVar(&f.pool.UserAgent, "user-agent", f.pool.UserAgent, "User-Agent sent with every request")
+	fs.StringVar(&f.pool.Contact, "contact", "", `email address or URL added to the User-Agent, e.g. "mailto:you@example.com"`)
+	fs.BoolVar(&f.ignoreRobots, "ignore-robots", false, "don't honour robots.txt and its Crawl-delay (never read with -replay-mode replay)")
+	fs.BoolVar(&f.cache, "cache", false, "keep the answers in -cache-dir and serve them again while fresh, for development runs")
+	fs.StringVar(&f.pool.CacheDir, "cache-dir", http_cache.DefaultDir, "directory of the -cache")
+	fs.DurationVar(&f.pool.CacheTTL, "cache-ttl", f.pool.CacheTTL, "how long a cached answer is served, 0 for ever")
+	return f
+}
+
+func (f *crawlFlags) newPool() (*crawl_pool.Pool, error) {
+	cfg := f.pool
+	switch {
+	case f.verbose && f.quiet:
+		return nil, usageErrorf("-v and -q can't be used together")
+	case f.verbose:
+		cfg.Verbosity = crawl_pool.Verbose
+	case f.quiet:
+		cfg.Verbosity = crawl_pool.Quiet
+	}
+	opts := &slog.HandlerOptions{Level: crawl_pool.Level(cfg.Verbosity)}
+	switch f.logFormat {
+	case "text":
+		cfg.Logger = slog.New(slog.NewTextHandler(os.Stdout, opts))
+	case "json":
+		cfg.Logger = slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	default:
+		return nil, usageErrorf("unknown log format %q (want text or json)", f.logFormat)
+	}
+	mode, err := replay.ParseMode(f.mode)
+	if err != nil {
+		return nil, usageErrorf("%v", err)
+	}
+	if mode != replay.Live {
+		cfg.Transport = &replay.Transport{Mode: mode, Dir: f.fixtures}
+	}
+	// Replayed pages never reach the site, and fixtures have no robots.txt
+	cfg.Robots = !f.ignoreRobots && mode != replay.Replay
+	if !f.cache {
+		cfg.CacheDir = ""
+	}
+	return crawl_pool.New(cfg)
+}
+
+// partialFile marks an output directory whose crawl was stopped before it
+// was done.
+const partialFile = "partial.json"
+
+// partialMarker is what partial.json holds.
+type partialMarker struct {
+	Reason    string    `json:"reason"`
+	StoppedAt time.Time `json:"stopped_at"`
+}
+
+// startRun serves /metrics on -metrics-addr while the pool crawls. The
+// returned ctx is cancelled by the first SIGINT or SIGTERM, a second one
+// kills the process, and once -max-duration is over.
+//
+// The returned finish stops all that and writes the totals of the run to
+// run.json in dir. When crawlErr says the crawl was stopped, see
+// crawl_pool.ErrStopped, it also writes partial.json next to the output;
+// a crawl that ran to its end removes it.
+func (f *crawlFlags) startRun(pool *crawl_pool.Pool, dir string) (ctx context.Context, finish func(crawlErr error) error, err error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	pool.SetContext(ctx)
+	stopTimer := func() bool { return false }
+	if f.maxDuration > 0 {
+		timer := time.AfterFunc(f.maxDuration, func() {
+			cancel(fmt.Errorf("-max-duration %s reached", f.maxDuration))
+		})
+		stopTimer = timer.Stop
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		// The next one gets the default handling and ends the process
+		signal.Stop(signals)
+		name := "SIGTERM"
+		if sig == os.Interrupt {
+			name = "SIGINT"
+		}
+		pool.Log().Warn("stopping, waiting for the pages being fetched; press Ctrl-C again to quit at once", "signal", name)
+		cancel(fmt.Errorf("interrupted by %s", name))
+	}()
+	stop := func() {
+		stopTimer()
+		signal.Stop(signals)
+		close(signals)
+	}
+
+	var server *http.Server
+	if f.metrics != "" {
+		ln, err := net.Listen("tcp", f.metrics)
+		if err != nil {
+			stop()
+			cancel(nil)
+			return nil, nil, fmt.Errorf("serve metrics: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", pool.Stats())
+		server = &http.Server{Handler: mux}
+		go server.Serve(ln)
+		pool.Log().Info("serving metrics", "url", "http://"+ln.Addr().String()+"/metrics")
+	}
+	return ctx, func(crawlErr error) error {
+		stop()
+		defer cancel(nil)
+		if server != nil {
+			server.Close()
+		}
+		report := pool.Stats().Report()
+		var markErr error
+		if errors.Is(crawlErr, crawl_pool.ErrStopped) {
+			report.Stopped = "stopped"
+			if err := pool.Stopped(ctx); err != nil {
+				report.Stopped = strings.TrimPrefix(err.Error(), crawl_pool.ErrStopped.Error()+": ")
+			}
+			markErr = writePartial(filepath.Join(dir, partialFile), partialMarker{Reason: report.Stopped, StoppedAt: report.FinishedAt})
+			fmt.Printf("The crawl is partial, %s; run the same command again to resume\n", report.Stopped)
+		} else if err := os.Remove(filepath.Join(dir, partialFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			markErr = err
+		}
+		if markErr != nil {
+			markErr = fmt.Errorf("write partial marker: %w", markErr)
+		}
+		if err := report.Save(filepath.Join(dir, "run.json")); err != nil {
+			return errors.Join(markErr, fmt.Errorf("write run report: %w", err))
+		}
+		return markErr
+	}, nil
+}
+
+func writePartial(path string, marker partialMarker) error {
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (f *crawlFlags) extractor() (extractor.Extractor, error) {
+	ex, err := extractor.ByName(f.site)
+	if err != nil {
+		return nil, usageErrorf("%v", err)
+	}
+	return ex, nil
+}
+
+// addImagesFlag adds the switch of the image mirror of the detail crawl.
+func addImagesFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("images", false, "also download ingredient images, flags and thumbnails into <out>/images")
+}
+
+// historyFlag keeps a snapshot of every complete detail crawl, for diff.
+type historyFlag struct {
+	dir string
+}
+
+func addHistoryFlag(fs *flag.FlagSet) *historyFlag {
+	h := &historyFlag{}
+	fs.StringVar(&h.dir, "history", "", "after a complete crawl, keep a copy of final_items.json in this directory, for diff")
+	return h
+}
+
+func (h *historyFlag) check(cfg output_sink.Config) error {
+	if h.dir != "" && cfg.Format != "json" && cfg.Format != "jsonl" {
+		return usageErrorf("-history needs -format json or jsonl")
+	}
+	return nil
+}
+
+// save snapshots the crawl in cfg. Crawls with failed pages are not kept,
+// their missing meals would show as removed.
+func (h *historyFlag) save(cfg output_sink.Config, crawlErr error) error {
+	if h.dir == "" || crawlErr != nil {
+		return nil
+	}
+	path, err := crawl_diff.Snapshot(h.dir, findCrawlFile(cfg.Dir, "final_items"), time.Now())
+	if err != nil {
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	fmt.Println("Saved snapshot", path)
+	return nil
+}
+
+// addListFlags adds the flags of the letter page crawl.
+func addListFlags(fs *flag.FlagSet) (*main_parse.ListOptions, *string) {
+	opts := &main_parse.ListOptions{}
+	fs.StringVar(&opts.BaseURL, "base-url", main_parse.DefaultBaseURL, "site to crawl, the page listing the recipes for -site schema.org")
+	letters := fs.String("letters", "a-z", `letter pages to crawl, e.g. "a-c,x" (themealdb only)`)
+	fs.BoolVar(&opts.Sitemap, "sitemap", false, "find the meals in the sitemaps of the site instead of its list pages")
+	return opts, letters
+}
+
+func runCrawl(args []string) error {
+	fs := newFlagSet("crawl", "")
+	out := addOutputFlags(fs)
+	crawl := addCrawlFlags(fs)
+	list, letters := addListFlags(fs)
+	var opts detail_parse.Options
+	fs.BoolVar(&opts.Incremental, "incremental", false, "only fetch new meals and revalidate known ones")
+	images := addImagesFlag(fs)
+	history := addHistoryFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := history.check(out.sink); err != nil {
+		return err
+	}
+	var err error
+	if list.Letters, err = main_parse.ParseLetters(*letters); err != nil {
+		return usageErrorf("%v", err)
+	}
+	pool, err := crawl.newPool()
+	if err != nil {
+		return err
+	}
+	if list.Extractor, err = crawl.extractor(); err != nil {
+		return err
+	}
+	manifest, err := openFailures(out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	manifest.Clear(failures.List)
+	manifest.Clear(failures.Details)
+	list.Failures = manifest
+	ctx, finish, err := crawl.startRun(pool, out.sink.Dir)
+	if err != nil {
+		return err
+	}
+
+	meals, listErr := crawlList(ctx, pool, out.sink, *list)
+	// A stopped list would crawl the details of a part of the meals only
+	if len(meals) == 0 || errors.Is(listErr, crawl_pool.ErrStopped) {
+		return errors.Join(listErr, saveFailures(manifest), finish(listErr))
+	}
+	opts.Dir = out.sink.Dir
+	opts.Failures = manifest
+	opts.Extractor = list.Extractor
+	detailErr := crawlDetails(ctx, pool, out.sink, meals, opts, *images)
+	crawlErr := errors.Join(listErr, detailErr)
+	return errors.Join(crawlErr, saveFailures(manifest), history.save(out.sink, crawlErr), finish(crawlErr))
+}
+
+func runList(args []string) error {
+	fs := newFlagSet("list", "")
+	out := addOutputFlags(fs)
+	crawl := addCrawlFlags(fs)
+	list, letters := addListFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var err error
+	if list.Letters, err = main_parse.ParseLetters(*letters); err != nil {
+		return usageErrorf("%v", err)
+	}
+	pool, err := crawl.newPool()
+	if err != nil {
+		return err
+	}
+	if list.Extractor, err = crawl.extractor(); err != nil {
+		return err
+	}
+	manifest, err := openFailures(out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	manifest.Clear(failures.List)
+	list.Failures = manifest
+	ctx, finish, err := crawl.startRun(pool, out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	_, err = crawlList(ctx, pool, out.sink, *list)
+	return errors.Join(err, saveFailures(manifest), finish(err))
+}
+
+func runDetails(args []string) error {
+	fs := newFlagSet("details", "")
+	out := addOutputFlags(fs)
+	crawl := addCrawlFlags(fs)
+	input := fs.String("input", "", "meal list to crawl, items.json or items.jsonl (default: the one in -out)")
+	var opts detail_parse.Options
+	fs.BoolVar(&opts.Incremental, "incremental", false, "only fetch new meals and revalidate known ones")
+	images := addImagesFlag(fs)
+	history := addHistoryFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := history.check(out.sink); err != nil {
+		return err
+	}
+	if *input == "" {
+		*input = mealListPath(out.sink.Dir, out.sink.Format)
+	}
+	meals, err := main_parse.LoadMeals(*input)
+	if err != nil {
+		return fmt.Errorf("load meal list: %w", err)
+	}
+	pool, err := crawl.newPool()
+	if err != nil {
+		return err
+	}
+	if opts.Extractor, err = crawl.extractor(); err != nil {
+		return err
+	}
+	manifest, err := openFailures(out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	manifest.Clear(failures.Details)
+	opts.Dir = out.sink.Dir
+	opts.Failures = manifest
+	ctx, finish, err := crawl.startRun(pool, out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	detailErr := crawlDetails(ctx, pool, out.sink, meals, opts, *images)
+	return errors.Join(detailErr, saveFailures(manifest), history.save(out.sink, detailErr), finish(detailErr))
+}
+
+// openFailures loads failures.json of the output directory. The crawling
+// commands clear the stages they run in full and save it when done.
+func openFailures(dir string) (*failures.Manifest, error) {
+	manifest, err := failures.Open(filepath.Join(dir, "failures.json"))
+	if err != nil {
+		return nil, fmt.Errorf("load failures: %w", err)
+	}
+	return manifest, nil
+}
+
+func saveFailures(manifest *failures.Manifest) error {
+	if err := manifest.Save(); err != nil {
+		return fmt.Errorf("write failures: %w", err)
+	}
+	if n := len(manifest.Failures("")); n > 0 {
+		fmt.Printf("%d pages failed, they are listed in %s for retry-failures\n", n, manifest.Path())
+	}
+	return nil
+}
+
+// mealListPath is where the list phase left its meal list. Formats that can't
+// be read back fall back to items.json.
+func mealListPath(dir, format string) string {
+	if format == "jsonl" {
+		return filepath.Join(dir, "items.jsonl")
+	}
+	return filepath.Join(dir, "items.json")
+}
+
+func crawlList(ctx context.Context, pool *crawl_pool.Pool, cfg output_sink.Config, opts main_parse.ListOptions) ([]main_parse.Meal, error) {
+	sink, err := output_sink.OpenMeals(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open output: %w", err)
+	}
+	return main_parse.ThumnailPageParse(ctx, pool, sink, opts)
+}
+
+// crawlDetails crawls the meal pages into cfg. With images set the pictures
+// are mirrored into <out>/images as well.
+func crawlDetails(ctx context.Context, pool *crawl_pool.Pool, cfg output_sink.Config, meals []main_parse.Meal, opts detail_parse.Options, images bool) error {
+	if images {
+		store, err := image_mirror.Open(pool, filepath.Join(cfg.Dir, "images"))
+		if err != nil {
+			return fmt.Errorf("open image store: %w", err)
+		}
+		opts.Images = store
+		defer func() {
+			if err := store.Save(); err != nil {
+				pool.Log().Error("writing image index", "err", err)
+			}
+		}()
+	}
+	sink, err := output_sink.OpenDetails(cfg)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	return detail_parse.DetailParse(ctx, pool, meals, sink, opts)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"go_lang/parsing/crawl_diff"
+	"go_lang/parsing/detail_parse"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// runDiff compares two finished crawls: what meals were added or removed,
+// and what changed in the others.
+func runDiff(args []string) error {
+	fs := newFlagSet("diff", " [old new]")
+	history := fs.String("history", "", "directory of crawl snapshots (crawl -history); without arguments the two latest are compared")
+	format := fs.String("format", "text", "report format: "+strings.Join(crawl_diff.Formats, ", "))
+	out := fs.String("out", "", "file to write the report to instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !slices.Contains(crawl_diff.Formats, *format) {
+		return usageErrorf("unknown format %q", *format)
+	}
+
+	var oldPath, newPath string
+	switch {
+	case fs.NArg() == 2:
+		oldPath, newPath = diffPath(*history, fs.Arg(0)), diffPath(*history, fs.Arg(1))
+	case fs.NArg() == 0 && *history != "":
+		snapshots, err := crawl_diff.Snapshots(*history)
+		if err != nil {
+			return fmt.Errorf("list snapshots: %w", err)
+		}
+		if len(snapshots) < 2 {
+			return fmt.Errorf("%s has %d snapshots, two are needed", *history, len(snapshots))
+		}
+		oldPath, newPath = snapshots[len(snapshots)-2], snapshots[len(snapshots)-1]
+	default:
+		return usageErrorf("give two crawls to compare, or -history")
+	}
+
+	before, err := detail_parse.LoadFinalMeals(oldPath)
+	if err != nil {
+		return fmt.Errorf("load old crawl: %w", err)
+	}
+	after, err := detail_parse.LoadFinalMeals(newPath)
+	if err != nil {
+		return fmt.Errorf("load new crawl: %w", err)
+	}
+	report := crawl_diff.Compare(before, after)
+	report.Old.Path, report.New.Path = oldPath, newPath
+
+	if *out == "" {
+		return report.WriteFormat(os.Stdout, *format)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteFormat(file, *format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// diffPath finds the crawl file arg names: a snapshot of history by its
+// name, with or without extension, a crawl output directory or the file
+// itself.
+func diffPath(history, arg string) string {
+	if history != "" && !strings.ContainsRune(arg, filepath.Separator) {
+		for _, name := range []string{arg, arg + ".json", arg + ".jsonl"} {
+			path := filepath.Join(history, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return findCrawlFile(arg, "final_items")
+	}
+	return arg
+}
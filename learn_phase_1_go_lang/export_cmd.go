@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/output_sink"
+	"os"
+	"path/filepath"
+)
+
+// runExport reads a finished crawl written as json or jsonl and writes it
+// again through another sink, so a crawl never has to be repeated just to
+// get a different format.
+func runExport(args []string) error {
+	fs := newFlagSet("export", "")
+	from := fs.String("from", "output", "directory of the crawl to export (items.json[l] and final_items.json[l])")
+	out := addOutputFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if out.sink.Format == "json" && filepath.Clean(out.sink.Dir) == filepath.Clean(*from) {
+		return usageErrorf("exporting %s to json would only rewrite it, pick another -format or -out", *from)
+	}
+
+	meals, err := main_parse.LoadMeals(findCrawlFile(*from, "items"))
+	if err != nil {
+		return fmt.Errorf("load meal list: %w", err)
+	}
+	details, err := detail_parse.LoadFinalMeals(findCrawlFile(*from, "final_items"))
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+
+	if err := writeMeals(out.sink, meals); err != nil {
+		return err
+	}
+
+	sink, err := output_sink.OpenDetails(out.sink)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	for _, detail := range details {
+		if err := sink.WriteDetail(detail); err != nil {
+			sink.Close()
+			return fmt.Errorf("write meal details: %w", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("write meal details: %w", err)
+	}
+	fmt.Printf("Exported %d meals and %d meal details as %s\n", len(meals), len(details), out.sink.Format)
+	return nil
+}
+
+// findCrawlFile returns dir/name.json, or dir/name.jsonl when only that one
+// exists.
+func findCrawlFile(dir, name string) string {
+	path := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(path + "l"); err == nil {
+			return path + "l"
+		}
+	}
+	return path
+}
@@ -1,11 +1,119 @@
 package main
 
 import (
-	"go_lang/parsing/main_parse"
-	"go_lang/parsing/detail_parse"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
 )
 
+const usage = `Usage: go run . [command] [flags]
+
+Commands:
+  crawl    crawl the meal list and every meal page (default)
+  list     crawl the meal list only
+  details  crawl the meal pages of a meal list crawled before
+  export   write a finished crawl in another output format
+  retry-failures
+           fetch again only the pages listed in failures.json
+  clear-cache
+           empty the HTTP cache of crawl -cache
+  stats    print numbers about a finished crawl
+  diff     compare two finished crawls
+  catalog  build the canonical ingredient catalog of a finished crawl
+  search   find meals of a finished crawl by words, ingredients or area
+  cook     rank the meals of a finished crawl by the ingredients at hand
+  shop     merge the ingredients of several meals into one shopping list
+  build-site
+           render a finished crawl into a static HTML cookbook
+  cookbook export meals as an EPUB book or Markdown notes
+  recipe   print one meal, scaled and converted
+  quiz     make quiz questions for the quiz server out of a finished crawl
+
+Run "go run . <command> -h" to see the flags of a command.
+`
+
+// errUsage marks a bad command line. It exits with 2 instead of 1.
+var errUsage = errors.New("invalid usage")
+
+func usageErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+var commands = map[string]func(args []string) error{
+	"crawl":    runCrawl,
+	"list":     runList,
+	"details":  runDetails,
+	"export":   runExport,
+	"stats":    runStats,
+	"diff":     runDiff,
+	"catalog":  runCatalog,
+	"search":   runSearch,
+	"cook":     runCook,
+	"shop":     runShop,
+	"recipe":   runRecipe,
+	"cookbook": runCookbook,
+	"quiz":     runQuiz,
+
+	"retry-failures": runRetryFailures,
+	"clear-cache":    runClearCache,
+	"build-site":     runBuildSite,
+}
+
 func main() {
-	main_parse.ThumnailPageParse()
-	detail_parse.DetailParse()
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command in args and returns the exit code: 0 on success,
+// 1 when the command failed and 2 for a bad command line.
+func run(args []string) int {
+	name := "crawl"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+	err := cmd(args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		return 2
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	return 1
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting, so
+// run decides the exit code.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go run . %s [flags]%s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs. The flag package already printed what
+// was wrong, so a failure only needs to set the exit code.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	return nil
 }
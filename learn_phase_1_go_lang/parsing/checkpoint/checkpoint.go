@@ -0,0 +1,129 @@
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// record is one line of the journal file.
+type record[T any] struct {
+	Key   string `json:"key"`
+	Value T      `json:"value"`
+}
+
+// Journal is an append-only JSONL file that remembers which jobs already
+// finished, so a crawl that died halfway can pick up where it stopped.
+// It is safe to use from several pool workers at once.
+//
+// Only values loaded from disk are kept in memory; appended values go
+// straight to the file, so a long crawl doesn't pile up in the journal.
+type Journal[T any] struct {
+	path     string
+	mu       sync.Mutex
+	file     *os.File
+	done     map[string]T
+	appended map[string]bool
+}
+
+// Load reads every complete line of the journal at path without opening it
+// for writing. A missing file gives an empty map.
+func Load[T any](path string) (map[string]T, error) {
+	done, _, err := load[T](path)
+	return done, err
+}
+
+// load also returns the size of the complete lines, so a torn tail can be
+// cut off.
+func load[T any](path string) (map[string]T, int, error) {
+	done := make(map[string]T)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	for _, line := range bytes.Split(data[:complete], []byte("\n")) {
+		var r record[T]
+		if json.Unmarshal(line, &r) != nil {
+			continue
+		}
+		done[r.Key] = r.Value
+	}
+	return done, complete, nil
+}
+
+// Open loads every complete line of the journal at path and opens it for
+// appending. A half-written last line from a crash is cut off, so the next
+// append starts on a clean line.
+func Open[T any](path string) (*Journal[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	done, complete, err := load[T](path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > int64(complete) {
+		if err := os.Truncate(path, int64(complete)); err != nil {
+			return nil, err
+		}
+	}
+	j := &Journal[T]{path: path, done: done, appended: make(map[string]bool)}
+	j.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Len returns how many jobs are recorded as finished.
+func (j *Journal[T]) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.done) + len(j.appended)
+}
+
+// Done returns the recorded value for key, if that job finished in an
+// earlier run.
+func (j *Journal[T]) Done(key string) (T, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v, ok := j.done[key]
+	return v, ok
+}
+
+// Append records key as finished and flushes it to disk right away.
+func (j *Journal[T]) Append(key string, value T) error {
+	line, err := json.Marshal(record[T]{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(line); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	if _, ok := j.done[key]; !ok {
+		j.appended[key] = true
+	}
+	return nil
+}
+
+func (j *Journal[T]) Close() error {
+	return j.file.Close()
+}
+
+// Rename closes the journal and moves it to path, so a finished journal can
+// be kept as the record of the run.
+func (j *Journal[T]) Rename(path string) error {
+	j.file.Close()
+	return os.Rename(j.path, path)
+}
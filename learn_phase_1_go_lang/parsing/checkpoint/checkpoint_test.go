@@ -0,0 +1,66 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_ResumesFinishedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output", "journal.jsonl")
+
+	j, err := Open[[]string](path)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append("https://example.com/meal/1", []string{"a"}))
+	assert.NoError(t, j.Append("https://example.com/meal/2", []string{"b", "c"}))
+	assert.NoError(t, j.Close())
+
+	// Simulate a crash in the middle of writing the next line
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"key":"https://example.com/meal/3","val`)
+	assert.NoError(t, err)
+	f.Close()
+
+	j, err = Open[[]string](path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, j.Len())
+
+	v, ok := j.Done("https://example.com/meal/2")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"b", "c"}, v)
+
+	_, ok = j.Done("https://example.com/meal/3")
+	assert.False(t, ok)
+
+	// The torn line must not swallow the next record
+	assert.NoError(t, j.Append("https://example.com/meal/3", []string{"d"}))
+	assert.NoError(t, j.Close())
+	j, err = Open[[]string](path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, j.Len())
+	j.Close()
+}
+
+func TestJournal_RenameKeepsRecords(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open[int](filepath.Join(dir, "journal.jsonl"))
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append("a", 1))
+	assert.NoError(t, j.Append("b", 2))
+	// appended values are not kept in memory, only counted
+	_, ok := j.Done("a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, j.Len())
+	assert.NoError(t, j.Rename(filepath.Join(dir, "state.jsonl")))
+
+	state, err := Load[int](filepath.Join(dir, "state.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, state)
+
+	missing, err := Load[int](filepath.Join(dir, "missing.jsonl"))
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+}
@@ -0,0 +1,218 @@
+package crawl_diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+)
+
+// Report is how the meals of one crawl differ from those of an older one.
+type Report struct {
+	Old       Source     `json:"old"`
+	New       Source     `json:"new"`
+	Added     []Meal     `json:"added"`
+	Removed   []Meal     `json:"removed"`
+	Changed   []MealDiff `json:"changed"`
+	Unchanged int        `json:"unchanged"`
+}
+
+// Source is one side of the comparison.
+type Source struct {
+	Path  string `json:"path"`
+	Meals int    `json:"meals"`
+}
+
+// Meal names a meal that was added or removed.
+type Meal struct {
+	Name string `json:"name"`
+	ID   int    `json:"id,omitempty"`
+	Flag string `json:"flag,omitempty"`
+}
+
+// MealDiff is what changed in a meal found in both crawls.
+type MealDiff struct {
+	Meal
+	Fields      []FieldChange      `json:"fields,omitempty"`
+	Ingredients *IngredientChanges `json:"ingredients,omitempty"`
+	// Instructions is a unified diff of the old and new instructions, one
+	// paragraph per line
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// FieldChange is a field of the meal with another value.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// IngredientChanges lists the ingredients of a meal that were added,
+// removed or given another caption, e.g. a new quantity.
+type IngredientChanges struct {
+	Added   []string           `json:"added,omitempty"`
+	Removed []string           `json:"removed,omitempty"`
+	Changed []IngredientChange `json:"changed,omitempty"`
+}
+
+// IngredientChange is an ingredient whose caption changed.
+type IngredientChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Compare compares two crawls. Meals are matched by meal ID when both sides
+// have one, then by name, ignoring case and the " Recipe" suffix. Added and
+// changed meals are listed in the order of the new crawl, removed ones in
+// the order of the old. Like the change report of an incremental crawl,
+// where and when a page was fetched doesn't count as a change.
+func Compare(before, after []detail_parse.MealDetail) *Report {
+	r := &Report{
+		Old:     Source{Meals: len(before)},
+		New:     Source{Meals: len(after)},
+		Added:   []Meal{},
+		Removed: []Meal{},
+		Changed: []MealDiff{},
+	}
+	byID := map[int]int{}
+	byName := map[string]int{}
+	for i, d := range before {
+		if d.ID != 0 {
+			if _, dup := byID[d.ID]; !dup {
+				byID[d.ID] = i
+			}
+		}
+		if _, dup := byName[nameKey(d)]; !dup {
+			byName[nameKey(d)] = i
+		}
+	}
+	matched := make([]bool, len(before))
+	match := func(d detail_parse.MealDetail) (int, bool) {
+		if i, ok := byID[d.ID]; ok && d.ID != 0 && !matched[i] {
+			return i, true
+		}
+		i, ok := byName[nameKey(d)]
+		if ok && !matched[i] && (d.ID == 0 || before[i].ID == 0 || d.ID == before[i].ID) {
+			return i, true
+		}
+		return 0, false
+	}
+
+	for _, d := range after {
+		i, ok := match(d)
+		if !ok {
+			r.Added = append(r.Added, mealOf(d))
+			continue
+		}
+		matched[i] = true
+		if diff, changed := compareMeal(before[i], d); changed {
+			r.Changed = append(r.Changed, diff)
+		} else {
+			r.Unchanged++
+		}
+	}
+	for i, d := range before {
+		if !matched[i] {
+			r.Removed = append(r.Removed, mealOf(d))
+		}
+	}
+	return r
+}
+
+// Empty reports whether the crawls have the same meals.
+func (r *Report) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+func nameKey(d detail_parse.MealDetail) string {
+	return strings.ToLower(d.DisplayName())
+}
+
+func mealOf(d detail_parse.MealDetail) Meal {
+	return Meal{Name: d.DisplayName(), ID: d.ID, Flag: d.Flag}
+}
+
+func compareMeal(before, after detail_parse.MealDetail) (MealDiff, bool) {
+	diff := MealDiff{Meal: mealOf(after)}
+	for _, f := range []struct{ name, old, new string }{
+		{"name", before.DisplayName(), after.DisplayName()},
+		{"flag", before.Flag, after.Flag},
+		{"category", before.Category, after.Category},
+		{"area", before.Area, after.Area},
+		{"tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", ")},
+		{"youtube", before.YouTube, after.YouTube},
+		{"recipe_source", before.RecipeSource, after.RecipeSource},
+		{"thumbnail", before.Thumbnail, after.Thumbnail},
+	} {
+		if f.old != f.new {
+			diff.Fields = append(diff.Fields, FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	diff.Ingredients = compareIngredients(before.Ingredents, after.Ingredents)
+	diff.Instructions = Unified(lines(before.Receipt), lines(after.Receipt), "old", "new", 3)
+	return diff, len(diff.Fields) > 0 || diff.Ingredients != nil || diff.Instructions != ""
+}
+
+// lines splits instructions into their paragraphs, ignoring the line
+// endings and blank lines the site adds.
+func lines(receipt string) []string {
+	var out []string
+	for _, line := range strings.Split(strings.ReplaceAll(receipt, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// compareIngredients matches the ingredients by name, so "100g butter"
+// becoming "125g butter" is a change rather than one removed and one
+// added. It returns nil when nothing changed.
+func compareIngredients(before, after []detail_parse.Ingredient) *IngredientChanges {
+	oldKeys, newKeys := ingredientKeys(before), ingredientKeys(after)
+	byKey := make(map[string]detail_parse.Ingredient, len(before))
+	for i, key := range oldKeys {
+		byKey[key] = before[i]
+	}
+	c := &IngredientChanges{}
+	for i, key := range newKeys {
+		prev, ok := byKey[key]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, after[i].Caption)
+		case prev.Caption != after[i].Caption:
+			c.Changed = append(c.Changed, IngredientChange{Name: ingredient_catalog.IngredientName(after[i]), Old: prev.Caption, New: after[i].Caption})
+		}
+	}
+	for i, key := range oldKeys {
+		if !slices.Contains(newKeys, key) {
+			c.Removed = append(c.Removed, before[i].Caption)
+		}
+	}
+	if len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0 {
+		return nil
+	}
+	return c
+}
+
+// ingredientKeys files every ingredient under its name, numbering the ones
+// a meal lists twice, e.g. butter for the pastry and for the filling.
+func ingredientKeys(ings []detail_parse.Ingredient) []string {
+	keys := make([]string, len(ings))
+	count := map[string]int{}
+	for i, ing := range ings {
+		key := ingredient_catalog.ID(ingredient_catalog.IngredientName(ing))
+		if key == "" {
+			key = strings.ToLower(strings.TrimSpace(ing.Caption))
+		}
+		count[key]++
+		if n := count[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		keys[i] = key
+	}
+	return keys
+}
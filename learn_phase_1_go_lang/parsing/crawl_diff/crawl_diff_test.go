@@ -0,0 +1,138 @@
+package crawl_diff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11"}
+	assert.Equal(t, `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -8,3 +8,4 @@
+ 8
+ 9
+ 10
++11
+`, Unified(a, b, "a", "b", 3))
+
+	// Changes closer than twice the context share a hunk
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,3 +1,3 @@\n-1\n+one\n 2\n-3\n+three\n", Unified([]string{"1", "2", "3"}, []string{"one", "2", "three"}, "a", "b", 1))
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n", Unified(nil, []string{"new"}, "a", "b", 3))
+	assert.Equal(t, "", Unified(a, a, "a", "b", 3))
+}
+
+func TestCompare(t *testing.T) {
+	before := []detail_parse.MealDetail{
+		{ReceiptName: "Tarte Tatin Recipe", Flag: "fr", Receipt: "Heat the oven.\nBake for 25 mins.\nServe.", Ingredents: meal_fixture.Ingredients("100g butter", "6 apples", "100g sugar")},
+		{ReceiptName: "Pad Thai Recipe", Flag: "th", Receipt: "Fry.", Ingredents: meal_fixture.Ingredients("200g rice noodles")},
+		{ReceiptName: "Bakewell tart Recipe", Flag: "gb", Receipt: "Bake."},
+	}
+	after := []detail_parse.MealDetail{
+		{ReceiptName: "Bakewell tart Recipe", Flag: "gb", Receipt: "Bake."},
+		{ReceiptName: "Tarte tatin Recipe", Flag: "fr", Receipt: "Heat the oven.\r\n\r\nBake for 30 mins.\nServe.", Ingredents: meal_fixture.Ingredients("125g butter", "6 apples", "1 sheet puff pastry")},
+		{ReceiptName: "Ramen Recipe", Flag: "jp", Receipt: "Boil."},
+	}
+	after[1].Category = "Dessert"
+	// Where and when a page was fetched is not a change
+	after[0].ID, after[0].Crawl = 52767, &detail_parse.CrawlInfo{HTTPStatus: 200}
+
+	r := Compare(before, after)
+	assert.Equal(t, []Meal{{Name: "Ramen", Flag: "jp"}}, r.Added)
+	assert.Equal(t, []Meal{{Name: "Pad Thai", Flag: "th"}}, r.Removed)
+	assert.Equal(t, 1, r.Unchanged)
+	assert.False(t, r.Empty())
+
+	assert.Len(t, r.Changed, 1)
+	c := r.Changed[0]
+	assert.Equal(t, "Tarte tatin", c.Name)
+	assert.Equal(t, []FieldChange{
+		{Field: "name", Old: "Tarte Tatin", New: "Tarte tatin"},
+		{Field: "category", Old: "", New: "Dessert"},
+	}, c.Fields)
+	assert.Equal(t, &IngredientChanges{
+		Added:   []string{"1 sheet puff pastry"},
+		Removed: []string{"100g sugar"},
+		Changed: []IngredientChange{{Name: "butter", Old: "100g butter", New: "125g butter"}},
+	}, c.Ingredients)
+	assert.Equal(t, "--- old\n+++ new\n@@ -1,3 +1,3 @@\n Heat the oven.\n-Bake for 25 mins.\n+Bake for 30 mins.\n Serve.\n", c.Instructions)
+
+	var text bytes.Buffer
+	assert.NoError(t, r.WriteText(&text))
+	assert.Equal(t, ` (3 meals) ->  (3 meals)
+1 added, 1 removed, 1 changed, 1 unchanged
+
+Added:
+  + Ramen (jp)
+
+Removed:
+  - Pad Thai (th)
+
+Changed:
+  ~ Tarte tatin (fr)
+    name: "Tarte Tatin" -> "Tarte tatin"
+    category: "" -> "Dessert"
+    ingredients:
+      + 1 sheet puff pastry
+      - 100g sugar
+      ~ 100g butter -> 125g butter
+    instructions:
+      --- old
+      +++ new
+      @@ -1,3 +1,3 @@
+       Heat the oven.
+      -Bake for 25 mins.
+      +Bake for 30 mins.
+       Serve.
+`, text.String())
+
+	assert.True(t, Compare(after, after).Empty())
+}
+
+func TestCompare_MatchesByID(t *testing.T) {
+	before := []detail_parse.MealDetail{{ReceiptName: "Tart Recipe", Flag: "gb", Receipt: "Bake."}, {ReceiptName: "Pie Recipe", Flag: "gb", Receipt: "Bake."}}
+	before[0].ID, before[1].ID = 1, 2
+	after := []detail_parse.MealDetail{{ReceiptName: "Treacle Tart Recipe", Flag: "gb", Receipt: "Bake."}, {ReceiptName: "Pie Recipe", Flag: "gb", Receipt: "Bake."}}
+	after[0].ID, after[1].ID = 1, 3
+
+	r := Compare(before, after)
+	// A renamed meal keeps its ID, a meal with a new ID is another meal
+	assert.Equal(t, []FieldChange{{Field: "name", Old: "Tart", New: "Treacle Tart"}}, r.Changed[0].Fields)
+	assert.Equal(t, []Meal{{Name: "Pie", ID: 3, Flag: "gb"}}, r.Added)
+	assert.Equal(t, []Meal{{Name: "Pie", ID: 2, Flag: "gb"}}, r.Removed)
+}
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "final_items.json")
+	assert.NoError(t, os.WriteFile(file, []byte("[]"), 0644))
+	history := filepath.Join(dir, "history")
+
+	second, err := Snapshot(history, file, time.Date(2026, 10, 12, 3, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(history, "20261012T030000Z.json"), second)
+	first, err := Snapshot(history, file, time.Date(2026, 10, 5, 3, 0, 0, 0, time.FixedZone("CEST", 2*3600)))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(history, "notes.txt"), nil, 0644))
+
+	snapshots, err := Snapshots(history)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first, second}, snapshots)
+	assert.Equal(t, "20261005T010000Z.json", filepath.Base(first))
+}
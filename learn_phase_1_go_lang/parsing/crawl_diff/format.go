@@ -0,0 +1,96 @@
+package crawl_diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats are the formats WriteFormat knows.
+var Formats = []string{"text", "json"}
+
+// WriteFormat writes the report as text or json.
+func (r *Report) WriteFormat(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		return r.WriteText(w)
+	case "json":
+		return r.WriteJSON(w)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+// WriteText writes the report for a person: the counts, the added and
+// removed meals, then every changed meal with its fields, ingredients and
+// the diff of its instructions.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d meals) -> %s (%d meals)\n", r.Old.Path, r.Old.Meals, r.New.Path, r.New.Meals)
+	fmt.Fprintf(&b, "%d added, %d removed, %d changed, %d unchanged\n",
+		len(r.Added), len(r.Removed), len(r.Changed), r.Unchanged)
+
+	for _, list := range []struct {
+		title string
+		sign  string
+		meals []Meal
+	}{{"Added", "+", r.Added}, {"Removed", "-", r.Removed}} {
+		if len(list.meals) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", list.title)
+		for _, m := range list.meals {
+			fmt.Fprintf(&b, "  %s %s\n", list.sign, describe(m))
+		}
+	}
+
+	if len(r.Changed) > 0 {
+		b.WriteString("\nChanged:\n")
+	}
+	for _, c := range r.Changed {
+		fmt.Fprintf(&b, "  ~ %s\n", describe(c.Meal))
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s: %q -> %q\n", f.Field, f.Old, f.New)
+		}
+		if ing := c.Ingredients; ing != nil {
+			b.WriteString("    ingredients:\n")
+			for _, caption := range ing.Added {
+				fmt.Fprintf(&b, "      + %s\n", caption)
+			}
+			for _, caption := range ing.Removed {
+				fmt.Fprintf(&b, "      - %s\n", caption)
+			}
+			for _, change := range ing.Changed {
+				fmt.Fprintf(&b, "      ~ %s -> %s\n", change.Old, change.New)
+			}
+		}
+		if c.Instructions != "" {
+			b.WriteString("    instructions:\n")
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(c.Instructions, "\n"), "\n") {
+				fmt.Fprintf(&b, "      %s", line)
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func describe(m Meal) string {
+	s := m.Name
+	if m.Flag != "" {
+		s += " (" + m.Flag + ")"
+	}
+	if m.ID != 0 {
+		s += fmt.Sprintf(" #%d", m.ID)
+	}
+	return s
+}
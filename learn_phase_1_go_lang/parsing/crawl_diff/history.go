@@ -0,0 +1,48 @@
+package crawl_diff
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// snapshotTime names the snapshots, so sorting the names sorts them by time.
+const snapshotTime = "20060102T150405Z"
+
+// Snapshot copies a finished crawl file (final_items.json or .jsonl) into
+// the history directory dir as <time>.json or <time>.jsonl, and returns its
+// path.
+func Snapshot(dir, file string, at time.Time) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, at.UTC().Format(snapshotTime)+filepath.Ext(file))
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// Snapshots lists the snapshots of the history directory dir, oldest first.
+func Snapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		stem := strings.TrimSuffix(strings.TrimSuffix(name, ".jsonl"), ".json")
+		if e.IsDir() || stem == name {
+			continue
+		}
+		if _, err := time.Parse(snapshotTime, stem); err == nil {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
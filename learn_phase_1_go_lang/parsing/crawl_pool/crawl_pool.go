@@ -0,0 +1,277 @@
+package crawl_pool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go_lang/parsing/http_cache"
+
+	"github.com/gocolly/colly"
+	"github.com/gocolly/colly/debug"
+)
+
+// Verbosity levels of Config.Verbosity. Errors are always printed.
+const (
+	Quiet   = 0 // errors only
+	Normal  = 1 // progress and summaries
+	Verbose = 2 // also every request and response colly handles
+)
+
+// Config controls how many pages are fetched at once and how long to wait
+// between two requests to the same host.
+type Config struct {
+	Parallelism int           // number of workers, also the max concurrent requests per host
+	Delay       time.Duration // fixed wait after each request to a host
+	RandomDelay time.Duration // random jitter in [0, RandomDelay) added to Delay
+	// Transport replaces the HTTP transport of every collector, e.g. to
+	// record or replay fixtures. Nil keeps colly's default.
+	Transport http.RoundTripper
+	Verbosity int // one of Quiet, Normal or Verbose
+	// Logger receives the progress of the crawl. Nil logs text to stdout at
+	// the level of Verbosity.
+	Logger *slog.Logger
+	// ProgressInterval is how often a running stage logs its progress,
+	// never when zero
+	ProgressInterval time.Duration
+	// MaxPages stops the crawl once that many list and meal pages were
+	// fetched, see TakePage. Zero means no limit.
+	MaxPages int
+
+	// UserAgent names the crawler in every request, DefaultUserAgent when
+	// empty. Contact, an email address or URL, is added to it so site owners
+	// can reach whoever runs the crawl.
+	UserAgent string
+	Contact   string
+	// Robots honours the robots.txt of every site: disallowed pages fail
+	// with ErrDisallowed and its Crawl-delay is kept, see polite.
+	Robots bool
+	// CacheDir, if set, keeps the answers on disk and serves them again for
+	// CacheTTL, forever when zero, see http_cache.
+	CacheDir string
+	CacheTTL time.Duration
+
+	Retries        int           // extra attempts for transient errors, see Visit
+	RetryBaseDelay time.Duration // wait before the first retry, doubled for every next one
+	RetryMaxDelay  time.Duration // longest wait between two attempts
+}
+
+// DefaultConfig is polite enough for themealdb.com while still being much
+// faster than visiting every page in series.
+func DefaultConfig() Config {
+	return Config{
+		Parallelism: 4,
+		Delay:       200 * time.Millisecond,
+		RandomDelay: 300 * time.Millisecond,
+		Verbosity:   Normal,
+
+		ProgressInterval: 10 * time.Second,
+
+		UserAgent: DefaultUserAgent,
+		Robots:    true,
+		CacheTTL:  24 * time.Hour,
+
+		Retries:        3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  30 * time.Second,
+	}
+}
+
+// Pool is shared by the list and detail phases. All collectors handed out by
+// the pool share one HTTP backend, so the rate limit applies across phases.
+type Pool struct {
+	cfg   Config
+	base  *colly.Collector
+	log   *slog.Logger
+	stats *Stats
+	taken atomic.Int64 // pages counted against MaxPages
+
+	// ctx is the context.Context of the crawl, see SetContext
+	ctx atomic.Value
+
+	// transport is the metered transport, for requests of the pool itself
+	transport http.RoundTripper
+	sitesMu   sync.Mutex
+	sites     map[string]*site // by scheme://host
+}
+
+// ErrStopped is returned for the pages of a crawl that was stopped before
+// they were fetched: cancelled, out of time or out of pages. It wraps the
+// cause.
+var ErrStopped = errors.New("crawl stopped")
+
+// ErrPageBudget is the cause of a crawl stopped by Config.MaxPages.
+var ErrPageBudget = errors.New("page budget spent")
+
+// Stopped returns why a crawl under ctx has to stop, wrapped in
+// ErrStopped, or nil while it may go on.
+func (p *Pool) Stopped(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ErrStopped, context.Cause(ctx))
+	}
+	if p.cfg.MaxPages > 0 && p.taken.Load() >= int64(p.cfg.MaxPages) {
+		return fmt.Errorf("%w: %w (%d pages)", ErrStopped, ErrPageBudget, p.cfg.MaxPages)
+	}
+	return nil
+}
+
+// SetContext ties the waits of the pool's transports to the crawl under
+// ctx: fetching a robots.txt and keeping its Crawl-delay are given up with
+// the Stopped error once ctx is done. colly sends its requests without a
+// context, so the transports can't take it from the request.
+func (p *Pool) SetContext(ctx context.Context) {
+	p.ctx.Store(ctx)
+}
+
+func (p *Pool) context() context.Context {
+	if ctx, ok := p.ctx.Load().(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// TakePage counts a page that is about to be fetched against
+// Config.MaxPages. It returns the Stopped error instead when the page must
+// not be fetched. Images don't count, only the pages a stage is made of.
+func (p *Pool) TakePage(ctx context.Context) error {
+	if err := p.Stopped(ctx); err != nil {
+		return err
+	}
+	if n := p.taken.Add(1); p.cfg.MaxPages > 0 && n > int64(p.cfg.MaxPages) {
+		return fmt.Errorf("%w: %w (%d pages)", ErrStopped, ErrPageBudget, p.cfg.MaxPages)
+	}
+	return nil
+}
+
+// Level is the log level of a verbosity.
+func Level(verbosity int) slog.Level {
+	switch {
+	case verbosity <= Quiet:
+		return slog.LevelWarn
+	case verbosity >= Verbose:
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+func New(cfg Config) (*Pool, error) {
+	if cfg.Parallelism < 1 {
+		cfg.Parallelism = 1
+	}
+	if cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		cfg.RetryMaxDelay = cfg.RetryBaseDelay
+	}
+	base := colly.NewCollector()
+	if cfg.Verbosity >= Verbose {
+		base.SetDebugger(&debug.LogDebugger{})
+	}
+	// The pool decides which pages get visited, so colly's own duplicate
+	// filter would only get in the way of re-runs in the same process.
+	base.AllowURLRevisit = true
+	stats := newStats()
+	p := &Pool{cfg: cfg, base: base, log: cfg.Logger, stats: stats, sites: map[string]*site{}}
+	if p.log == nil {
+		p.log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: Level(cfg.Verbosity)}))
+	}
+	p.transport = &meter{next: cmp.Or(cfg.Transport, http.DefaultTransport), stats: stats}
+	var transport http.RoundTripper = &polite{pool: p, next: p.transport}
+	if cfg.CacheDir != "" {
+		transport = &http_cache.Transport{
+			Dir: cfg.CacheDir, TTL: cfg.CacheTTL, Next: transport,
+			OnHit: func(*http.Request) { stats.cacheHit() },
+			Log:   p.log,
+		}
+	}
+	base.WithTransport(transport)
+	base.UserAgent = cfg.userAgent()
+	err := base.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: cfg.Parallelism,
+		Delay:       cfg.Delay,
+		RandomDelay: cfg.RandomDelay,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Config returns the configuration the pool was created with.
+func (p *Pool) Config() Config {
+	return p.cfg
+}
+
+// Log is the logger of the crawl progress.
+func (p *Pool) Log() *slog.Logger {
+	return p.log
+}
+
+// Stats counts what the pool fetched since it was created.
+func (p *Pool) Stats() *Stats {
+	return p.stats
+}
+
+// Collector returns a fresh collector for a single job. Callbacks registered
+// on it only fire for pages it visits itself, so a job can collect its
+// results into local variables without any locking.
+func (p *Pool) Collector() *colly.Collector {
+	return p.base.Clone()
+}
+
+// Map runs fn for every index in [0, n) on the pool's workers and calls emit
+// with each result in index order, as soon as all earlier results are in.
+// emit is never called concurrently, so it may append to shared state.
+// Once the crawl is stopped, see Stopped, no more indexes are handed out:
+// the jobs already running finish and are emitted, and Map returns the
+// Stopped error.
+func Map[T any](ctx context.Context, p *Pool, n int, fn func(i int) T, emit func(i int, v T)) error {
+	jobs := make(chan int)
+	var (
+		mu      sync.Mutex
+		pending = make(map[int]T)
+		next    int
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < p.cfg.Parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				v := fn(i)
+				mu.Lock()
+				pending[i] = v
+				for {
+					ready, ok := pending[next]
+					if !ok {
+						break
+					}
+					delete(pending, next)
+					emit(next, ready)
+					next++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	var stopped error
+	for i := 0; i < n && stopped == nil; i++ {
+		if stopped = p.Stopped(ctx); stopped != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			stopped = p.Stopped(ctx)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return stopped
+}
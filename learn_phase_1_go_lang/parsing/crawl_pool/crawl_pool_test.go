@@ -0,0 +1,71 @@
+package crawl_pool
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap_KeepsIndexOrder(t *testing.T) {
+	for _, parallelism := range []int{1, 3, 16} {
+		pool, err := New(Config{Parallelism: parallelism})
+		assert.NoError(t, err)
+
+		var got []int
+		Map(context.Background(), pool, 50, func(i int) int {
+			// Finish jobs out of order on purpose
+			time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+			return i * 10
+		}, func(i int, v int) {
+			assert.Equal(t, i*10, v)
+			got = append(got, v)
+		})
+
+		assert.Len(t, got, 50)
+		for i, v := range got {
+			assert.Equal(t, i*10, v, "parallelism %d", parallelism)
+		}
+	}
+}
+
+func TestMap_Stops(t *testing.T) {
+	pool, err := New(Config{Parallelism: 2, MaxPages: 5})
+	assert.NoError(t, err)
+
+	ran := 0
+	err = Map(context.Background(), pool, 50, func(i int) error {
+		return pool.TakePage(context.Background())
+	}, func(i int, err error) {
+		if err == nil {
+			ran++
+		}
+	})
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.ErrorIs(t, err, ErrPageBudget)
+	assert.Equal(t, 5, ran)
+
+	pool, err = New(Config{Parallelism: 2})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	ran = 0
+	err = Map(ctx, pool, 50, func(i int) int {
+		if i == 9 {
+			cancel(errors.New("interrupted"))
+		}
+		return i
+	}, func(i int, _ int) { ran++ })
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.EqualError(t, err, "crawl stopped: interrupted")
+	assert.Less(t, ran, 50)
+	assert.NoError(t, Map(context.Background(), pool, 3, func(i int) int { return i }, func(int, int) {}))
+}
+
+func TestNew_ClampsParallelism(t *testing.T) {
+	pool, err := New(Config{Parallelism: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pool.Config().Parallelism)
+}
@@ -0,0 +1,55 @@
+package crawl_pool
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// ServeHTTP serves the counters of the pool in the Prometheus text format,
+// for a /metrics endpoint that can be scraped while the crawl runs.
+func (s *Stats) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r := s.Report()
+	var b bytes.Buffer
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("crawl_requests_total", "counter", "HTTP requests sent, retries included.")
+	fmt.Fprintf(&b, "crawl_requests_total %d\n", r.Requests)
+	metric("crawl_bytes_total", "counter", "Bytes of response bodies read.")
+	fmt.Fprintf(&b, "crawl_bytes_total %d\n", r.Bytes)
+	metric("crawl_cache_hits_total", "counter", "Requests answered from the HTTP cache.")
+	fmt.Fprintf(&b, "crawl_cache_hits_total %d\n", r.CacheHits)
+	metric("crawl_responses_total", "counter", "HTTP responses by status, 0 for requests without an answer.")
+	for _, key := range slices.Sorted(maps.Keys(r.Statuses)) {
+		status := key
+		if key == "error" {
+			status = "0"
+		}
+		fmt.Fprintf(&b, "crawl_responses_total{status=%q} %d\n", status, r.Statuses[key])
+	}
+
+	metric("crawl_stage_pages", "gauge", "Pages a stage has to do.")
+	for _, st := range r.Stages {
+		fmt.Fprintf(&b, "crawl_stage_pages{stage=%q} %d\n", st.Name, st.Queued)
+	}
+	metric("crawl_stage_pages_done_total", "counter", "Pages of a stage finished, by result.")
+	for _, st := range r.Stages {
+		fmt.Fprintf(&b, "crawl_stage_pages_done_total{stage=%q,result=\"ok\"} %d\n", st.Name, st.Done)
+		fmt.Fprintf(&b, "crawl_stage_pages_done_total{stage=%q,result=\"failed\"} %d\n", st.Name, st.Failed)
+	}
+	metric("crawl_stage_seconds", "gauge", "Time a stage has run.")
+	for _, st := range r.Stages {
+		fmt.Fprintf(&b, "crawl_stage_seconds{stage=%q} %g\n", st.Name, st.Seconds)
+	}
+	metric("crawl_stage_eta_seconds", "gauge", "Time the pages left of a running stage should take.")
+	for _, st := range r.Stages {
+		fmt.Fprintf(&b, "crawl_stage_eta_seconds{stage=%q} %g\n", st.Name, st.ETASeconds)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
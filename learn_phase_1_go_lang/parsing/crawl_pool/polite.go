@@ -0,0 +1,209 @@
+package crawl_pool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// DefaultUserAgent names the crawler to the sites it visits.
+const DefaultUserAgent = "mealdb-crawler/1.0"
+
+// ErrDisallowed is returned for pages the robots.txt of their site keeps
+// the crawler away from.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsMaxSize is how much of a robots.txt is read, RFC 9309 asks for at
+// least 500 KiB.
+const robotsMaxSize = 512 << 10
+
+// userAgent is the User-Agent header: Config.UserAgent with the contact
+// address in a comment, the way crawlers usually leave one.
+func (c Config) userAgent() string {
+	ua := cmp.Or(c.UserAgent, DefaultUserAgent)
+	if c.Contact != "" {
+		ua += " (+" + c.Contact + ")"
+	}
+	return ua
+}
+
+// robotsAgent is the name robots.txt groups are matched against, the
+// product token of the User-Agent without its version.
+func (c Config) robotsAgent() string {
+	product, _, _ := strings.Cut(cmp.Or(c.UserAgent, DefaultUserAgent), " ")
+	product, _, _ = strings.Cut(product, "/")
+	return product
+}
+
+// identify sets the headers that tell a site who is crawling it. A contact
+// that is an email address also goes into From.
+func (p *Pool) identify(h http.Header) {
+	h.Set("User-Agent", p.cfg.userAgent())
+	if contact := strings.TrimPrefix(p.cfg.Contact, "mailto:"); strings.Contains(contact, "@") && !strings.Contains(contact, "://") {
+		h.Set("From", contact)
+	}
+}
+
+// site is what the pool knows about one scheme and host.
+type site struct {
+	ready  chan struct{} // closed once robots or err is set
+	robots *robotstxt.RobotsData
+	err    error
+
+	mu   sync.Mutex
+	next time.Time // when the next request may start, for Crawl-delay
+}
+
+// site returns the robots.txt rules of the site of u, fetching them the
+// first time. A robots.txt that could not be fetched is tried again by the
+// next request.
+func (p *Pool) site(ctx context.Context, u *url.URL) (*site, error) {
+	origin := u.Scheme + "://" + u.Host
+	p.sitesMu.Lock()
+	s, ok := p.sites[origin]
+	if !ok {
+		s = &site{ready: make(chan struct{})}
+		p.sites[origin] = s
+	}
+	p.sitesMu.Unlock()
+
+	if ok {
+		select {
+		case <-s.ready:
+			return s, s.err
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+	s.robots, s.err = p.fetchRobots(ctx, origin)
+	if s.err != nil {
+		p.sitesMu.Lock()
+		delete(p.sites, origin)
+		p.sitesMu.Unlock()
+	}
+	close(s.ready)
+	return s, s.err
+}
+
+// fetchRobots reads the robots.txt of origin. Following RFC 9309 a missing
+// one (4xx) allows everything; a server error is returned as an error, the
+// pages of the site fail until it can be read.
+func (p *Pool) fetchRobots(ctx context.Context, origin string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	p.identify(req.Header)
+	// Redirects are followed, the pool's own collectors don't see this request
+	client := &http.Client{Transport: p.transport}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 500 {
+		return nil, fmt.Errorf("fetch %s: %s", req.URL, res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, robotsMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	robots, err := robotstxt.FromStatusAndBytes(res.StatusCode, body)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", req.URL, err)
+	}
+	attrs := []any{"url", req.URL.String(), "status", res.StatusCode, "sitemaps", len(robots.Sitemaps)}
+	if delay := robots.FindGroup(p.cfg.robotsAgent()).CrawlDelay; delay > 0 {
+		attrs = append(attrs, "crawl_delay", delay.Seconds())
+	}
+	p.log.Info("robots.txt", attrs...)
+	return robots, nil
+}
+
+// Sitemaps returns the sitemaps the robots.txt of the site of siteURL
+// lists, with or without Config.Robots.
+func (p *Pool) Sitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	s, err := p.site(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return s.robots.Sitemaps, nil
+}
+
+// wait holds a request back until delay has passed since the start of the
+// one before it.
+func (s *site) wait(ctx context.Context, delay time.Duration) error {
+	s.mu.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	s.next = start.Add(delay)
+	s.mu.Unlock()
+
+	if !start.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// polite is the transport between the collectors and meter. It sends the
+// User-Agent and contact address and, with Config.Robots, turns pages
+// disallowed by robots.txt into ErrDisallowed and keeps its Crawl-delay
+// between two requests to a site, on top of Config.Delay. Both waits end
+// with the context of Pool.SetContext.
+type polite struct {
+	pool *Pool
+	next http.RoundTripper
+}
+
+func (t *polite) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	t.pool.identify(req.Header)
+	if !t.pool.cfg.Robots || req.URL.Path == "/robots.txt" {
+		return t.next.RoundTrip(req)
+	}
+	ctx := t.pool.context()
+	s, err := t.pool.site(ctx, req.URL)
+	if err != nil {
+		return nil, t.stopped(ctx, err)
+	}
+	agent := t.pool.cfg.robotsAgent()
+	if !s.robots.TestAgent(req.URL.RequestURI(), agent) {
+		return nil, ErrDisallowed
+	}
+	if err := s.wait(ctx, s.robots.FindGroup(agent).CrawlDelay); err != nil {
+		return nil, t.stopped(ctx, err)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// stopped turns err into the Stopped error when the crawl's ctx is done,
+// so the page counts as not fetched rather than failed.
+func (t *polite) stopped(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return t.pool.Stopped(ctx)
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package crawl_pool
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolite_Robots(t *testing.T) {
+	var mu sync.Mutex
+	var robotsCalls int
+	var visits []time.Time
+	var agents, from []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		agents = append(agents, r.UserAgent())
+		from = append(from, r.Header.Get("From"))
+		if r.URL.Path == "/robots.txt" {
+			robotsCalls++
+			io.WriteString(w, "User-agent: *\nDisallow: /\n\n"+
+				"User-agent: mealdb-crawler\nDisallow: /private\nAllow: /private/ok\nCrawl-delay: 0.05\n\n"+
+				"Sitemap: https://example.com/sitemap.xml\n")
+			return
+		}
+		visits = append(visits, time.Now())
+		io.WriteString(w, "<html>ok</html>")
+	}))
+	defer srv.Close()
+
+	pool, err := New(Config{Parallelism: 3, Robots: true, Contact: "mailto:cook@example.com"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	err = pool.Visit(ctx, pool.Collector(), srv.URL+"/private/recipes")
+	assert.ErrorIs(t, err, ErrDisallowed)
+	var wg sync.WaitGroup
+	for _, path := range []string{"/meal/1", "/meal/2", "/private/ok"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, pool.Visit(ctx, pool.Collector(), srv.URL+path))
+		}()
+	}
+	wg.Wait()
+
+	sitemaps, err := pool.Sitemaps(ctx, srv.URL+"/meal/1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/sitemap.xml"}, sitemaps)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, robotsCalls)
+	assert.Len(t, visits, 3)
+	// Crawl-delay holds requests apart even with several workers
+	for i := 1; i < len(visits); i++ {
+		assert.GreaterOrEqual(t, visits[i].Sub(visits[i-1]), 40*time.Millisecond)
+	}
+	for i := range agents {
+		assert.Equal(t, "mealdb-crawler/1.0 (+mailto:cook@example.com)", agents[i])
+		assert.Equal(t, "cook@example.com", from[i])
+	}
+}
+
+func TestPolite_RobotsErrors(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(status)
+			return
+		}
+		io.WriteString(w, "<html>ok</html>")
+	}))
+	defer srv.Close()
+
+	pool, err := New(Config{Robots: true, UserAgent: "test-bot/2"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// A site whose robots.txt is down isn't crawled, the next page asks again
+	assert.ErrorContains(t, pool.Visit(ctx, pool.Collector(), srv.URL+"/a"), "503 Service Unavailable")
+	// No robots.txt allows everything
+	status = http.StatusNotFound
+	assert.NoError(t, pool.Visit(ctx, pool.Collector(), srv.URL+"/a"))
+}
+
+func TestPolite_Stopped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			io.WriteString(w, "User-agent: *\nCrawl-delay: 60\n")
+			return
+		}
+		io.WriteString(w, "<html>ok</html>")
+	}))
+	defer srv.Close()
+
+	pool, err := New(Config{Robots: true})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.SetContext(ctx)
+	assert.NoError(t, pool.Visit(ctx, pool.Collector(), srv.URL+"/a"))
+
+	// The Crawl-delay before the next page is given up with the crawl
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err = pool.Visit(context.Background(), pool.Collector(), srv.URL+"/b")
+	assert.ErrorIs(t, err, ErrStopped)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
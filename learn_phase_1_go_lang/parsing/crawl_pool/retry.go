@@ -0,0 +1,123 @@
+package crawl_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// VisitError is returned by Visit when a page could not be loaded, after
+// all retries.
+type VisitError struct {
+	URL      string
+	Status   int // HTTP status, 0 when there was no answer
+	Attempts int
+	Err      error
+}
+
+func (e *VisitError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s: %v (after %d attempts)", e.URL, e.Err, e.Attempts)
+	}
+	return fmt.Sprintf("%s: %v", e.URL, e.Err)
+}
+
+func (e *VisitError) Unwrap() error {
+	return e.Err
+}
+
+// Visit visits url with c, which must come from Collector. Timeouts, dropped
+// connections, 5xx answers and 429 are retried up to Config.Retries times,
+// waiting an exponentially growing, jittered delay in between. A Retry-After
+// header is honoured up to Config.RetryMaxDelay. Any other error is returned
+// at once. When ctx is done the next attempt, or the wait before it, is
+// given up with the Stopped error; a request already sent is finished.
+func (p *Pool) Visit(ctx context.Context, c *colly.Collector, url string) error {
+	var status int
+	var retryAfter string
+	c.OnError(func(r *colly.Response, _ error) {
+		status = r.StatusCode
+		retryAfter = ""
+		if r.Headers != nil {
+			retryAfter = r.Headers.Get("Retry-After")
+		}
+	})
+	for attempt := 1; ; attempt++ {
+		if ctx.Err() != nil {
+			return p.Stopped(ctx)
+		}
+		status = 0
+		err := c.Visit(url)
+		if err == nil {
+			return nil
+		}
+		if attempt > p.cfg.Retries || !transient(status, err) {
+			return &VisitError{URL: url, Status: status, Attempts: attempt, Err: err}
+		}
+		wait := p.backoff(attempt)
+		if d, ok := parseRetryAfter(retryAfter); ok {
+			wait = min(max(d, wait), p.cfg.RetryMaxDelay)
+		}
+		p.log.Info("retrying", "url", url, "in", wait.Round(time.Millisecond), "attempt", attempt, "err", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return p.Stopped(ctx)
+		}
+	}
+}
+
+// backoff is the wait before retry number attempt: RetryBaseDelay doubled
+// for every earlier retry and capped at RetryMaxDelay, of which a random
+// half is taken off so workers that failed together don't retry together.
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.cfg.RetryBaseDelay << (attempt - 1)
+	if d <= 0 || d > p.cfg.RetryMaxDelay {
+		d = p.cfg.RetryMaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// transient reports whether a failed request is worth trying again.
+func transient(status int, err error) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case 0:
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout() ||
+			errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header, either in seconds or as an
+// HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
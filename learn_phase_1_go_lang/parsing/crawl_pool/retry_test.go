@@ -0,0 +1,105 @@
+package crawl_pool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func retryPool(t *testing.T, retries int) *Pool {
+	pool, err := New(Config{Retries: retries, RetryBaseDelay: time.Millisecond, RetryMaxDelay: 5 * time.Millisecond})
+	assert.NoError(t, err)
+	return pool
+}
+
+func TestVisit_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			// Asks for an hour, the pool caps it at RetryMaxDelay
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("<html>ok</html>"))
+		}
+	}))
+	defer srv.Close()
+
+	pool := retryPool(t, 3)
+	start := time.Now()
+	assert.NoError(t, pool.Visit(context.Background(), pool.Collector(), srv.URL))
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestVisit_GivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	pool := retryPool(t, 2)
+
+	err := pool.Visit(context.Background(), pool.Collector(), srv.URL+"/down")
+	var visitErr *VisitError
+	assert.True(t, errors.As(err, &visitErr))
+	assert.Equal(t, http.StatusBadGateway, visitErr.Status)
+	assert.Equal(t, 3, visitErr.Attempts)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// A 404 won't get better by asking again
+	err = pool.Visit(context.Background(), pool.Collector(), srv.URL+"/missing")
+	assert.True(t, errors.As(err, &visitErr))
+	assert.Equal(t, http.StatusNotFound, visitErr.Status)
+	assert.Equal(t, 1, visitErr.Attempts)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestVisit_RetriesRefusedConnection(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	pool := retryPool(t, 1)
+	err := pool.Visit(context.Background(), pool.Collector(), url)
+	var visitErr *VisitError
+	assert.True(t, errors.As(err, &visitErr))
+	assert.Equal(t, 0, visitErr.Status)
+	assert.Equal(t, 2, visitErr.Attempts)
+}
+
+func TestBackoff(t *testing.T) {
+	pool, _ := New(Config{RetryBaseDelay: 100 * time.Millisecond, RetryMaxDelay: time.Second})
+	for attempt, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second, 80: time.Second} {
+		d := pool.backoff(attempt)
+		assert.GreaterOrEqual(t, d, limit/2, "attempt %d", attempt)
+		assert.LessOrEqual(t, d, limit, "attempt %d", attempt)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, d, float64(2*time.Second))
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
@@ -0,0 +1,311 @@
+package crawl_pool
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// slowestPages is how many of the slowest requests the report keeps.
+const slowestPages = 10
+
+// Stats counts what a pool fetched, for the progress lines, run.json and
+// /metrics. It is safe for concurrent use.
+type Stats struct {
+	mu       sync.Mutex
+	started  time.Time
+	stages   []*stageStats
+	statuses map[int]int // by HTTP status, 0 when there was no answer
+	requests int
+	bytes    int64
+	cached   int          // answers served by the HTTP cache
+	slowest  []PageTiming // slowest first
+}
+
+type stageStats struct {
+	name             string
+	queued           int
+	done, failed     int
+	requests         int
+	bytes            int64
+	started, ended   time.Time
+	lastProgress     time.Time
+	progressInterval time.Duration
+}
+
+func newStats() *Stats {
+	return &Stats{started: time.Now(), statuses: map[int]int{}}
+}
+
+// current is the stage requests are counted in, nil before the first one.
+func (s *Stats) current() *stageStats {
+	if len(s.stages) == 0 {
+		return nil
+	}
+	return s.stages[len(s.stages)-1]
+}
+
+// request records one HTTP request, retries count as requests of their own.
+func (s *Stats) request(url string, status int, bytes int64, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.bytes += bytes
+	s.statuses[status]++
+	page := PageTiming{URL: url, Status: status, Bytes: bytes, Milliseconds: took.Milliseconds()}
+	if st := s.current(); st != nil {
+		st.requests++
+		st.bytes += bytes
+		page.Stage = st.name
+	}
+	// After the ones as slow, so ties keep the order they came in
+	i, _ := slices.BinarySearchFunc(s.slowest, page, func(a, b PageTiming) int {
+		if a.Milliseconds >= b.Milliseconds {
+			return -1
+		}
+		return 1
+	})
+	if i < slowestPages {
+		s.slowest = slices.Insert(s.slowest, i, page)
+		s.slowest = s.slowest[:min(len(s.slowest), slowestPages)]
+	}
+}
+
+// cacheHit records a request the HTTP cache answered, it never reached the
+// site.
+func (s *Stats) cacheHit() {
+	s.mu.Lock()
+	s.cached++
+	s.mu.Unlock()
+}
+
+// meter is the HTTP transport of the pool. It times every request until
+// its body is closed and counts the bytes read.
+type meter struct {
+	next  http.RoundTripper
+	stats *Stats
+}
+
+func (m *meter) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := m.next.RoundTrip(req)
+	if err != nil {
+		m.stats.request(req.URL.String(), 0, 0, time.Since(start))
+		return nil, err
+	}
+	resp.Body = &meteredBody{ReadCloser: resp.Body, done: func(n int64) {
+		m.stats.request(req.URL.String(), resp.StatusCode, n, time.Since(start))
+	}}
+	return resp, nil
+}
+
+type meteredBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(n int64)
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	b.once.Do(func() { b.done(b.n) })
+	return b.ReadCloser.Close()
+}
+
+// Stage is one phase of a crawl, e.g. the list or the meal pages, with the
+// number of pages it has to do.
+type Stage struct {
+	pool *Pool
+	s    *stageStats
+}
+
+// StartStage starts counting the pages of a stage. Requests count towards
+// the latest stage started.
+func (p *Pool) StartStage(name string, pages int) *Stage {
+	st := &stageStats{name: name, queued: pages, started: time.Now(), progressInterval: p.cfg.ProgressInterval}
+	st.lastProgress = st.started
+	p.stats.mu.Lock()
+	p.stats.stages = append(p.stats.stages, st)
+	p.stats.mu.Unlock()
+	p.log.Info("stage started", "stage", name, "pages", pages)
+	return &Stage{pool: p, s: st}
+}
+
+// Done counts a page of the stage that succeeded.
+func (s *Stage) Done() {
+	s.count(func(st *stageStats) { st.done++ })
+}
+
+// Failed counts a page of the stage that failed.
+func (s *Stage) Failed() {
+	s.count(func(st *stageStats) { st.failed++ })
+}
+
+// count updates the stage and logs its progress every
+// Config.ProgressInterval.
+func (s *Stage) count(update func(st *stageStats)) {
+	stats := s.pool.stats
+	stats.mu.Lock()
+	update(s.s)
+	now := time.Now()
+	var report StageReport
+	due := s.s.progressInterval > 0 && now.Sub(s.s.lastProgress) >= s.s.progressInterval
+	if due {
+		s.s.lastProgress = now
+		report = s.s.report(now)
+	}
+	stats.mu.Unlock()
+	if due {
+		s.pool.log.Info("progress", report.attrs()...)
+	}
+}
+
+// End stops the clock of the stage and logs its totals.
+func (s *Stage) End() {
+	stats := s.pool.stats
+	stats.mu.Lock()
+	s.s.ended = time.Now()
+	report := s.s.report(s.s.ended)
+	stats.mu.Unlock()
+	s.pool.log.Info("stage finished", report.attrs()...)
+}
+
+// StageReport is a stage in run.json.
+type StageReport struct {
+	Name           string    `json:"name"`
+	Queued         int       `json:"queued"`
+	Done           int       `json:"done"`
+	Failed         int       `json:"failed"`
+	Requests       int       `json:"requests"`
+	Bytes          int64     `json:"bytes"`
+	StartedAt      time.Time `json:"started_at"`
+	Seconds        float64   `json:"seconds"`
+	PagesPerSecond float64   `json:"pages_per_second"`
+	// ETASeconds is how long the pages left should take at the current
+	// pace, only while the stage runs
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+}
+
+func (st *stageStats) report(now time.Time) StageReport {
+	r := StageReport{
+		Name: st.name, Queued: st.queued, Done: st.done, Failed: st.failed,
+		Requests: st.requests, Bytes: st.bytes, StartedAt: st.started,
+	}
+	end := now
+	if !st.ended.IsZero() {
+		end = st.ended
+	}
+	elapsed := end.Sub(st.started).Seconds()
+	r.Seconds = round(elapsed)
+	finished := st.done + st.failed
+	if elapsed > 0 {
+		r.PagesPerSecond = round(float64(finished) / elapsed)
+	}
+	if left := st.queued - finished; st.ended.IsZero() && left > 0 && finished > 0 {
+		r.ETASeconds = round(float64(left) * elapsed / float64(finished))
+	}
+	return r
+}
+
+func (r StageReport) attrs() []any {
+	attrs := []any{"stage", r.Name, "queued", r.Queued, "done", r.Done, "failed", r.Failed,
+		"bytes", r.Bytes, "pages_per_second", r.PagesPerSecond, "seconds", r.Seconds}
+	if r.ETASeconds > 0 {
+		attrs = append(attrs, "eta_seconds", r.ETASeconds)
+	}
+	return attrs
+}
+
+// round keeps two decimals, enough for seconds and rates.
+func round(v float64) float64 {
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// PageTiming is one of the slowest requests in run.json.
+type PageTiming struct {
+	URL          string `json:"url"`
+	Stage        string `json:"stage,omitempty"`
+	Status       int    `json:"status"` // 0 when there was no answer
+	Bytes        int64  `json:"bytes"`
+	Milliseconds int64  `json:"ms"`
+}
+
+// Report is what run.json holds about a crawl.
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Seconds    float64   `json:"seconds"`
+	Done       int       `json:"done"`
+	Failed     int       `json:"failed"`
+	Requests   int       `json:"requests"`
+	Bytes      int64     `json:"bytes"`
+	// CacheHits counts the requests served from the HTTP cache, they are
+	// not in Requests
+	CacheHits int           `json:"cache_hits"`
+	Stages    []StageReport `json:"stages"`
+	// Statuses counts the answers by HTTP status, "error" for requests
+	// that got none
+	Statuses map[string]int `json:"http_statuses"`
+	Slowest  []PageTiming   `json:"slowest_pages"`
+	// Stopped is why the crawl was stopped before it was done, see
+	// ErrStopped
+	Stopped string `json:"stopped,omitempty"`
+}
+
+// Report sums up the pool so far.
+func (s *Stats) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	r := Report{
+		StartedAt:  s.started,
+		FinishedAt: now,
+		Seconds:    round(now.Sub(s.started).Seconds()),
+		Requests:   s.requests,
+		Bytes:      s.bytes,
+		CacheHits:  s.cached,
+		Stages:     []StageReport{},
+		Statuses:   map[string]int{},
+		Slowest:    slices.Clone(s.slowest),
+	}
+	for _, st := range s.stages {
+		sr := st.report(now)
+		r.Stages = append(r.Stages, sr)
+		r.Done += sr.Done
+		r.Failed += sr.Failed
+	}
+	for status, n := range s.statuses {
+		key := strconv.Itoa(status)
+		if status == 0 {
+			key = "error"
+		}
+		r.Statuses[key] = n
+	}
+	if r.Slowest == nil {
+		r.Slowest = []PageTiming{}
+	}
+	return r
+}
+
+// Save writes the report as indented JSON, creating the directory first.
+func (r Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
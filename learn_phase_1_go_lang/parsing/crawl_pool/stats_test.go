@@ -0,0 +1,86 @@
+package crawl_pool
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Write([]byte("<html>hello</html>"))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	pool, err := New(Config{Parallelism: 1, Logger: slog.New(slog.NewTextHandler(&logs, nil)), ProgressInterval: time.Nanosecond})
+	assert.NoError(t, err)
+
+	stage := pool.StartStage("list", 3)
+	for _, path := range []string{"/a", "/slow", "/missing"} {
+		if err := pool.Visit(context.Background(), pool.Collector(), server.URL+path); err != nil {
+			stage.Failed()
+		} else {
+			stage.Done()
+		}
+	}
+	stage.End()
+
+	r := pool.Stats().Report()
+	assert.Equal(t, 2, r.Done)
+	assert.Equal(t, 1, r.Failed)
+	assert.Equal(t, 3, r.Requests)
+	assert.Equal(t, map[string]int{"200": 2, "404": 1}, r.Statuses)
+	assert.Equal(t, int64(2*len("<html>hello</html>")+len("404 page not found\n")), r.Bytes)
+	assert.Len(t, r.Stages, 1)
+	assert.Equal(t, "list", r.Stages[0].Name)
+	assert.Equal(t, 3, r.Stages[0].Requests)
+	assert.Zero(t, r.Stages[0].ETASeconds)
+	assert.Equal(t, server.URL+"/slow", r.Slowest[0].URL)
+	assert.Equal(t, "list", r.Slowest[0].Stage)
+	assert.GreaterOrEqual(t, r.Slowest[0].Milliseconds, int64(20))
+
+	assert.Contains(t, logs.String(), `msg="stage started" stage=list pages=3`)
+	assert.Contains(t, logs.String(), `msg=progress stage=list queued=3 done=1 failed=0`)
+	assert.Contains(t, logs.String(), `msg="stage finished" stage=list queued=3 done=2 failed=1`)
+
+	rec := httptest.NewRecorder()
+	pool.Stats().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	metrics := rec.Body.String()
+	for _, line := range []string{
+		"crawl_requests_total 3",
+		`crawl_responses_total{status="404"} 1`,
+		`crawl_stage_pages{stage="list"} 3`,
+		`crawl_stage_pages_done_total{stage="list",result="ok"} 2`,
+		`crawl_stage_pages_done_total{stage="list",result="failed"} 1`,
+	} {
+		assert.Contains(t, strings.Split(metrics, "\n"), line)
+	}
+}
+
+func TestStageReport_ETA(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	st := &stageStats{name: "details", queued: 100, done: 20, failed: 5, started: start}
+	r := st.report(start.Add(50 * time.Second))
+	assert.Equal(t, 0.5, r.PagesPerSecond)
+	assert.Equal(t, 150.0, r.ETASeconds)
+
+	st.ended = start.Add(60 * time.Second)
+	r = st.report(start.Add(time.Hour))
+	assert.Equal(t, 60.0, r.Seconds)
+	assert.Zero(t, r.ETASeconds)
+}
@@ -1,131 +1,445 @@
 package detail_parse
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go_lang/parsing/checkpoint"
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/failures"
+	"go_lang/parsing/image_mirror"
+	"go_lang/parsing/ingredient_parse"
 	"go_lang/parsing/main_parse"
+	"go_lang/parsing/meal_model"
+	"go_lang/parsing/step_parse"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 )
 
-type MealDetail struct {
-	Receipt     string       `json:"receipt"`
-	Flag        string       `json:"flag"`
-	Ingredents  []Ingredient `json:"ingredents"`
-	ReceiptName string       `json:"receipt_name"`
-}
-type Ingredient struct {
-	ImageURL string `json:"image_url"`
-	Caption  string `json:"caption"`
+// The meal detail types live in meal_model, so extractors can produce them.
+type (
+	MealDetail = meal_model.MealDetail
+	CrawlInfo  = meal_model.CrawlInfo
+	Ingredient = meal_model.Ingredient
+)
+
+// journalFile records every finished meal page while the crawl runs, so a
+// restarted run can skip them. Once the crawl is done it becomes the crawl
+// state for the next incremental run.
+const journalFile = "crawl_state.journal.jsonl"
+
+// now is the clock of CrawlInfo.FetchedAt, tests replace it.
+var now = time.Now
+
+// DetailSink receives the meal details in meal list order while they are
+// crawled.
+type DetailSink interface {
+	WriteDetail(MealDetail) error
+	Close() error
 }
 
-var fullDetailMeal []MealDetail
+// Options tweaks how DetailParse fetches the meal pages.
+type Options struct {
+	// Incremental only downloads meals that are new since the previous run
+	// and revalidates the known ones with conditional requests. It writes a
+	// change report to changes.json.
+	Incremental bool
+	// Dir holds the journal, the crawl state and the change report,
+	// "output" when empty.
+	Dir string
+	// Failures, if set, gets the meal pages that could not be loaded or had
+	// no recipe in them, and forgets the ones that worked.
+	Failures *failures.Manifest
+	// Retry, if set, only fetches the meals whose href is in it. Every other
+	// meal is taken over from the crawl state of the last run.
+	Retry map[string]bool
+	// Images, if set, gets a copy of the ingredient images, the flag and the
+	// thumbnail of every meal, and the details get their local paths.
+	Images *image_mirror.Store
+	// Extractor reads the meal pages, extractor.TheMealDB when nil.
+	Extractor extractor.Extractor
+}
 
-func DetailParse() {
-	file, _ := os.ReadFile("output/items.json")
-	var loadedMeals []main_parse.Meal
-	_ = json.Unmarshal(file, &loadedMeals)
-	fmt.Printf("Loaded %d meals from output/items.json\n", len(loadedMeals))
-	for _, meal := range loadedMeals {
-		parseMeal(meal)
+func (o Options) path(file string) string {
+	if o.Dir == "" {
+		return filepath.Join("output", file)
 	}
-	saveFinalMeals(fullDetailMeal)
+	return filepath.Join(o.Dir, file)
+}
+
+// mealResult is what a pool worker hands back for one meal.
+type mealResult struct {
+	Page    PageState
+	Failed  bool
+	Skipped bool // not retried and not in the last crawl state either
+	Stopped bool // not fetched, the crawl was stopped
 }
 
-func parseMeal(meal main_parse.Meal) {
-	c := colly.NewCollector()
+// errNoRecipe is returned for a page that loaded fine but in which the
+// extractor found no recipe, on themealdb one without Instructions.
+var errNoRecipe = errors.New("no recipe found on the page")
+
+// DetailParse crawls the detail page of every meal and writes the details to
+// sink as soon as all earlier meals are done, so nothing is buffered. Meals
+// whose page fails to load are skipped and counted in the returned error.
+//
+// When the crawl is stopped, see crawl_pool.Stopped, the details done so far
+// are still written and the error wraps crawl_pool.ErrStopped. The journal
+// is kept then, so the next run resumes where this one stopped.
+func DetailParse(ctx context.Context, pool *crawl_pool.Pool, meals []main_parse.Meal, sink DetailSink, opts Options) error {
+	ex := opts.Extractor
+	if ex == nil {
+		ex = extractor.TheMealDB{}
+	}
 
-	c.OnHTML("section#feature", func(e *colly.HTMLElement) {
-		html, _ := e.DOM.Html()
-		start := strings.Index(html, "Instructions")
-		end := strings.Index(html, "Browse More")
-		if start == -1 || end == -1 || end <= start {
-			fmt.Println("Could not find Instructions or Browse More section")
+	// A full crawl reads the previous state too, the pages that fail keep
+	// theirs in the next one
+	journalPath := opts.path(journalFile)
+	revalidate := opts.Incremental || opts.Retry != nil
+	previous, err := loadCrawlState(opts.path(stateFile))
+	if err != nil && revalidate {
+		sink.Close()
+		return err
+	} else if err != nil {
+		pool.Log().Warn("previous crawl state ignored", "err", err)
+		previous = map[string]PageState{}
+	}
+	if opts.Retry != nil && len(previous) == 0 {
+		sink.Close()
+		return fmt.Errorf("nothing to retry from, %s is missing: run a full crawl first", opts.path(stateFile))
+	}
+	journal, err := checkpoint.Open[PageState](journalPath)
+	if err != nil {
+		sink.Close()
+		return fmt.Errorf("open checkpoint journal: %w", err)
+	}
+	if journal.Len() > 0 {
+		pool.Log().Info("resuming crawl", "done", journal.Len(), "journal", journalPath)
+	}
+	stage := pool.StartStage("details", len(meals))
+	hashes := make(map[string]string, len(meals))
+	var sinkErr, stopErr error
+	failed := 0
+	mapErr := crawl_pool.Map(ctx, pool, len(meals), func(i int) mealResult {
+		meal := meals[i]
+		retry := opts.Retry[meal.Href]
+		if page, ok := journal.Done(meal.Href); ok && !retry {
+			mirrorImages(ctx, pool, opts.Images, &page)
+			return mealResult{Page: page}
+		}
+		if opts.Retry != nil && !retry {
+			page, ok := previous[meal.Href]
+			if !ok {
+				return mealResult{Skipped: true}
+			}
+			mirrorImages(ctx, pool, opts.Images, &page)
+			// Copy it over, the journal becomes the next crawl state
+			if err := journal.Append(meal.Href, page); err != nil {
+				pool.Log().Error("writing checkpoint journal failed", "url", meal.Href, "err", err)
+			}
+			return mealResult{Page: page}
+		}
+		prev, hasPrev := previous[meal.Href]
+		var known *PageState
+		if hasPrev && revalidate {
+			known = &prev
+		}
+		if err := pool.TakePage(ctx); err != nil {
+			return mealResult{Stopped: true}
+		}
+		page, err := parseMeal(ctx, pool, ex, meal, known)
+		if errors.Is(err, crawl_pool.ErrStopped) {
+			return mealResult{Stopped: true}
+		} else if err != nil {
+			pool.Log().Warn("meal page failed", "url", meal.Href, "err", err)
+			if opts.Failures != nil {
+				reason := failures.Fetch
+				if errors.Is(err, errNoRecipe) {
+					reason = failures.Parse
+				}
+				f := failures.New(failures.Details, reason, meal.Href, err)
+				f.Name = meal.Name
+				opts.Failures.Add(f)
+			}
+			if !hasPrev {
+				return mealResult{Failed: true}
+			}
+			if known == nil {
+				// The crawl state keeps the last copy, the output goes without it
+				if err := journal.Append(meal.Href, prev); err != nil {
+					pool.Log().Error("writing checkpoint journal failed", "url", meal.Href, "err", err)
+				}
+				return mealResult{Failed: true}
+			}
+			// Better to keep last week's copy than to drop the meal
+			page = *known
+		} else if opts.Failures != nil {
+			opts.Failures.Remove(failures.Details, meal.Href)
+		}
+		mirrorImages(ctx, pool, opts.Images, &page)
+		if err := journal.Append(meal.Href, page); err != nil {
+			pool.Log().Error("writing checkpoint journal failed", "url", meal.Href, "err", err)
+		}
+		return mealResult{Page: page}
+	}, func(i int, res mealResult) {
+		switch {
+		case res.Stopped:
+			if stopErr == nil {
+				stopErr = pool.Stopped(ctx)
+			}
 			return
+		case res.Failed:
+			failed++
+			stage.Failed()
+		default:
+			stage.Done()
+		}
+		if res.Failed || res.Skipped || sinkErr != nil {
+			return
+		}
+		hashes[meals[i].Href] = res.Page.Hash
+		for _, detail := range res.Page.Details {
+			if sinkErr = sink.WriteDetail(detail); sinkErr != nil {
+				return
+			}
+		}
+	})
+	stage.End()
+	stopErr = cmp.Or(stopErr, mapErr)
+
+	if err := errors.Join(sinkErr, sink.Close()); err != nil {
+		// Keep the journal so the next run can still resume
+		journal.Close()
+		return fmt.Errorf("write meal details: %w", err)
+	}
+	if stopErr != nil {
+		journal.Close()
+		pool.Log().Info("journal kept to resume from", "journal", journalPath)
+		return fmt.Errorf("meal details are partial: %w", stopErr)
+	}
+	if opts.Incremental {
+		report := buildChangeReport(meals, hashes, previous)
+		changesPath := opts.path(changesFile)
+		if err := writeJSON(changesPath, report); err != nil {
+			pool.Log().Error("writing change report failed", "report", changesPath, "err", err)
+		} else {
+			pool.Log().Info("changes", "added", len(report.Added), "removed", len(report.Removed),
+				"modified", len(report.Modified), "unchanged", report.Unchanged, "report", changesPath)
 		}
-		start += len("Instructions")
-		instructions := html[start:end]
-		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(instructions))
+	}
+	if err := journal.Rename(opts.path(stateFile)); err != nil {
+		return fmt.Errorf("write crawl state: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d meal pages failed", failed, len(meals))
+	}
+	return nil
+}
 
-		text := strings.TrimSpace(doc.Text())
+// parseMeal visits a single meal page with its own collector, so it is safe
+// to run from several pool workers at once. Pages that failed to load return
+// an error and are not recorded in the journal, so a re-run tries them again.
+//
+// When known is set the request is conditional: a 304 answer returns known
+// unchanged, with the crawl info of the response it was first read from.
+// Anything else is parsed again and re-hashed.
+func parseMeal(ctx context.Context, pool *crawl_pool.Pool, ex extractor.Extractor, meal main_parse.Meal, known *PageState) (PageState, error) {
+	var details []MealDetail
+	var etag, lastModified, sourceURL, canonical string
+	var status int
+	var fetchedAt time.Time
+	notModified := false
+	c := pool.Collector()
 
-		flagURL := ""
-		e.DOM.Find("img[src*='/images/icons/flags/big/64/']").Each(func(_ int, s *goquery.Selection) {
-			src, exists := s.Attr("src")
-			if exists && strings.Contains(src, "/images/icons/flags/big/64/") {
-				flagURL = src
+	if known != nil {
+		c.OnRequest(func(r *colly.Request) {
+			if known.ETag != "" {
+				r.Headers.Set("If-None-Match", known.ETag)
 			}
-		})
-		// Extract country code from flagURL, e.g. "/images/icons/flags/big/64/gb.png" -> "gb"
-		flagCode := getFlag(flagURL)
-		// Parse ingredients: extract src and figcaption from each <figure>
-
-		var ingredients []Ingredient
-		// Use the full section DOM, not just the instructions fragment
-		e.DOM.Find("figure").Each(func(_ int, s *goquery.Selection) {
-			img := s.Find("img")
-			src, _ := img.Attr("src")
-			caption := s.Find("figcaption").Text()
-			ingredients = append(ingredients, Ingredient{
-				ImageURL: strings.TrimSpace(src),
-				Caption:  strings.TrimSpace(caption),
-			})
-		})
-		receiptName := ""
-		//	<meta property="og:title" content="Apple Frangipan Tart Recipe">
-		e.DOM.Closest("html").Find("meta[property='og:title']").Each(func(_ int, meta *goquery.Selection) {
-			content, exists := meta.Attr("content")
-			if exists {
-				receiptName = strings.TrimSpace(content)
+			if known.LastModified != "" {
+				r.Headers.Set("If-Modified-Since", known.LastModified)
 			}
 		})
-		finalResult := MealDetail{
-			Receipt:     text,
-			Flag:        flagCode,
-			Ingredents:  ingredients,
-			ReceiptName: receiptName,
-		}
-		fullDetailMeal = append(fullDetailMeal, finalResult)
+	}
+	c.OnResponse(func(r *colly.Response) {
+		etag = r.Headers.Get("ETag")
+		lastModified = r.Headers.Get("Last-Modified")
+		// After redirects this is where the page really lives
+		sourceURL = r.Request.URL.String()
+		status = r.StatusCode
+		fetchedAt = now().UTC()
+	})
+	c.OnError(func(r *colly.Response, _ error) {
+		notModified = r.StatusCode == http.StatusNotModified
 	})
-	err := c.Visit(meal.Href)
+
+	c.OnHTML("link[rel='canonical']", func(e *colly.HTMLElement) {
+		canonical = e.Request.AbsoluteURL(e.Attr("href"))
+	})
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		details = append(details, ex.ExtractDetails(extractor.FromColly(e))...)
+	})
+	err := pool.Visit(ctx, c, meal.Href)
+	if notModified && known != nil {
+		return *known, nil
+	}
 	if err != nil {
-		fmt.Println("Error visiting page:", err)
+		return PageState{}, err
+	}
+	if len(details) == 0 {
+		return PageState{}, fmt.Errorf("%s: %w", meal.Href, errNoRecipe)
 	}
+	if canonical != "" {
+		sourceURL = canonical
+	}
+	id := meal.ID()
+	if id == 0 {
+		id = main_parse.Meal{Href: sourceURL}.ID()
+	}
+	for i := range details {
+		details[i].ID = id
+		details[i].SourceURL = sourceURL
+		details[i].Crawl = &CrawlInfo{
+			FetchedAt:   fetchedAt,
+			HTTPStatus:  status,
+			ContentHash: hashDetails(details[i : i+1]),
+		}
+	}
+	return PageState{
+		Name:         meal.Name,
+		ETag:         etag,
+		LastModified: lastModified,
+		Hash:         hashDetails(details),
+		Details:      details,
+	}, nil
 }
 
-func saveFinalMeals(finalResult []MealDetail) bool {
-	jsonData, err := json.MarshalIndent(finalResult, "", "  ")
+// writeJSON writes v as indented JSON, creating the parent directory first.
+func writeJSON(path string, v any) error {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		fmt.Println("Error marshaling to JSON:", err)
-		return true
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
-	// Create output directory if it doesn't exist
-	err = os.MkdirAll("output", 0755)
+	return os.WriteFile(path, jsonData, 0644)
+}
+
+// LoadFinalMeals reads meal details written by the json or jsonl sink.
+// Ingredients saved before captions were parsed get their parsed fields
+// filled in on the fly, and so do the steps of instructions saved before
+// they were split. Relative image URLs of older crawls are resolved against
+// the meal page.
+func LoadFinalMeals(path string) ([]MealDetail, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Println("Error creating output directory:", err)
-		return true
+		return nil, err
+	}
+	var meals []MealDetail
+	if strings.HasSuffix(path, ".jsonl") {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var meal MealDetail
+			if err := json.Unmarshal([]byte(line), &meal); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+			meals = append(meals, meal)
+		}
+	} else if err := json.Unmarshal(data, &meals); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range meals {
+		if meals[i].Steps == nil {
+			meals[i].Steps = step_parse.Parse(meals[i].Receipt)
+		}
+		page := cmp.Or(meals[i].SourceURL, main_parse.DefaultBaseURL+"/meal/")
+		for j, ing := range meals[i].Ingredents {
+			if ing.Name == "" && ing.Caption != "" {
+				meals[i].Ingredents[j].Parsed = ingredient_parse.Parse(ing.Caption)
+			}
+			meals[i].Ingredents[j].ImageURL = resolveURL(page, ing.ImageURL)
+		}
 	}
+	return meals, nil
+}
 
-	// Write JSON to output/items.json
-	err = os.WriteFile("output/final_items.json", jsonData, 0644)
+// resolveURL resolves ref against the page it was found on, e.g.
+// "../images/ingredients/Butter.png" on /meal/52768 becomes
+// /images/ingredients/Butter.png. Absolute and empty refs are kept.
+func resolveURL(page, ref string) string {
+	base, err := url.Parse(page)
+	if err != nil || ref == "" {
+		return ref
+	}
+	u, err := url.Parse(ref)
 	if err != nil {
-		fmt.Println("Error writing JSON file:", err)
-		return true
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// mirrorImages stores the images of page in store and records their local
+// paths. A failed download is only logged, the remote URLs still work.
+// Once the crawl is stopped the images left keep their remote URLs only.
+// The paths go into copies of the details: the page may share them with
+// the journal or the previous crawl state.
+func mirrorImages(ctx context.Context, pool *crawl_pool.Pool, store *image_mirror.Store, page *PageState) {
+	if store == nil {
+		return
+	}
+	page.Details = slices.Clone(page.Details)
+	for i := range page.Details {
+		page.Details[i].Ingredents = slices.Clone(page.Details[i].Ingredents)
+	}
+	fetch := func(remote string, local *string) {
+		path, err := store.Fetch(ctx, remote)
+		if errors.Is(err, crawl_pool.ErrStopped) {
+			return
+		} else if err != nil {
+			pool.Log().Warn("mirroring image failed", "url", remote, "err", err)
+			return
+		}
+		*local = path
+	}
+	for i := range page.Details {
+		d := &page.Details[i]
+		fetch(d.FlagURL, &d.FlagPath)
+		fetch(d.Thumbnail, &d.ThumbnailPath)
+		for j := range d.Ingredents {
+			fetch(d.Ingredents[j].ImageURL, &d.Ingredents[j].ImagePath)
+		}
 	}
-	return false
 }
-func getFlag(flagURL string) string {
-	if flagURL != "" {
-		parts := strings.Split(flagURL, "/")
-		if len(parts) > 0 {
-			filename := parts[len(parts)-1]
-			if strings.HasSuffix(filename, ".png") {
-				flagURL = strings.TrimSuffix(filename, ".png")
+
+// FindMeal looks a meal up by name, ignoring case and the " Recipe" suffix,
+// or by its meal ID.
+func FindMeal(meals []MealDetail, name string) (MealDetail, bool) {
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(name), " Recipe"))
+	if id, err := strconv.Atoi(name); err == nil && id > 0 {
+		for _, meal := range meals {
+			if meal.ID == id {
+				return meal, true
 			}
 		}
 	}
-	return flagURL
+	for _, meal := range meals {
+		if strings.EqualFold(meal.DisplayName(), name) {
+			return meal, true
+		}
+	}
+	return MealDetail{}, false
 }
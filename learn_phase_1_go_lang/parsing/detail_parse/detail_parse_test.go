@@ -0,0 +1,433 @@
+package detail_parse
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/failures"
+	"go_lang/parsing/image_mirror"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/replay"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the fixtures")
+
+const goldenFinal = "../../testdata/golden/final_items.json"
+
+func replayPool(t *testing.T) *crawl_pool.Pool {
+	pool, err := crawl_pool.New(crawl_pool.Config{
+		Parallelism: 1,
+		Transport:   &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"},
+	})
+	assert.NoError(t, err)
+	return pool
+}
+
+// fixClock makes CrawlInfo.FetchedAt predictable for the golden files.
+func fixClock(t *testing.T) time.Time {
+	fixed := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	t.Cleanup(func() { now = time.Now })
+	return fixed
+}
+
+func TestParseMeal_Replay(t *testing.T) {
+	fixClock(t)
+	pool := replayPool(t)
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Apple & Blackberry Crumble", Href: "https://www.themealdb.com/meal/52893"},
+		{Name: "Apam balik", Href: "https://www.themealdb.com/meal/53049"},
+		{Name: "Bakewell tart", Href: "https://www.themealdb.com/meal/52767"},
+	}
+	var details []MealDetail
+	for _, meal := range meals {
+		page, err := parseMeal(context.Background(), pool, extractor.TheMealDB{}, meal, nil)
+		assert.NoError(t, err)
+		assert.Len(t, page.Details, 1)
+		details = append(details, page.Details...)
+	}
+
+	if *update {
+		assert.NoError(t, writeJSON(goldenFinal, details))
+	}
+	want, err := LoadFinalMeals(goldenFinal)
+	assert.NoError(t, err)
+	assert.Equal(t, want, details)
+}
+
+func TestParseMeal_ReplayHeaders(t *testing.T) {
+	fetched := fixClock(t)
+	page, err := parseMeal(context.Background(), replayPool(t), extractor.TheMealDB{}, main_parse.Meal{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `"52768-v1"`, page.ETag)
+	assert.Equal(t, "Sat, 17 Oct 2026 10:00:00 GMT", page.LastModified)
+	assert.Equal(t, hashDetails(page.Details), page.Hash)
+
+	detail := page.Details[0]
+	assert.Equal(t, 52768, detail.ID)
+	assert.Equal(t, "https://www.themealdb.com/meal/52768", detail.SourceURL)
+	assert.Equal(t, &CrawlInfo{FetchedAt: fetched, HTTPStatus: 200, ContentHash: page.Hash}, detail.Crawl)
+
+	// The canonical link wins over the URL the page was requested with
+	page, err = parseMeal(context.Background(), replayPool(t), extractor.TheMealDB{}, main_parse.Meal{Name: "Apam balik", Href: "https://www.themealdb.com/meal/53049"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 53049, page.Details[0].ID)
+	assert.Equal(t, "https://www.themealdb.com/meal/53049-Apam-balik-Recipe", page.Details[0].SourceURL)
+
+	_, err = parseMeal(context.Background(), replayPool(t), extractor.TheMealDB{}, main_parse.Meal{Name: "Missing", Href: "https://www.themealdb.com/meal/1"}, nil)
+	assert.Error(t, err)
+}
+
+func TestParseMeal_ReplaySource(t *testing.T) {
+	// The source of a recipe is linked from an info table or a label, or
+	// only written out
+	for href, source := range map[string]string{
+		"https://www.themealdb.com/meal/52893": "https://www.bbcgoodfood.com/recipes/778642/apple-and-blackberry-crumble",
+		"https://www.themealdb.com/meal/53049": "https://www.nyonyacooking.com/recipes/apam-balik~SJ5WuvsDf9WQ",
+		"https://www.themealdb.com/meal/52767": "https://www.bbcgoodfood.com/recipes/2375/bakewell-tart",
+		"https://www.themealdb.com/meal/52768": "",
+	} {
+		page, err := parseMeal(context.Background(), replayPool(t), extractor.TheMealDB{}, main_parse.Meal{Href: href}, nil)
+		if assert.NoError(t, err, href) && assert.Len(t, page.Details, 1, href) {
+			assert.Equal(t, source, page.Details[0].RecipeSource, href)
+		}
+	}
+}
+
+type memorySink struct {
+	details []MealDetail
+}
+
+func (s *memorySink) WriteDetail(d MealDetail) error {
+	s.details = append(s.details, d)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func TestDetailParse_Replay(t *testing.T) {
+	dir := t.TempDir()
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Missing", Href: "https://www.themealdb.com/meal/1"},
+		{Name: "Apam balik", Href: "https://www.themealdb.com/meal/53049"},
+	}
+	sink := &memorySink{}
+	err := DetailParse(context.Background(), replayPool(t), meals, sink, Options{Dir: dir})
+	assert.ErrorContains(t, err, "1 of 3 meal pages failed")
+	assert.Len(t, sink.details, 2)
+	assert.Equal(t, "Apam balik Recipe", sink.details[1].ReceiptName)
+
+	state, err := loadCrawlState(filepath.Join(dir, stateFile))
+	assert.NoError(t, err)
+	assert.Len(t, state, 2)
+	assert.NoFileExists(t, filepath.Join(dir, journalFile))
+
+	// Pages failing in the next full crawl keep their last state
+	offline, err := crawl_pool.New(crawl_pool.Config{Transport: &replay.Transport{Mode: replay.Replay, Dir: t.TempDir()}})
+	assert.NoError(t, err)
+	sink = &memorySink{}
+	err = DetailParse(context.Background(), offline, meals, sink, Options{Dir: dir})
+	assert.ErrorContains(t, err, "3 of 3 meal pages failed")
+	assert.Empty(t, sink.details)
+	again, err := loadCrawlState(filepath.Join(dir, stateFile))
+	assert.NoError(t, err)
+	assert.Equal(t, state, again)
+}
+
+func TestDetailParse_IncrementalRevalidates(t *testing.T) {
+	page, err := os.ReadFile("../../testdata/fixtures/www.themealdb.com/meal/52768.html")
+	assert.NoError(t, err)
+	var mu sync.Mutex
+	body, etag := string(page), `"v1"`
+	lastModified := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	var conditional []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		if inm := r.Header.Get("If-None-Match"); inm != "" && inm == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && r.Header.Get("If-None-Match") == "" && !lastModified.After(ims) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	pool, err := crawl_pool.New(crawl_pool.Config{})
+	assert.NoError(t, err)
+	meals := []main_parse.Meal{{Name: "Apple Frangipan Tart", Href: srv.URL + "/meal/52768"}}
+	crawl := func() (map[string]PageState, ChangeReport, []MealDetail) {
+		t.Helper()
+		sink := &memorySink{}
+		assert.NoError(t, DetailParse(context.Background(), pool, meals, sink, Options{Dir: dir, Incremental: true}))
+		state, err := loadCrawlState(filepath.Join(dir, stateFile))
+		assert.NoError(t, err)
+		var report ChangeReport
+		data, err := os.ReadFile(filepath.Join(dir, changesFile))
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, &report))
+		return state, report, sink.details
+	}
+
+	first, report, _ := crawl()
+	assert.Len(t, report.Added, 1)
+	assert.Equal(t, `"v1"`, first[meals[0].Href].ETag)
+
+	// 304: the known page and its hash are kept, and still written out
+	second, report, details := crawl()
+	assert.Equal(t, `"v1"`, conditional[len(conditional)-1])
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Empty(t, report.Modified)
+	assert.Equal(t, first[meals[0].Href].Details, details)
+
+	// 200 with a new body: the page is parsed again and marked as changed
+	mu.Lock()
+	body = strings.Replace(body, "Bake for 20-25 minutes", "Bake for 30 minutes", 1)
+	etag = `"v2"`
+	lastModified = lastModified.Add(24 * time.Hour)
+	mu.Unlock()
+	third, report, details := crawl()
+	assert.Equal(t, []main_parse.Meal{meals[0]}, report.Modified)
+	assert.Zero(t, report.Unchanged)
+	assert.Equal(t, `"v2"`, third[meals[0].Href].ETag)
+	assert.NotEqual(t, first[meals[0].Href].Hash, third[meals[0].Href].Hash)
+	assert.Contains(t, details[0].Receipt, "Bake for 30 minutes")
+
+	// Without an ETag the Last-Modified date is sent instead
+	mu.Lock()
+	etag = ""
+	mu.Unlock()
+	state := third[meals[0].Href]
+	state.ETag = ""
+	fourth, err := parseMeal(context.Background(), pool, extractor.TheMealDB{}, meals[0], &state)
+	assert.NoError(t, err)
+	assert.Equal(t, state, fourth)
+}
+
+func TestDetailParse_StoppedKeepsJournal(t *testing.T) {
+	dir := t.TempDir()
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Apam balik", Href: "https://www.themealdb.com/meal/53049"},
+	}
+	pool, err := crawl_pool.New(crawl_pool.Config{
+		Parallelism: 1,
+		MaxPages:    1,
+		Transport:   &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"},
+	})
+	assert.NoError(t, err)
+
+	sink := &memorySink{}
+	err = DetailParse(context.Background(), pool, meals, sink, Options{Dir: dir})
+	assert.ErrorIs(t, err, crawl_pool.ErrStopped)
+	assert.ErrorIs(t, err, crawl_pool.ErrPageBudget)
+	assert.Len(t, sink.details, 1)
+	assert.FileExists(t, filepath.Join(dir, journalFile))
+	assert.NoFileExists(t, filepath.Join(dir, stateFile))
+
+	// The next run only fetches what is left
+	sink = &memorySink{}
+	assert.NoError(t, DetailParse(context.Background(), replayPool(t), meals, sink, Options{Dir: dir}))
+	assert.Len(t, sink.details, 2)
+	assert.NoFileExists(t, filepath.Join(dir, journalFile))
+
+	// A cancelled crawl fetches nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink = &memorySink{}
+	err = DetailParse(ctx, replayPool(t), meals, sink, Options{Dir: t.TempDir()})
+	assert.ErrorIs(t, err, crawl_pool.ErrStopped)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, sink.details)
+}
+
+// countingTransport counts the requests per URL on top of the replay fixtures.
+type countingTransport struct {
+	mu    sync.Mutex
+	calls map[string]int
+	next  http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.calls[req.URL.String()]++
+	c.mu.Unlock()
+	return c.next.RoundTrip(req)
+}
+
+func TestDetailParse_FailuresAndRetry(t *testing.T) {
+	dir := t.TempDir()
+	counter := &countingTransport{
+		calls: map[string]int{},
+		next:  &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"},
+	}
+	pool, err := crawl_pool.New(crawl_pool.Config{Parallelism: 2, Transport: counter})
+	assert.NoError(t, err)
+	manifest, err := failures.Open(filepath.Join(dir, "failures.json"))
+	assert.NoError(t, err)
+
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Being updated", Href: "https://www.themealdb.com/meal/99999"},
+		{Name: "Missing", Href: "https://www.themealdb.com/meal/1"},
+	}
+	err = DetailParse(context.Background(), pool, meals, &memorySink{}, Options{Dir: dir, Failures: manifest})
+	assert.ErrorContains(t, err, "2 of 3 meal pages failed")
+
+	got := manifest.Failures(failures.Details)
+	assert.Len(t, got, 2)
+	byURL := map[string]failures.Failure{}
+	for _, f := range got {
+		byURL[f.URL] = f
+	}
+	assert.Equal(t, failures.Parse, byURL[meals[1].Href].Reason)
+	assert.Equal(t, "Being updated", byURL[meals[1].Href].Name)
+	assert.Equal(t, failures.Fetch, byURL[meals[2].Href].Reason)
+	assert.Equal(t, 1, byURL[meals[2].Href].Attempts)
+
+	// Retrying only fetches the failed pages, the tart comes from the crawl state
+	retry := map[string]bool{}
+	for _, f := range got {
+		retry[f.URL] = true
+	}
+	sink := &memorySink{}
+	err = DetailParse(context.Background(), pool, meals, sink, Options{Dir: dir, Failures: manifest, Retry: retry})
+	assert.ErrorContains(t, err, "2 of 3 meal pages failed")
+	assert.Len(t, sink.details, 1)
+	assert.Equal(t, "Apple Frangipan Tart Recipe", sink.details[0].ReceiptName)
+	assert.Equal(t, 1, counter.calls[meals[0].Href])
+	assert.Equal(t, 2, counter.calls[meals[1].Href])
+
+	state, err := loadCrawlState(filepath.Join(dir, stateFile))
+	assert.NoError(t, err)
+	assert.Len(t, state, 1)
+}
+
+// imageTransport answers every /images/ request with the image path as
+// body and leaves the meal pages to next.
+type imageTransport struct {
+	countingTransport
+}
+
+func (c *imageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, "/images/") {
+		return c.countingTransport.RoundTrip(req)
+	}
+	c.mu.Lock()
+	c.calls[req.URL.String()]++
+	c.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"image/png"}},
+		Body:       io.NopCloser(strings.NewReader(req.URL.Path)),
+		Request:    req,
+	}, nil
+}
+
+func TestDetailParse_MirrorImages(t *testing.T) {
+	dir := t.TempDir()
+	transport := &imageTransport{countingTransport{
+		calls: map[string]int{},
+		next:  &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"},
+	}}
+	pool, err := crawl_pool.New(crawl_pool.Config{Parallelism: 2, Transport: transport})
+	assert.NoError(t, err)
+	store, err := image_mirror.Open(pool, filepath.Join(dir, "images"))
+	assert.NoError(t, err)
+
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Apple & Blackberry Crumble", Href: "https://www.themealdb.com/meal/52893"},
+	}
+	sink := &memorySink{}
+	assert.NoError(t, DetailParse(context.Background(), pool, meals, sink, Options{Dir: dir, Images: store}))
+	assert.Len(t, sink.details, 2)
+
+	for _, d := range sink.details {
+		assert.Equal(t, "https://www.themealdb.com/images/icons/flags/big/64/gb.png", d.FlagURL)
+		assert.FileExists(t, filepath.Join(dir, d.FlagPath))
+		assert.FileExists(t, filepath.Join(dir, d.ThumbnailPath))
+		for _, ing := range d.Ingredents {
+			assert.Regexp(t, `^https://www\.themealdb\.com/images/ingredients/`, ing.ImageURL)
+			assert.Regexp(t, `^images/[0-9a-f]{64}\.png$`, ing.ImagePath)
+		}
+	}
+	// Both meals are British, the flag is downloaded once
+	assert.Equal(t, sink.details[0].FlagPath, sink.details[1].FlagPath)
+	assert.Equal(t, 1, transport.calls["https://www.themealdb.com/images/icons/flags/big/64/gb.png"])
+
+	// The paths go into the crawl state but not into the content hash
+	state, err := loadCrawlState(filepath.Join(dir, stateFile))
+	assert.NoError(t, err)
+	page := state[meals[0].Href]
+	assert.Equal(t, sink.details[0].FlagPath, page.Details[0].FlagPath)
+	assert.Equal(t, page.Hash, hashDetails(page.Details))
+
+	// A page shared with the journal or the previous state is left as it is
+	shared := PageState{Details: []MealDetail{{
+		Thumbnail:  "https://www.themealdb.com/images/media/meals/shared.jpg",
+		Ingredents: []Ingredient{{ImageURL: "https://www.themealdb.com/images/ingredients/Shared.png"}},
+	}}}
+	mirrored := shared
+	mirrorImages(context.Background(), pool, store, &mirrored)
+	assert.NotEmpty(t, mirrored.Details[0].ThumbnailPath)
+	assert.NotEmpty(t, mirrored.Details[0].Ingredents[0].ImagePath)
+	assert.Empty(t, shared.Details[0].ThumbnailPath)
+	assert.Empty(t, shared.Details[0].Ingredents[0].ImagePath)
+}
+
+func TestLoadFinalMeals_ResolvesImageURLs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "final_items.json")
+	old := `[{"receipt":"Bake it.","flag":"gb","receipt_name":"Bakewell tart Recipe",
+		"ingredents":[{"image_url":"../images/ingredients/butter-medium.png","caption":"75g butter"}]}]`
+	assert.NoError(t, os.WriteFile(path, []byte(old), 0644))
+	meals, err := LoadFinalMeals(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://www.themealdb.com/images/ingredients/butter-medium.png", meals[0].Ingredents[0].ImageURL)
+}
+
+func TestFindMeal(t *testing.T) {
+	meals := []MealDetail{
+		{ReceiptName: "Bakewell tart Recipe", ID: 52767},
+		{ReceiptName: "Tarte Tatin Recipe"},
+	}
+	meal, ok := FindMeal(meals, "bakewell TART")
+	assert.True(t, ok)
+	assert.Equal(t, 52767, meal.ID)
+	meal, ok = FindMeal(meals, "52767")
+	assert.True(t, ok)
+	assert.Equal(t, "Bakewell tart Recipe", meal.ReceiptName)
+	_, ok = FindMeal(meals, "Tarte Tatin Recipe")
+	assert.True(t, ok)
+	_, ok = FindMeal(meals, "0")
+	assert.False(t, ok)
+}
+
+func TestDetailParse_RetryNeedsState(t *testing.T) {
+	err := DetailParse(context.Background(), replayPool(t), nil, &memorySink{}, Options{Dir: t.TempDir(), Retry: map[string]bool{}})
+	assert.ErrorContains(t, err, "run a full crawl first")
+}
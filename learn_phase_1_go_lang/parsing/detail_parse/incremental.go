@@ -0,0 +1,106 @@
+package detail_parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go_lang/parsing/checkpoint"
+	"go_lang/parsing/main_parse"
+	"maps"
+	"slices"
+)
+
+const (
+	// stateFile remembers every crawled page between runs, so an incremental
+	// run knows what it already has and how to revalidate it. It is the
+	// checkpoint journal of the last finished run.
+	stateFile = "crawl_state.jsonl"
+	// changesFile is the change report of an incremental run.
+	changesFile = "changes.json"
+)
+
+// PageState is what one crawl learned about a meal page.
+type PageState struct {
+	Name         string       `json:"name"`
+	ETag         string       `json:"etag,omitempty"`
+	LastModified string       `json:"last_modified,omitempty"`
+	Hash         string       `json:"hash"` // sha256 of the extracted details
+	Details      []MealDetail `json:"details"`
+}
+
+// ChangeReport lists how the meal list differs from the previous run.
+type ChangeReport struct {
+	Added     []main_parse.Meal `json:"added"`
+	Removed   []main_parse.Meal `json:"removed"`
+	Modified  []main_parse.Meal `json:"modified"`
+	Unchanged int               `json:"unchanged"`
+}
+
+// hashDetails hashes the extracted content rather than the raw HTML, so
+// ads or other markup that changes on every request don't count as edits.
+// Where and when a page was fetched is not content, so ID, SourceURL and
+// Crawl are left out; that also keeps hashes from before they existed valid.
+// So are the paths of mirrored images and the catalog IDs, which depend on
+// the local run, and the steps, which only repeat Receipt.
+func hashDetails(details []MealDetail) string {
+	content := make([]MealDetail, len(details))
+	for i, d := range details {
+		d.ID, d.SourceURL, d.Crawl = 0, "", nil
+		d.FlagPath, d.ThumbnailPath = "", ""
+		d.Steps = nil
+		d.Ingredents = slices.Clone(d.Ingredents)
+		for j := range d.Ingredents {
+			d.Ingredents[j].ImagePath, d.Ingredents[j].IngredientID = "", ""
+		}
+		content[i] = d
+	}
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCrawlState returns the state of the previous run at path keyed by
+// href, or an empty map if there was none.
+func loadCrawlState(path string) (map[string]PageState, error) {
+	state, err := checkpoint.Load[PageState](path)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// buildChangeReport compares the freshly crawled pages with the previous
+// state. Meals are listed in items.json order; meals that failed to load are
+// left out since nothing is known about them.
+// fresh holds the new content hash of every meal that was crawled.
+func buildChangeReport(meals []main_parse.Meal, fresh map[string]string, previous map[string]PageState) ChangeReport {
+	report := ChangeReport{
+		Added:    []main_parse.Meal{},
+		Removed:  []main_parse.Meal{},
+		Modified: []main_parse.Meal{},
+	}
+	seen := make(map[string]bool, len(meals))
+	for _, meal := range meals {
+		seen[meal.Href] = true
+		hash, ok := fresh[meal.Href]
+		if !ok {
+			continue
+		}
+		prev, ok := previous[meal.Href]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, meal)
+		case prev.Hash != hash:
+			report.Modified = append(report.Modified, meal)
+		default:
+			report.Unchanged++
+		}
+	}
+	for _, href := range slices.Sorted(maps.Keys(previous)) {
+		if !seen[href] {
+			report.Removed = append(report.Removed, main_parse.Meal{Name: previous[href].Name, Href: href})
+		}
+	}
+	return report
+}
@@ -0,0 +1,52 @@
+package detail_parse
+
+import (
+	"go_lang/parsing/main_parse"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildChangeReport(t *testing.T) {
+	tart := []MealDetail{{ReceiptName: "Apple Frangipan Tart Recipe", Flag: "gb"}}
+	crumble := []MealDetail{{ReceiptName: "Apple & Blackberry Crumble Recipe", Flag: "gb"}}
+	crumbleEdited := []MealDetail{{ReceiptName: "Apple & Blackberry Crumble Recipe", Flag: "fr"}}
+
+	previous := map[string]PageState{
+		"https://www.themealdb.com/meal/52768": {Name: "Apple Frangipan Tart", Hash: hashDetails(tart)},
+		"https://www.themealdb.com/meal/52893": {Name: "Apple & Blackberry Crumble", Hash: hashDetails(crumble)},
+		"https://www.themealdb.com/meal/53049": {Name: "Apam balik", Hash: "old"},
+	}
+	meals := []main_parse.Meal{
+		{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+		{Name: "Apple & Blackberry Crumble", Href: "https://www.themealdb.com/meal/52893"},
+		{Name: "Ayam Percik", Href: "https://www.themealdb.com/meal/53050"},
+		{Name: "Bakewell tart", Href: "https://www.themealdb.com/meal/52767"},
+	}
+	fresh := map[string]string{
+		"https://www.themealdb.com/meal/52768": hashDetails(tart),
+		"https://www.themealdb.com/meal/52893": hashDetails(crumbleEdited),
+		"https://www.themealdb.com/meal/53050": "new",
+		// 52767 failed to load and must not show up anywhere
+	}
+
+	report := buildChangeReport(meals, fresh, previous)
+
+	assert.Equal(t, []main_parse.Meal{meals[2]}, report.Added)
+	assert.Equal(t, []main_parse.Meal{meals[1]}, report.Modified)
+	assert.Equal(t, []main_parse.Meal{{Name: "Apam balik", Href: "https://www.themealdb.com/meal/53049"}}, report.Removed)
+	assert.Equal(t, 1, report.Unchanged)
+}
+
+func TestHashDetails_IgnoresCrawlInfo(t *testing.T) {
+	plain := []MealDetail{{ReceiptName: "Apple Frangipan Tart Recipe", Flag: "gb"}}
+	crawled := []MealDetail{{
+		ID:          52768,
+		SourceURL:   "https://www.themealdb.com/meal/52768",
+		ReceiptName: "Apple Frangipan Tart Recipe",
+		Flag:        "gb",
+		Crawl:       &CrawlInfo{HTTPStatus: 200},
+	}}
+	assert.Equal(t, hashDetails(plain), hashDetails(crawled))
+	assert.NotNil(t, crawled[0].Crawl, "hashing must not change the details")
+}
@@ -0,0 +1,87 @@
+package extractor
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go_lang/parsing/meal_model"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// Page is a fetched HTML page handed to an Extractor.
+type Page struct {
+	DOM *goquery.Selection // the <html> element
+	URL string
+	// AbsoluteURL resolves a link found on the page against its URL
+	AbsoluteURL func(string) string
+}
+
+// FromColly wraps the <html> element of a colly callback.
+func FromColly(e *colly.HTMLElement) Page {
+	return Page{DOM: e.DOM, URL: e.Request.URL.String(), AbsoluteURL: e.Request.AbsoluteURL}
+}
+
+// Extractor knows the pages of one kind of recipe site: where its meal
+// lists are, which links on them lead to meals and how to read a meal page.
+// The crawl itself, retries and output are the same for every site.
+type Extractor interface {
+	// ListPages returns the pages listing the meals of the site at baseURL.
+	// letters picks a subset on sites that list meals by first letter.
+	ListPages(baseURL, letters string) []string
+	// ListMeals returns the meals linked from a list page.
+	ListMeals(page Page) []meal_model.Meal
+	// ExtractDetails reads the recipe on a meal page, usually one. A page
+	// without a recipe gives none.
+	ExtractDetails(page Page) []meal_model.MealDetail
+	// SitemapMeal reports whether a page listed in the sitemap of the site
+	// is a meal page, and names it as well as the URL allows.
+	SitemapMeal(pageURL string) (meal_model.Meal, bool)
+}
+
+// slugName turns the last segment of a URL path like "apple-frangipan-tart"
+// into "Apple frangipan tart". Leading digits, e.g. a meal ID, are dropped.
+func slugName(u *url.URL) string {
+	slug := strings.TrimLeft(path.Base(u.Path), "0123456789")
+	slug = strings.TrimSuffix(slug, path.Ext(slug))
+	name := strings.Join(strings.FieldsFunc(slug, func(r rune) bool { return r == '-' || r == '_' }), " ")
+	if name == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// extractors lists every site by the name of its -site flag, the first one
+// is the default.
+var extractors = []struct {
+	name string
+	new  func() Extractor
+}{
+	{"themealdb", func() Extractor { return TheMealDB{} }},
+	{"schema.org", func() Extractor { return SchemaOrg{} }},
+}
+
+// Names returns the names ByName accepts.
+func Names() []string {
+	names := make([]string, len(extractors))
+	for i, e := range extractors {
+		names[i] = e.name
+	}
+	return names
+}
+
+// ByName returns the extractor called name.
+func ByName(name string) (Extractor, error) {
+	for _, e := range extractors {
+		if e.name == name {
+			return e.new(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown site %q (want one of %s)", name, strings.Join(Names(), ", "))
+}
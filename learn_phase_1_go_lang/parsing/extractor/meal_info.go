@@ -0,0 +1,120 @@
+package extractor
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// mealInfo is what a meal page says about the meal besides the recipe.
+type mealInfo struct {
+	Category     string
+	Area         string
+	Tags         []string
+	YouTube      string
+	RecipeSource string
+	Thumbnail    string
+}
+
+// extractInfo reads the category, area, tags, video, source and thumbnail
+// of a meal page. section is section#feature, page the whole document and
+// abs resolves a link found on the page against the page URL.
+func extractInfo(section, page *goquery.Selection, abs func(string) string) mealInfo {
+	var info mealInfo
+	if texts, _ := labelled(section, "Category"); len(texts) > 0 {
+		info.Category = texts[0]
+	}
+	if texts, _ := labelled(section, "Area"); len(texts) > 0 {
+		info.Area = texts[0]
+	}
+	info.Tags, _ = labelled(section, "Tags")
+
+	if texts, links := labelled(section, "Source"); len(links) > 0 {
+		info.RecipeSource = abs(links[0])
+	} else if len(texts) > 0 && strings.HasPrefix(texts[0], "http") {
+		info.RecipeSource = texts[0]
+	}
+
+	section.Find("a[href], iframe[src]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		link := s.AttrOr("href", s.AttrOr("src", ""))
+		info.YouTube = youTubeURL(link)
+		return info.YouTube == ""
+	})
+
+	// <meta property="og:image" content="https://www.themealdb.com/images/media/meals/wxywrq1468235067.jpg">
+	if thumb, ok := page.Find("meta[property='og:image']").Attr("content"); ok && strings.TrimSpace(thumb) != "" {
+		info.Thumbnail = abs(strings.TrimSpace(thumb))
+	} else if src, ok := section.Find("img[src*='/images/media/meals/']").Attr("src"); ok {
+		info.Thumbnail = abs(src)
+	}
+	return info
+}
+
+// labelled finds the value next to a label, like <b>Category:</b> Dessert
+// or <td>Area</td><td>British</td>. It returns the texts after the label,
+// split on commas, and the links among them. The value ends at a line
+// break, a heading or the next label.
+func labelled(root *goquery.Selection, label string) (texts, links []string) {
+	l := root.Find("*").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		own := strings.TrimSuffix(strings.TrimSpace(s.Text()), ":")
+		return s.Children().Length() == 0 && strings.EqualFold(own, label)
+	}).First()
+	if l.Length() == 0 {
+		return nil, nil
+	}
+	after := false
+	l.Parent().Contents().EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !after {
+			after = s.IsSelection(l)
+			return true
+		}
+		name := goquery.NodeName(s)
+		text := strings.TrimSpace(s.Text())
+		if name == "br" || isHeading(name) || (name != "#text" && strings.HasSuffix(text, ":")) {
+			return false
+		}
+		for _, part := range strings.Split(strings.TrimPrefix(text, ":"), ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				texts = append(texts, part)
+			}
+		}
+		if href, ok := s.Attr("href"); ok && name == "a" {
+			links = append(links, href)
+		}
+		s.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+			links = append(links, a.AttrOr("href", ""))
+		})
+		return true
+	})
+	return texts, links
+}
+
+func isHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+}
+
+// youTubeURL turns a YouTube watch, short or embed link into
+// https://www.youtube.com/watch?v=<id>, or returns "" for any other link.
+func youTubeURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(u.Host, "www."), "m.")
+	var id string
+	switch host {
+	case "youtu.be":
+		id = strings.Trim(u.Path, "/")
+	case "youtube.com", "youtube-nocookie.com":
+		if strings.HasPrefix(u.Path, "/embed/") {
+			id = strings.TrimPrefix(u.Path, "/embed/")
+		} else {
+			id = u.Query().Get("v")
+		}
+	}
+	if id == "" || strings.Contains(id, "/") {
+		return ""
+	}
+	return "https://www.youtube.com/watch?v=" + id
+}
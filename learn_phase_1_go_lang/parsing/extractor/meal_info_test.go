@@ -0,0 +1,55 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelled(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<section>
+		<p><b>Category:</b> <a href="/category/Dessert">Dessert</a><br><b>Tags:</b> Tart, Baking ,<b>Area:</b> British</p>
+		<table><tr><td>Source</td><td><a href="https://example.com/tart">example.com</a></td></tr></table>
+		<h4>Keywords</h4><span>Sweet</span><h4>Other</h4>
+	</section>`))
+	assert.NoError(t, err)
+	root := doc.Find("section")
+
+	texts, links := labelled(root, "category")
+	assert.Equal(t, []string{"Dessert"}, texts)
+	assert.Equal(t, []string{"/category/Dessert"}, links)
+
+	texts, _ = labelled(root, "Tags")
+	assert.Equal(t, []string{"Tart", "Baking"}, texts)
+
+	texts, _ = labelled(root, "Area")
+	assert.Equal(t, []string{"British"}, texts)
+
+	_, links = labelled(root, "Source")
+	assert.Equal(t, []string{"https://example.com/tart"}, links)
+
+	texts, _ = labelled(root, "Keywords")
+	assert.Equal(t, []string{"Sweet"}, texts)
+
+	texts, links = labelled(root, "Video")
+	assert.Nil(t, texts)
+	assert.Nil(t, links)
+}
+
+func TestYouTubeURL(t *testing.T) {
+	cases := map[string]string{
+		"https://www.youtube.com/watch?v=rp8Slv4INLk":        "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		"https://m.youtube.com/watch?v=rp8Slv4INLk&t=10":     "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		"https://www.youtube.com/embed/rp8Slv4INLk":          "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		"https://youtu.be/rp8Slv4INLk":                       "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		"https://www.youtube-nocookie.com/embed/rp8Slv4INLk": "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		"https://www.youtube.com/channel/UC123":              "",
+		"https://www.bbcgoodfood.com/recipes/778642":         "",
+		"": "",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, youTubeURL(in), in)
+	}
+}
@@ -0,0 +1,343 @@
+package extractor
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/meal_model"
+	"go_lang/parsing/step_parse"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SchemaOrg reads any site that marks its recipes up with the schema.org
+// Recipe type, as JSON-LD or as microdata. The list page is the base URL
+// itself: meals are taken from a schema.org ItemList on it, or else from
+// the links whose path mentions "recipe".
+type SchemaOrg struct{}
+
+func (SchemaOrg) ListPages(baseURL, _ string) []string {
+	return []string{baseURL}
+}
+
+func (SchemaOrg) ListMeals(page Page) []meal_model.Meal {
+	var meals []meal_model.Meal
+	seen := map[string]bool{}
+	add := func(name, href string) {
+		href = page.AbsoluteURL(strings.TrimSpace(href))
+		if href == "" || href == page.URL || seen[href] {
+			return
+		}
+		seen[href] = true
+		meals = append(meals, meal_model.Meal{Name: strings.Join(strings.Fields(name), " "), Href: href})
+	}
+	for _, list := range jsonLD(page.DOM, "ItemList") {
+		for _, el := range asSlice(list["itemListElement"]) {
+			switch el := el.(type) {
+			case string:
+				add("", el)
+			case map[string]any:
+				if item, ok := el["item"].(map[string]any); ok {
+					el = item
+				}
+				add(text(el["name"]), cmp.Or(text(el["url"]), text(el["@id"])))
+			}
+		}
+	}
+	if len(meals) > 0 {
+		return meals
+	}
+	host := ""
+	if u, err := url.Parse(page.URL); err == nil {
+		host = u.Host
+	}
+	page.DOM.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		u, err := url.Parse(page.AbsoluteURL(a.AttrOr("href", "")))
+		if err != nil || u.Host != host || !strings.Contains(strings.ToLower(u.Path), "recipe") {
+			return
+		}
+		u.Fragment = ""
+		add(a.Text(), u.String())
+	})
+	return meals
+}
+
+// SitemapMeal takes the pages whose path mentions "recipe", as ListMeals
+// does with links, but not the recipe index pages themselves.
+func (SchemaOrg) SitemapMeal(pageURL string) (meal_model.Meal, bool) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return meal_model.Meal{}, false
+	}
+	p := strings.ToLower(strings.Trim(u.Path, "/"))
+	last := path.Base(p)
+	if !strings.Contains(p, "recipe") || last == "recipe" || last == "recipes" {
+		return meal_model.Meal{}, false
+	}
+	return meal_model.Meal{Name: slugName(u), Href: pageURL}, true
+}
+
+func (SchemaOrg) ExtractDetails(page Page) []meal_model.MealDetail {
+	var details []meal_model.MealDetail
+	for _, recipe := range jsonLD(page.DOM, "Recipe") {
+		if d, ok := recipeDetail(jsonLDProps(recipe), page.AbsoluteURL); ok {
+			details = append(details, d)
+		}
+	}
+	if len(details) > 0 {
+		return details
+	}
+	page.DOM.Find("[itemscope][itemtype]").Each(func(_ int, scope *goquery.Selection) {
+		if !isType(strings.Fields(scope.AttrOr("itemtype", "")), "Recipe") {
+			return
+		}
+		if d, ok := recipeDetail(microdataProps(scope), page.AbsoluteURL); ok {
+			details = append(details, d)
+		}
+	})
+	return details
+}
+
+// recipeDetail turns the properties of a Recipe into a MealDetail. It
+// needs at least ingredients or instructions to count as a recipe.
+func recipeDetail(props map[string][]string, abs func(string) string) (meal_model.MealDetail, bool) {
+	d := meal_model.MealDetail{
+		ReceiptName: first(props["name"]),
+		Category:    first(props["recipeCategory"]),
+		Area:        first(props["recipeCuisine"]),
+	}
+	for _, caption := range append(props["recipeIngredient"], props["ingredients"]...) {
+		if caption = strings.Join(strings.Fields(caption), " "); caption != "" {
+			d.Ingredents = append(d.Ingredents, meal_model.Ingredient{Caption: caption, Parsed: ingredient_parse.Parse(caption)})
+		}
+	}
+	var steps []string
+	for _, step := range props["recipeInstructions"] {
+		if step = plainText(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	d.Receipt = strings.Join(steps, "\n")
+	d.Steps = step_parse.Parse(d.Receipt)
+	if len(d.Ingredents) == 0 && d.Receipt == "" {
+		return d, false
+	}
+	for _, keywords := range props["keywords"] {
+		for _, tag := range strings.Split(keywords, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(d.Tags, tag) {
+				d.Tags = append(d.Tags, tag)
+			}
+		}
+	}
+	if image := first(props["image"]); image != "" {
+		d.Thumbnail = abs(image)
+	}
+	for _, video := range props["video"] {
+		if d.YouTube = youTubeURL(video); d.YouTube != "" {
+			break
+		}
+	}
+	if source := first(props["isBasedOn"]); source != "" {
+		d.RecipeSource = abs(source)
+	}
+	return d, true
+}
+
+// recipeProps are the Recipe properties recipeDetail reads.
+var recipeProps = []string{
+	"name", "recipeIngredient", "ingredients", "recipeInstructions", "recipeCategory",
+	"recipeCuisine", "keywords", "image", "video", "isBasedOn",
+}
+
+// jsonLD returns every object of type typ in the JSON-LD scripts of the
+// page, also the ones inside @graph or nested in other objects.
+func jsonLD(doc *goquery.Selection, typ string) []map[string]any {
+	var found []map[string]any
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			if isType(strings.Fields(strings.Join(strs(v["@type"]), " ")), typ) {
+				found = append(found, v)
+				return
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	doc.Find("script[type='application/ld+json']").Each(func(_ int, s *goquery.Selection) {
+		var v any
+		// A broken block is skipped, the page may have a good one or microdata
+		if err := json.Unmarshal([]byte(s.Text()), &v); err != nil {
+			return
+		}
+		walk(v)
+	})
+	return found
+}
+
+// isType reports whether one of types is the schema.org type typ, written
+// as "Recipe", "schema:Recipe" or "https://schema.org/Recipe".
+func isType(types []string, typ string) bool {
+	for _, t := range types {
+		if t == typ || strings.HasSuffix(t, "/"+typ) || strings.HasSuffix(t, ":"+typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonLDProps flattens the properties of a JSON-LD Recipe into strings.
+func jsonLDProps(recipe map[string]any) map[string][]string {
+	props := map[string][]string{}
+	for _, name := range recipeProps {
+		switch name {
+		case "recipeInstructions":
+			props[name] = instructionSteps(recipe[name])
+		case "image", "video", "isBasedOn":
+			for _, v := range asSlice(recipe[name]) {
+				if m, ok := v.(map[string]any); ok {
+					v = cmp.Or(text(m["embedUrl"]), text(m["contentUrl"]), text(m["url"]), text(m["@id"]))
+				}
+				if s := text(v); s != "" {
+					props[name] = append(props[name], s)
+				}
+			}
+		default:
+			props[name] = strs(recipe[name])
+		}
+	}
+	return props
+}
+
+// instructionSteps flattens recipeInstructions, which is a text, a list of
+// texts, of HowToStep or of HowToSection holding more steps.
+func instructionSteps(v any) []string {
+	var steps []string
+	for _, item := range asSlice(v) {
+		switch item := item.(type) {
+		case map[string]any:
+			if elements, ok := item["itemListElement"]; ok {
+				steps = append(steps, instructionSteps(elements)...)
+			} else if s := cmp.Or(text(item["text"]), text(item["name"])); s != "" {
+				steps = append(steps, s)
+			}
+		default:
+			if s := text(item); s != "" {
+				steps = append(steps, s)
+			}
+		}
+	}
+	return steps
+}
+
+// microdataProps collects the itemprop values that belong to scope itself,
+// not to items nested in it. A nested item, like a HowToStep or an
+// ImageObject, gives the value of its text or url.
+func microdataProps(scope *goquery.Selection) map[string][]string {
+	props := map[string][]string{}
+	scope.Find("[itemprop]").Each(func(_ int, s *goquery.Selection) {
+		if !s.Parent().Closest("[itemscope]").IsSelection(scope) {
+			return
+		}
+		value := itemValue(s)
+		if _, nested := s.Attr("itemscope"); nested {
+			value = ""
+			for _, prop := range []string{"text", "embedUrl", "contentUrl", "url", "name"} {
+				if p := s.Find("[itemprop='" + prop + "']").First(); p.Length() > 0 {
+					value = itemValue(p)
+					break
+				}
+			}
+		}
+		if value == "" {
+			return
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			props[name] = append(props[name], value)
+		}
+	})
+	return props
+}
+
+// itemValue is the microdata value of an element: the content attribute,
+// the link of a media or link element, or else its text.
+func itemValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	switch goquery.NodeName(s) {
+	case "img", "audio", "video", "source", "embed", "iframe":
+		return strings.TrimSpace(s.AttrOr("src", ""))
+	case "a", "link", "area":
+		return strings.TrimSpace(s.AttrOr("href", ""))
+	case "time":
+		if dt, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(dt)
+		}
+	case "data", "meter":
+		return strings.TrimSpace(s.AttrOr("value", ""))
+	}
+	return strings.TrimSpace(s.Text())
+}
+
+// plainText strips the HTML some sites leave in their instructions.
+func plainText(s string) string {
+	if strings.Contains(s, "<") {
+		if doc, err := goquery.NewDocumentFromReader(strings.NewReader(s)); err == nil {
+			s = doc.Text()
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func asSlice(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	}
+	return []any{v}
+}
+
+// text is a JSON-LD value as a string; of an object its name is taken.
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprint(v)
+	case map[string]any:
+		return text(v["name"])
+	}
+	return ""
+}
+
+func strs(v any) []string {
+	var out []string
+	for _, item := range asSlice(v) {
+		if s := text(item); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,141 @@
+package extractor
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/meal_model"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+// htmlPage parses html as if it was fetched from pageURL.
+func htmlPage(t *testing.T, html, pageURL string) Page {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	assert.NoError(t, err)
+	base, err := url.Parse(pageURL)
+	assert.NoError(t, err)
+	return Page{
+		DOM: doc.Find("html"),
+		URL: pageURL,
+		AbsoluteURL: func(ref string) string {
+			u, err := base.Parse(ref)
+			if err != nil {
+				return ""
+			}
+			return u.String()
+		},
+	}
+}
+
+func fixturePage(t *testing.T, file, pageURL string) Page {
+	data, err := os.ReadFile("../../testdata/fixtures/recipes.example.com/" + file)
+	assert.NoError(t, err)
+	return htmlPage(t, string(data), pageURL)
+}
+
+func TestSchemaOrg_ListMeals(t *testing.T) {
+	page := fixturePage(t, "index.html", "https://recipes.example.com")
+	assert.Equal(t, []meal_model.Meal{
+		{Name: "Shakshuka", Href: "https://recipes.example.com/recipes/shakshuka"},
+		{Name: "Banana Bread", Href: "https://recipes.example.com/recipes/banana-bread"},
+	}, SchemaOrg{}.ListMeals(page))
+
+	// Without an ItemList the recipe links on the same site are taken
+	page = htmlPage(t, `<html><body>
+		<a href="/recipes/soup">Tomato
+			soup</a> <a href="/about">About</a>
+		<a href="https://other.example.com/recipes/stew">Stew</a> <a href="/recipes/soup#comments">Comments</a>
+	</body></html>`, "https://recipes.example.com/")
+	assert.Equal(t, []meal_model.Meal{
+		{Name: "Tomato soup", Href: "https://recipes.example.com/recipes/soup"},
+	}, SchemaOrg{}.ListMeals(page))
+}
+
+func TestSchemaOrg_JSONLD(t *testing.T) {
+	page := fixturePage(t, "recipes/shakshuka.html", "https://recipes.example.com/recipes/shakshuka")
+	details := SchemaOrg{}.ExtractDetails(page)
+	assert.Len(t, details, 1)
+	d := details[0]
+	assert.Equal(t, "Shakshuka", d.ReceiptName)
+	assert.Equal(t, "Breakfast", d.Category)
+	assert.Equal(t, "Tunisian", d.Area)
+	assert.Equal(t, []string{"eggs", "one pan", "tomatoes"}, d.Tags)
+	assert.Equal(t, "https://recipes.example.com/img/shakshuka.jpg", d.Thumbnail)
+	assert.Equal(t, "https://www.youtube.com/watch?v=abc123XYZ", d.YouTube)
+	assert.Equal(t, "Heat the oil and fry the onion for 5 minutes.\n"+
+		"Add the tomatoes and simmer for 10 minutes.\n"+
+		"Crack in the eggs, cover and cook until set.", d.Receipt)
+	assert.Len(t, d.Ingredents, 4)
+	assert.Equal(t, meal_model.Ingredient{Caption: "1 onion, chopped", Parsed: ingredient_parse.Parse("1 onion, chopped")}, d.Ingredents[1])
+}
+
+func TestSchemaOrg_Microdata(t *testing.T) {
+	page := fixturePage(t, "recipes/banana-bread.html", "https://recipes.example.com/recipes/banana-bread")
+	details := SchemaOrg{}.ExtractDetails(page)
+	assert.Len(t, details, 1)
+	d := details[0]
+	// The name of the author is not the name of the recipe
+	assert.Equal(t, "Banana Bread", d.ReceiptName)
+	assert.Equal(t, "Baking", d.Category)
+	assert.Equal(t, []string{"cake", "bananas"}, d.Tags)
+	assert.Equal(t, "https://recipes.example.com/img/banana-bread.jpg", d.Thumbnail)
+	assert.Equal(t, "Mash the bananas and stir in the butter.\nFold in the flour and bake for 1 hour at 180C.", d.Receipt)
+	if assert.Len(t, d.Steps, 2) {
+		assert.Equal(t, 3600, d.Steps[1].Durations[0].MinSeconds)
+		assert.Equal(t, 180, d.Steps[1].Temperatures[0].Celsius)
+	}
+	assert.Len(t, d.Ingredents, 3)
+	assert.Equal(t, "butter", d.Ingredents[1].Name)
+}
+
+func TestSchemaOrg_BrokenJSONLD(t *testing.T) {
+	page := htmlPage(t, `<html><head>
+<script type="application/ld+json">{"@type": "Recipe", "name": </script>
+<script type="application/ld+json">{"@type": "Recipe", "name": "Toast", "recipeIngredient": ["2 slices bread"], "recipeInstructions": "Toast the bread."}</script>
+</head></html>`, "https://recipes.example.com/toast")
+	details := SchemaOrg{}.ExtractDetails(page)
+	if assert.Len(t, details, 1) {
+		assert.Equal(t, "Toast", details[0].ReceiptName)
+	}
+}
+
+func TestSchemaOrg_NoRecipe(t *testing.T) {
+	page := htmlPage(t, `<html><head><script type="application/ld+json">{"@type": "Recipe", "name": "Empty"}</script></head></html>`, "https://recipes.example.com/x")
+	assert.Empty(t, SchemaOrg{}.ExtractDetails(page))
+	page = htmlPage(t, `<html><body><p>Nothing here</p></body></html>`, "https://recipes.example.com/y")
+	assert.Empty(t, SchemaOrg{}.ExtractDetails(page))
+}
+
+func TestByName(t *testing.T) {
+	ex, err := ByName("schema.org")
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaOrg{}, ex)
+	_, err = ByName("bbc")
+	assert.ErrorContains(t, err, `unknown site "bbc"`)
+}
+
+func TestSitemapMeal(t *testing.T) {
+	meal, ok := TheMealDB{}.SitemapMeal("https://www.themealdb.com/meal/52768-Apple-Frangipan-Tart")
+	assert.True(t, ok)
+	assert.Equal(t, meal_model.Meal{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768-Apple-Frangipan-Tart"}, meal)
+	meal, ok = TheMealDB{}.SitemapMeal("https://www.themealdb.com/meal/52768")
+	assert.True(t, ok)
+	assert.Empty(t, meal.Name)
+	for _, page := range []string{"https://www.themealdb.com/browse/letter/a", "https://www.themealdb.com/meal/random", "https://www.themealdb.com/meal/1/x"} {
+		_, ok = TheMealDB{}.SitemapMeal(page)
+		assert.False(t, ok, page)
+	}
+
+	meal, ok = SchemaOrg{}.SitemapMeal("https://recipes.example.com/recipes/banana_bread.html")
+	assert.True(t, ok)
+	assert.Equal(t, "Banana bread", meal.Name)
+	for _, page := range []string{"https://recipes.example.com/recipes/", "https://recipes.example.com/about"} {
+		_, ok = SchemaOrg{}.SitemapMeal(page)
+		assert.False(t, ok, page)
+	}
+}
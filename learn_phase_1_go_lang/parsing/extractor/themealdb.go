@@ -0,0 +1,128 @@
+package extractor
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/meal_model"
+	"go_lang/parsing/step_parse"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TheMealDB reads www.themealdb.com: meals are listed by first letter on
+// /browse/letter/<x>, and a meal page keeps everything in section#feature.
+type TheMealDB struct{}
+
+func (TheMealDB) ListPages(baseURL, letters string) []string {
+	if letters == "" {
+		letters = "abcdefghijklmnopqrstuvwxyz"
+	}
+	pages := make([]string, 0, len(letters))
+	for _, ch := range letters {
+		pages = append(pages, fmt.Sprintf("%s/browse/letter/%c", strings.TrimSuffix(baseURL, "/"), ch))
+	}
+	return pages
+}
+
+func (TheMealDB) ListMeals(page Page) []meal_model.Meal {
+	var meals []meal_model.Meal
+	page.DOM.Find("section#feature .row .col-sm-3").Each(func(_ int, s *goquery.Selection) {
+		href := page.AbsoluteURL(s.Find("a").AttrOr("href", ""))
+		meals = append(meals, meal_model.Meal{Name: s.Text(), Href: href})
+	})
+	return meals
+}
+
+// SitemapMeal takes the /meal/<id> pages, with or without a name after the
+// ID.
+func (TheMealDB) SitemapMeal(pageURL string) (meal_model.Meal, bool) {
+	u, err := url.Parse(pageURL)
+	if err != nil || path.Dir(u.Path) != "/meal" {
+		return meal_model.Meal{}, false
+	}
+	meal := meal_model.Meal{Name: slugName(u), Href: pageURL}
+	return meal, meal.ID() != 0
+}
+
+func (TheMealDB) ExtractDetails(page Page) []meal_model.MealDetail {
+	var details []meal_model.MealDetail
+	page.DOM.Find("section#feature").Each(func(_ int, section *goquery.Selection) {
+		html, _ := section.Html()
+		start := strings.Index(html, "Instructions")
+		end := strings.Index(html, "Browse More")
+		if start == -1 || end == -1 || end <= start {
+			// No recipe in this section
+			return
+		}
+		start += len("Instructions")
+		instructions := html[start:end]
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(instructions))
+
+		text := strings.TrimSpace(doc.Text())
+
+		flagURL := ""
+		section.Find("img[src*='/images/icons/flags/big/64/']").Each(func(_ int, s *goquery.Selection) {
+			src, exists := s.Attr("src")
+			if exists && strings.Contains(src, "/images/icons/flags/big/64/") {
+				flagURL = page.AbsoluteURL(strings.TrimSpace(src))
+			}
+		})
+		// Extract country code from flagURL, e.g. "/images/icons/flags/big/64/gb.png" -> "gb"
+		flagCode := getFlag(flagURL)
+		// Parse ingredients: extract src and figcaption from each <figure>
+
+		var ingredients []meal_model.Ingredient
+		// Use the full section DOM, not just the instructions fragment
+		section.Find("figure").Each(func(_ int, s *goquery.Selection) {
+			img := s.Find("img")
+			src, _ := img.Attr("src")
+			caption := strings.TrimSpace(s.Find("figcaption").Text())
+			ingredients = append(ingredients, meal_model.Ingredient{
+				ImageURL: page.AbsoluteURL(strings.TrimSpace(src)),
+				Caption:  caption,
+				Parsed:   ingredient_parse.Parse(caption),
+			})
+		})
+		receiptName := ""
+		//	<meta property="og:title" content="Apple Frangipan Tart Recipe">
+		page.DOM.Find("meta[property='og:title']").Each(func(_ int, meta *goquery.Selection) {
+			content, exists := meta.Attr("content")
+			if exists {
+				receiptName = strings.TrimSpace(content)
+			}
+		})
+		info := extractInfo(section, page.DOM, page.AbsoluteURL)
+		details = append(details, meal_model.MealDetail{
+			Receipt:      text,
+			Steps:        step_parse.Parse(text),
+			Flag:         flagCode,
+			FlagURL:      flagURL,
+			Ingredents:   ingredients,
+			ReceiptName:  receiptName,
+			Category:     info.Category,
+			Area:         info.Area,
+			Tags:         info.Tags,
+			YouTube:      info.YouTube,
+			RecipeSource: info.RecipeSource,
+			Thumbnail:    info.Thumbnail,
+		})
+	})
+	return details
+}
+
+func getFlag(flagURL string) string {
+	if flagURL != "" {
+		parts := strings.Split(flagURL, "/")
+		if len(parts) > 0 {
+			filename := parts[len(parts)-1]
+			if strings.HasSuffix(filename, ".png") {
+				flagURL = strings.TrimSuffix(filename, ".png")
+			}
+		}
+	}
+	return flagURL
+}
@@ -0,0 +1,152 @@
+package failures
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"go_lang/parsing/crawl_pool"
+)
+
+// Stages of the crawl a failure can come from.
+const (
+	List    = "list"    // a letter page of the meal list
+	Details = "details" // a meal page
+)
+
+// Reasons a page failed.
+const (
+	Fetch = "fetch" // the page could not be loaded
+	Parse = "parse" // the page loaded but had no recipe in it
+)
+
+// Failure is one page the crawl had to give up on.
+type Failure struct {
+	URL      string `json:"url"`
+	Stage    string `json:"stage"`
+	Reason   string `json:"reason"`
+	Name     string `json:"name,omitempty"` // meal name of a details failure
+	Error    string `json:"error"`
+	Status   int    `json:"status,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+// Manifest is the list of failed pages kept in failures.json. Every stage
+// removes the pages it crawled fine and adds the ones it gave up on, so the
+// file always lists what is still missing from the output.
+// It is safe to use from several pool workers at once.
+type Manifest struct {
+	path  string
+	mu    sync.Mutex
+	items []Failure
+}
+
+// Open loads the manifest at path. A missing file gives an empty manifest.
+func Open(path string) (*Manifest, error) {
+	m := &Manifest{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.items); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Path is the file the manifest is saved to.
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Add records f, replacing an earlier failure of the same page.
+func (m *Manifest) Add(f Failure) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = slices.DeleteFunc(m.items, func(old Failure) bool {
+		return old.Stage == f.Stage && old.URL == f.URL
+	})
+	m.items = append(m.items, f)
+}
+
+// Remove forgets the failure of a page that has now been crawled.
+func (m *Manifest) Remove(stage, url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = slices.DeleteFunc(m.items, func(f Failure) bool {
+		return f.Stage == stage && f.URL == url
+	})
+}
+
+// Clear forgets every failure of stage, for a run that crawls all of its
+// pages again.
+func (m *Manifest) Clear(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = slices.DeleteFunc(m.items, func(f Failure) bool {
+		return f.Stage == stage
+	})
+}
+
+// Failures returns the failures of stage, or all of them for "".
+func (m *Manifest) Failures(stage string) []Failure {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Failure
+	for _, f := range m.items {
+		if stage == "" || f.Stage == stage {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Save writes the manifest back to its file, list failures first. An empty
+// manifest is written too, so an old failures.json doesn't outlive a
+// successful run.
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slices.SortStableFunc(m.items, func(a, b Failure) int {
+		return stageOrder(a.Stage) - stageOrder(b.Stage)
+	})
+	items := m.items
+	if items == nil {
+		items = []Failure{}
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(m.path, data, 0644)
+}
+
+func stageOrder(stage string) int {
+	if stage == List {
+		return 0
+	}
+	return 1
+}
+
+// New describes a failed page. Attempts and status come from the
+// crawl_pool.VisitError in err, if there is one.
+func New(stage, reason, url string, err error) Failure {
+	f := Failure{URL: url, Stage: stage, Reason: reason, Error: err.Error(), Attempts: 1}
+	var visitErr *crawl_pool.VisitError
+	if errors.As(err, &visitErr) {
+		f.Error = visitErr.Err.Error()
+		f.Status = visitErr.Status
+		f.Attempts = visitErr.Attempts
+	}
+	return f
+}
@@ -0,0 +1,39 @@
+package failures
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.json")
+	m, err := Open(path)
+	assert.NoError(t, err)
+	assert.Empty(t, m.Failures(""))
+
+	m.Add(Failure{URL: "https://www.themealdb.com/meal/1", Stage: Details, Reason: Fetch, Error: "Not Found", Status: 404, Attempts: 1})
+	m.Add(Failure{URL: "https://www.themealdb.com/browse/letter/q", Stage: List, Reason: Fetch, Error: "timeout", Attempts: 4})
+	m.Add(Failure{URL: "https://www.themealdb.com/meal/2", Stage: Details, Reason: Parse, Error: "no recipe", Attempts: 1})
+	// Failing again replaces the old entry
+	m.Add(Failure{URL: "https://www.themealdb.com/meal/1", Stage: Details, Reason: Fetch, Error: "Bad Gateway", Status: 502, Attempts: 4})
+	m.Remove(Details, "https://www.themealdb.com/meal/2")
+	assert.NoError(t, m.Save())
+
+	m, err = Open(path)
+	assert.NoError(t, err)
+	all := m.Failures("")
+	assert.Len(t, all, 2)
+	assert.Equal(t, List, all[0].Stage)
+	assert.Equal(t, 502, all[1].Status)
+	assert.Len(t, m.Failures(Details), 1)
+
+	m.Clear(Details)
+	m.Clear(List)
+	assert.NoError(t, m.Save())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+}
@@ -0,0 +1,225 @@
+package http_cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultDir is where the crawl keeps its cache unless told otherwise.
+const DefaultDir = ".cache/http"
+
+// entry is stored next to a cached body.
+type entry struct {
+	URL      string      `json:"url"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// Transport is an http.RoundTripper that answers GET requests from a cache
+// directory while the stored answer is younger than TTL, so repeated runs
+// don't hit the origin. Only 200 answers are stored. Requests with a
+// "Cache-Control: no-cache" header always go to Next.
+type Transport struct {
+	Dir string
+	TTL time.Duration // how long an answer stays fresh, forever when zero
+	// Next does the requests the cache can't answer, http.DefaultTransport
+	// when nil.
+	Next http.RoundTripper
+	// OnHit, if set, is called for every request answered from the cache.
+	OnHit func(req *http.Request)
+	// Log gets the answers that could not be stored, slog.Default() when nil.
+	Log *slog.Logger
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next().RoundTrip(req)
+	}
+	path := t.path(req.URL.String())
+	if !strings.Contains(req.Header.Get("Cache-Control"), "no-cache") {
+		if res, ok := t.load(req, path); ok {
+			if t.OnHit != nil {
+				t.OnHit(req)
+			}
+			return res, nil
+		}
+	}
+	res, err := t.next().RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	header := res.Header.Clone()
+	// The body is stored decoded
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	if err := store(path, entry{URL: req.URL.String(), Status: res.StatusCode, Header: header, StoredAt: time.Now().UTC()}, body); err != nil {
+		// A cache that can't be written only costs the next run a request
+		t.log().Warn("writing HTTP cache failed", "url", req.URL.String(), "err", err)
+	}
+	return res, nil
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) log() *slog.Logger {
+	if t.Log != nil {
+		return t.Log
+	}
+	return slog.Default()
+}
+
+// path is the body file of url, <dir>/<2 hex digits>/<sha256 of url>. The
+// entry is the same path ending in .json.
+func (t *Transport) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(t.Dir, name[:2], name+".body")
+}
+
+func metaPath(body string) string {
+	return strings.TrimSuffix(body, ".body") + ".json"
+}
+
+// load returns the cached answer to req if there is a fresh one.
+func (t *Transport) load(req *http.Request, path string) (*http.Response, bool) {
+	data, err := os.ReadFile(metaPath(path))
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if json.Unmarshal(data, &e) != nil || e.URL != req.URL.String() || expired(e, t.TTL, time.Now()) {
+		return nil, false
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if e.Header == nil {
+		e.Header = http.Header{}
+	}
+	e.Header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, true
+}
+
+func expired(e entry, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(e.StoredAt) > ttl
+}
+
+// store writes the body first and the entry last, each through a temporary
+// file, so an interrupted write never leaves an entry without its body.
+func store(path string, e entry, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, body); err != nil {
+		return err
+	}
+	return writeFile(metaPath(path), meta)
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Clear removes the cached answers in dir and returns how many there were.
+// With ttl set only the ones older than ttl are removed. Other files in dir
+// are left alone, and a missing dir is an empty cache.
+func Clear(dir string, ttl time.Duration) (int, error) {
+	removed := 0
+	now := time.Now()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return fs.SkipAll
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name, ok := cacheFile(path)
+		switch {
+		case !ok:
+			return nil
+		case strings.HasSuffix(name, ".tmp"):
+			// Left over by an interrupted write
+			return os.Remove(path)
+		case !strings.HasSuffix(name, ".json"):
+			return nil
+		}
+		if ttl > 0 {
+			var e entry
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			// Entries that can't be read are of no use either
+			if json.Unmarshal(data, &e) == nil && !expired(e, ttl, now) {
+				return nil
+			}
+		}
+		body := strings.TrimSuffix(path, ".json") + ".body"
+		if err := os.Remove(body); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		removed++
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		// Drops the directory once it is empty
+		os.Remove(filepath.Dir(path))
+		return nil
+	})
+	return removed, err
+}
+
+// cacheFile reports whether path is laid out like a file of the cache, see
+// Transport.path, and returns its name.
+func cacheFile(path string) (string, bool) {
+	name := filepath.Base(path)
+	sum, _, _ := strings.Cut(name, ".")
+	if len(sum) != 2*sha256.Size || filepath.Base(filepath.Dir(path)) != sum[:2] {
+		return "", false
+	}
+	_, err := hex.DecodeString(sum)
+	return name, err == nil
+}
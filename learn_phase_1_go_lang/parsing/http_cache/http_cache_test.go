@@ -0,0 +1,92 @@
+package http_cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	res, err := client.Get(url)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	return res, string(body)
+}
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "<html>"+r.URL.Path+"</html>")
+	}))
+	defer origin.Close()
+	hits := 0
+	cache := &Transport{Dir: t.TempDir(), TTL: time.Hour, OnHit: func(*http.Request) { hits++ }}
+	client := &http.Client{Transport: cache}
+
+	res, body := get(t, client, origin.URL+"/meal/52768")
+	assert.Equal(t, "<html>/meal/52768</html>", body)
+	assert.Empty(t, res.Header.Get("X-From-Cache"))
+
+	res, body = get(t, client, origin.URL+"/meal/52768")
+	assert.Equal(t, "<html>/meal/52768</html>", body)
+	assert.Equal(t, "1", res.Header.Get("X-From-Cache"))
+	assert.Equal(t, `"v1"`, res.Header.Get("ETag"))
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 1, hits)
+
+	// Only 200 answers are kept
+	get(t, client, origin.URL+"/missing")
+	res, _ = get(t, client, origin.URL+"/missing")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// A stale answer is fetched again
+	cache.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	res, _ = get(t, client, origin.URL+"/meal/52768")
+	assert.Empty(t, res.Header.Get("X-From-Cache"))
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestClear(t *testing.T) {
+	dir := t.TempDir()
+	cache := &Transport{Dir: dir}
+	old := cache.path("https://www.themealdb.com/meal/1")
+	fresh := cache.path("https://www.themealdb.com/meal/2")
+	assert.NoError(t, store(old, entry{URL: "https://www.themealdb.com/meal/1", Status: 200, StoredAt: time.Now().Add(-48 * time.Hour)}, []byte("1")))
+	assert.NoError(t, store(fresh, entry{URL: "https://www.themealdb.com/meal/2", Status: 200, StoredAt: time.Now()}, []byte("2")))
+	// Files that aren't the cache's are never removed
+	notes := filepath.Join(dir, "notes.json")
+	assert.NoError(t, os.WriteFile(notes, []byte("{}"), 0644))
+
+	removed, err := Clear(dir, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, fresh)
+
+	removed, err = Clear(dir, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, fresh)
+	assert.NoDirExists(t, filepath.Dir(fresh))
+	assert.FileExists(t, notes)
+
+	removed, err = Clear(filepath.Join(dir, "missing"), 0)
+	assert.NoError(t, err)
+	assert.Zero(t, removed)
+}
@@ -0,0 +1,191 @@
+package image_mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go_lang/parsing/crawl_pool"
+
+	"github.com/gocolly/colly"
+)
+
+// indexFile remembers which remote URL is stored in which file, so a new
+// run doesn't download the same images again.
+const indexFile = "index.json"
+
+// Store mirrors remote images into a directory. Files are named by the
+// SHA-256 of their content, so an image used by many meals, or served under
+// several URLs, is stored once. It is safe to use from several pool
+// workers at once.
+type Store struct {
+	pool   *crawl_pool.Pool
+	dir    string
+	prefix string // put in front of file names in the returned paths
+
+	mu       sync.Mutex
+	index    map[string]string // remote URL -> file name
+	inflight map[string]*download
+	failed   map[string]error // not asked again in the same run
+}
+
+// download lets workers that want the same URL wait for one request.
+type download struct {
+	done chan struct{}
+	file string
+	err  error
+}
+
+// Open loads the store in dir. Fetch returns paths relative to the parent of
+// dir, e.g. "images/<sha256>.png" for a store in output/images, so they stay
+// valid next to the other output files.
+func Open(pool *crawl_pool.Pool, dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		pool:     pool,
+		dir:      dir,
+		prefix:   filepath.Base(dir) + "/",
+		index:    map[string]string{},
+		inflight: map[string]*download{},
+		failed:   map[string]error{},
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.index); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", filepath.Join(dir, indexFile), err)
+		}
+	}
+	return s, nil
+}
+
+// Fetch makes sure the image at rawURL is in the store and returns its local
+// path. An empty URL gives an empty path.
+func (s *Store) Fetch(ctx context.Context, rawURL string) (string, error) {
+	if rawURL == "" {
+		return "", nil
+	}
+	s.mu.Lock()
+	if file, ok := s.index[rawURL]; ok && s.exists(file) {
+		s.mu.Unlock()
+		return s.prefix + file, nil
+	}
+	if err, ok := s.failed[rawURL]; ok {
+		s.mu.Unlock()
+		return "", err
+	}
+	if d, ok := s.inflight[rawURL]; ok {
+		s.mu.Unlock()
+		<-d.done
+		return s.local(d)
+	}
+	d := &download{done: make(chan struct{})}
+	s.inflight[rawURL] = d
+	s.mu.Unlock()
+
+	d.file, d.err = s.download(ctx, rawURL)
+
+	s.mu.Lock()
+	switch {
+	case d.err == nil:
+		s.index[rawURL] = d.file
+	case !errors.Is(d.err, crawl_pool.ErrStopped):
+		s.failed[rawURL] = d.err
+	}
+	delete(s.inflight, rawURL)
+	s.mu.Unlock()
+	close(d.done)
+	return s.local(d)
+}
+
+func (s *Store) local(d *download) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	return s.prefix + d.file, nil
+}
+
+func (s *Store) exists(file string) bool {
+	_, err := os.Stat(filepath.Join(s.dir, file))
+	return err == nil
+}
+
+func (s *Store) download(ctx context.Context, rawURL string) (string, error) {
+	var body []byte
+	var contentType string
+	c := s.pool.Collector()
+	c.OnResponse(func(r *colly.Response) {
+		body = r.Body
+		contentType = r.Headers.Get("Content-Type")
+	})
+	if err := s.pool.Visit(ctx, c, rawURL); err != nil {
+		return "", err
+	}
+	if len(body) == 0 {
+		return "", fmt.Errorf("%s: empty image", rawURL)
+	}
+	sum := sha256.Sum256(body)
+	file := hex.EncodeToString(sum[:]) + extension(rawURL, contentType)
+	if s.exists(file) {
+		return file, nil
+	}
+	// Write to a temporary name first, so a crash never leaves half an image
+	// under its final name
+	tmp := filepath.Join(s.dir, file+".tmp")
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return "", err
+	}
+	return file, os.Rename(tmp, filepath.Join(s.dir, file))
+}
+
+// extension keeps the extension of the URL, or guesses one from the
+// content type.
+func extension(rawURL, contentType string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := commonExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// commonExtensions avoids odd picks like .jfif, mime lists extensions
+// alphabetically.
+var commonExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Save writes the URL index, so the next run can skip what is already here.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.index, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, indexFile), data, 0644)
+}
@@ -0,0 +1,92 @@
+package image_mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go_lang/parsing/crawl_pool"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	png := []byte("\x89PNG fake butter")
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/missing.png":
+			w.WriteHeader(http.StatusNotFound)
+		case "/thumb":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("jpeg"))
+		default:
+			w.Write(png)
+		}
+	}))
+	defer srv.Close()
+
+	pool, err := crawl_pool.New(crawl_pool.Config{Parallelism: 4})
+	assert.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "images")
+	store, err := Open(pool, dir)
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256(png)
+	want := "images/" + hex.EncodeToString(sum[:]) + ".png"
+
+	// Many meals asking for the same image at once download it once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local, err := store.Fetch(context.Background(), srv.URL+"/images/ingredients/butter-medium.png")
+			assert.NoError(t, err)
+			assert.Equal(t, want, local)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Another URL with the same bytes shares the file
+	local, err := store.Fetch(context.Background(), srv.URL+"/images/ingredients/Butter-medium.png")
+	assert.NoError(t, err)
+	assert.Equal(t, want, local)
+
+	local, err = store.Fetch(context.Background(), srv.URL+"/thumb")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^images/[0-9a-f]{64}\.jpg$`, local)
+
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		_, err = store.Fetch(context.Background(), srv.URL+"/missing.png")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+
+	local, err = store.Fetch(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Empty(t, local)
+
+	assert.NoError(t, store.Save())
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3) // two images and the index
+
+	// A new run finds everything in the index
+	calls.Store(0)
+	store, err = Open(pool, dir)
+	assert.NoError(t, err)
+	local, err = store.Fetch(context.Background(), srv.URL+"/images/ingredients/butter-medium.png")
+	assert.NoError(t, err)
+	assert.Equal(t, want, local)
+	assert.Equal(t, int32(0), calls.Load())
+}
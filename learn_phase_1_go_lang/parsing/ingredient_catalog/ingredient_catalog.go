@@ -0,0 +1,310 @@
+package ingredient_catalog
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go_lang/parsing/detail_parse"
+)
+
+// Entry is one canonical ingredient of the catalog.
+type Entry struct {
+	ID        string   `json:"id"`   // e.g. "plain-flour"
+	Name      string   `json:"name"` // e.g. "Plain Flour"
+	Aliases   []string `json:"aliases,omitempty"`
+	ImageURL  string   `json:"image_url,omitempty"`
+	ImagePath string   `json:"image_path,omitempty"` // mirrored copy of ImageURL
+	Meals     int      `json:"meals"`                // number of meals using it
+}
+
+// Catalog is the list of canonical ingredients, sorted by ID.
+type Catalog struct {
+	Entries []Entry
+	index   map[string]int // normalized name, ID or alias -> entry
+}
+
+// Aliases maps a spelling to the ingredient it stands for, like
+// "Salted Butter": "Butter". Keys and values are compared after Normalize.
+// A value no meal spells that way names a new ingredient, "Sea Salt": "Salt"
+// files sea salt as Salt even when no meal uses plain salt.
+type Aliases map[string]string
+
+// LoadAliases reads the user's alias file. A missing file means no aliases.
+func LoadAliases(path string) (Aliases, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Aliases{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var aliases Aliases
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return aliases, nil
+}
+
+// Normalize makes spellings of one ingredient compare equal: lower case,
+// underscores as spaces and no repeated white space.
+func Normalize(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "_", " ")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ID turns a name into the catalog ID, e.g. "Plain Flour" -> "plain-flour".
+func ID(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(Normalize(name)) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+				return r
+			}
+			return -1
+		}, word)
+		if word == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
+
+// ImageName returns the ingredient name themealdb put in an image URL, e.g.
+// "Plain Flour" for .../images/ingredients/Plain_Flour-medium.png.
+func ImageName(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil || !strings.Contains(u.Path, "/ingredients/") {
+		return ""
+	}
+	name := path.Base(u.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	for _, size := range []string{"-small", "-medium", "-large"} {
+		name = strings.TrimSuffix(name, size)
+	}
+	return strings.TrimSpace(strings.ReplaceAll(name, "_", " "))
+}
+
+// IngredientName is the name an ingredient is filed under. The image name
+// is the site's own ingredient field, so it wins over the name parsed from
+// the caption. A preparation note after a comma is not part of the name.
+func IngredientName(ing detail_parse.Ingredient) string {
+	name := cmp.Or(ImageName(ing.ImageURL), ing.Name, ing.Caption)
+	name, _, _ = strings.Cut(name, ",")
+	return strings.TrimSpace(name)
+}
+
+// normalized returns the aliases with both sides normalized.
+func (a Aliases) normalized() map[string]string {
+	m := make(map[string]string, len(a))
+	for from, to := range a {
+		m[Normalize(from)] = Normalize(to)
+	}
+	return m
+}
+
+// resolve follows the aliases from a normalized name to its canonical one.
+func resolve(aliases map[string]string, key string) string {
+	// Bounded, so an alias loop can't hang the build
+	for range len(aliases) {
+		to, ok := aliases[key]
+		if !ok || to == key {
+			break
+		}
+		key = to
+	}
+	return key
+}
+
+// Build files every ingredient of meals under its canonical name. Names
+// that only differ in case or underscores are merged, and so are the ones
+// aliases points to the same ingredient. The display name is the spelling
+// used most.
+func Build(meals []detail_parse.MealDetail, aliases Aliases) *Catalog {
+	type seen struct {
+		spellings map[string]int // name as written -> uses
+		other     map[string]bool
+		images    map[string]int
+		paths     map[string]string // image URL -> local path
+		meals     int
+	}
+	byKey := map[string]*seen{}
+	normalized := aliases.normalized()
+	keyOf := func(name string) string {
+		return resolve(normalized, Normalize(name))
+	}
+	for _, meal := range meals {
+		inMeal := map[string]bool{}
+		for _, ing := range meal.Ingredents {
+			name := IngredientName(ing)
+			if name == "" {
+				continue
+			}
+			key := keyOf(name)
+			s := byKey[key]
+			if s == nil {
+				s = &seen{spellings: map[string]int{}, other: map[string]bool{}, images: map[string]int{}, paths: map[string]string{}}
+				byKey[key] = s
+			}
+			s.spellings[name]++
+			if ing.Name != "" {
+				s.other[ing.Name] = true
+			}
+			if ing.ImageURL != "" {
+				s.images[ing.ImageURL]++
+				if ing.ImagePath != "" {
+					s.paths[ing.ImageURL] = ing.ImagePath
+				}
+			}
+			if !inMeal[key] {
+				inMeal[key] = true
+				s.meals++
+			}
+		}
+	}
+
+	preferred := map[string]string{}
+	for from, to := range aliases {
+		key := keyOf(to)
+		if Normalize(to) == key {
+			preferred[key] = strings.TrimSpace(to)
+		}
+		if s := byKey[key]; s != nil {
+			s.other[strings.TrimSpace(from)] = true
+		}
+	}
+
+	c := &Catalog{}
+	for key, s := range byKey {
+		e := Entry{
+			ID:    ID(key),
+			Name:  mostUsed(s.spellings),
+			Meals: s.meals,
+		}
+		if name, ok := preferred[key]; ok && !hasSpelling(s.spellings, name) {
+			e.Name = name
+		}
+		e.ImageURL = mostUsed(s.images)
+		e.ImagePath = s.paths[e.ImageURL]
+		names := slices.Collect(maps.Keys(s.spellings))
+		names = append(names, slices.Collect(maps.Keys(s.other))...)
+		e.Aliases = aliasList(e.Name, names)
+		c.Entries = append(c.Entries, e)
+	}
+	slices.SortFunc(c.Entries, func(a, b Entry) int { return cmp.Compare(a.ID, b.ID) })
+	c.reindex()
+	return c
+}
+
+// mostUsed returns the key with the highest count, ties go to the first in
+// byte order, which prefers capitalized spellings.
+func mostUsed(counts map[string]int) string {
+	best := ""
+	for key, n := range counts {
+		if best == "" || n > counts[best] || n == counts[best] && key < best {
+			best = key
+		}
+	}
+	return best
+}
+
+func hasSpelling(spellings map[string]int, name string) bool {
+	for s := range spellings {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasList returns the sorted names other than name, one per spelling.
+func aliasList(name string, names []string) []string {
+	slices.Sort(names)
+	done := map[string]bool{strings.ToLower(name): true}
+	var aliases []string
+	for _, n := range names {
+		if n != "" && !done[strings.ToLower(n)] {
+			done[strings.ToLower(n)] = true
+			aliases = append(aliases, n)
+		}
+	}
+	return aliases
+}
+
+func (c *Catalog) reindex() {
+	c.index = map[string]int{}
+	for i, e := range c.Entries {
+		c.index[e.ID] = i
+		c.index[Normalize(e.Name)] = i
+		for _, alias := range e.Aliases {
+			if _, ok := c.index[Normalize(alias)]; !ok {
+				c.index[Normalize(alias)] = i
+			}
+		}
+	}
+}
+
+// Lookup finds the ingredient with the given name, ID or alias.
+func (c *Catalog) Lookup(name string) (Entry, bool) {
+	i, ok := c.index[Normalize(name)]
+	if !ok {
+		i, ok = c.index[ID(name)]
+	}
+	if !ok {
+		return Entry{}, false
+	}
+	return c.Entries[i], true
+}
+
+// Apply sets the IngredientID of every ingredient in meals found in the
+// catalog and returns how many were not.
+func (c *Catalog) Apply(meals []detail_parse.MealDetail) int {
+	missing := 0
+	for i := range meals {
+		for j := range meals[i].Ingredents {
+			ing := &meals[i].Ingredents[j]
+			e, ok := c.Lookup(IngredientName(*ing))
+			if !ok {
+				missing++
+				continue
+			}
+			ing.IngredientID = e.ID
+		}
+	}
+	return missing
+}
+
+// Save writes the catalog as a JSON array.
+func (c *Catalog) Save(path string) error {
+	data, err := json.MarshalIndent(c.Entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Load reads a catalog written by Save.
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{}
+	if err := json.Unmarshal(data, &c.Entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	c.reindex()
+	return c, nil
+}
@@ -0,0 +1,105 @@
+package ingredient_catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var meals = []detail_parse.MealDetail{
+	{ReceiptName: "Apple Frangipan Tart Recipe", Ingredents: []detail_parse.Ingredient{
+		meal_fixture.ImageIngredient("75g/3oz butter", meal_fixture.ImageURL("butter"), ""),
+		meal_fixture.ImageIngredient("200g Salted Butter", meal_fixture.ImageURL("Salted_Butter"), ""),
+		meal_fixture.ImageIngredient("2 free-range eggs, beaten", meal_fixture.ImageURL("free-range_eggs,_beaten"), ""),
+	}},
+	{ReceiptName: "Apple & Blackberry Crumble Recipe", Ingredents: []detail_parse.Ingredient{
+		meal_fixture.ImageIngredient("120g Butter", meal_fixture.ImageURL("Butter"), ""),
+		meal_fixture.ImageIngredient("25g Butter", meal_fixture.ImageURL("Butter"), ""),
+		meal_fixture.ImageIngredient("120g Plain Flour", meal_fixture.ImageURL("Plain_Flour"), ""),
+	}},
+	{ReceiptName: "Pancakes Recipe", Ingredents: []detail_parse.Ingredient{
+		meal_fixture.ImageIngredient("knob of Butter", meal_fixture.ImageURL("Butter"), ""),
+		meal_fixture.Ingredient("Pinch Sea Salt"),
+	}},
+}
+
+func TestBuild(t *testing.T) {
+	c := Build(meals, nil)
+	ids := []string{}
+	for _, e := range c.Entries {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"butter", "free-range-eggs", "plain-flour", "salted-butter", "sea-salt"}, ids)
+
+	butter, ok := c.Lookup("BUTTER")
+	assert.True(t, ok)
+	assert.Equal(t, "Butter", butter.Name) // used 3 times against 1
+	assert.Empty(t, butter.Aliases)        // "butter" only differs in case
+	assert.Equal(t, "https://www.themealdb.com/images/ingredients/Butter-medium.png", butter.ImageURL)
+	assert.Equal(t, 3, butter.Meals)
+
+	eggs, ok := c.Lookup("free-range-eggs")
+	assert.True(t, ok)
+	assert.Equal(t, "free-range eggs", eggs.Name)
+}
+
+func TestBuild_Aliases(t *testing.T) {
+	c := Build(meals, Aliases{"salted butter": "butter", "Sea Salt": "Salt"})
+
+	butter, ok := c.Lookup("Salted Butter")
+	assert.True(t, ok)
+	assert.Equal(t, "butter", butter.ID)
+	assert.Equal(t, "Butter", butter.Name)
+	assert.Equal(t, []string{"Salted Butter"}, butter.Aliases)
+
+	// No meal uses plain salt, the alias names it
+	salt, ok := c.Lookup("sea salt")
+	assert.True(t, ok)
+	assert.Equal(t, Entry{ID: "salt", Name: "Salt", Aliases: []string{"Sea Salt"}, Meals: 1}, salt)
+
+	missing := c.Apply(meals)
+	assert.Zero(t, missing)
+	assert.Equal(t, "butter", meals[0].Ingredents[1].IngredientID)
+	assert.Equal(t, "free-range-eggs", meals[0].Ingredents[2].IngredientID)
+	assert.Equal(t, "salt", meals[2].Ingredents[1].IngredientID)
+}
+
+func TestBuild_AliasLoop(t *testing.T) {
+	c := Build(meals, Aliases{"butter": "salted butter", "salted butter": "butter"})
+	_, ok := c.Lookup("butter")
+	assert.True(t, ok)
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	aliases, err := LoadAliases(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	path := filepath.Join(dir, "aliases.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"Salted Butter": "Butter"}`), 0644))
+	aliases, err = LoadAliases(path)
+	assert.NoError(t, err)
+
+	c := Build(meals, aliases)
+	assert.NoError(t, c.Save(filepath.Join(dir, "ingredients.json")))
+	loaded, err := Load(filepath.Join(dir, "ingredients.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, c.Entries, loaded.Entries)
+	e, ok := loaded.Lookup("salted_butter")
+	assert.True(t, ok)
+	assert.Equal(t, "butter", e.ID)
+}
+
+func TestImageName(t *testing.T) {
+	assert.Equal(t, "Plain Flour", ImageName("https://www.themealdb.com/images/ingredients/Plain_Flour-medium.png"))
+	assert.Equal(t, "Lime", ImageName("https://www.themealdb.com/images/ingredients/Lime.png"))
+	assert.Equal(t, "", ImageName("https://www.themealdb.com/images/media/meals/wxywrq1468235067.jpg"))
+	assert.Equal(t, "plain-flour", ID("Plain  Flour"))
+	assert.Equal(t, "cook-s-best", ID("Cook_s best!"))
+}
@@ -0,0 +1,279 @@
+package ingredient_parse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Measure is an amount with its unit, e.g. 175 g or 2-3 tbsp.
+type Measure struct {
+	Quantity    float64 `json:"quantity,omitempty"`
+	QuantityMax float64 `json:"quantity_max,omitempty"` // upper bound of a range like "4 - 6"
+	Unit        string  `json:"unit,omitempty"`
+}
+
+// Parsed holds the typed fields of an ingredient caption such as
+// "175g/6oz digestive biscuits" or "2 free-range eggs, beaten".
+type Parsed struct {
+	Measure
+	Alternate *Measure `json:"alternate,omitempty"` // the "6oz" in "175g/6oz"
+	Name      string   `json:"name"`
+	Note      string   `json:"note,omitempty"` // preparation, e.g. "beaten"
+}
+
+var unicodeFractions = map[rune]float64{
+	'½': 1.0 / 2, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 1.0 / 4, '¾': 3.0 / 4,
+	'⅕': 1.0 / 5, '⅛': 1.0 / 8, '⅜': 3.0 / 8, '⅝': 5.0 / 8, '⅞': 7.0 / 8,
+}
+
+const fractionChars = "½⅓⅔¼¾⅕⅛⅜⅝⅞"
+
+var (
+	mixedRe         = regexp.MustCompile(`^(\d+)[\s-]+(\d+)/(\d+)`)
+	slashFractionRe = regexp.MustCompile(`^(\d+)/(\d+)`)
+	numberUnicode   = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*-?\s*([` + fractionChars + `])`)
+	numberRe        = regexp.MustCompile(`^\d+(?:\.\d+)?`)
+	rangeSepRe      = regexp.MustCompile(`^\s*(?:-|–|to\s)\s*`)
+)
+
+// units maps every spelling seen in the captions to one canonical unit.
+var units = map[string]string{
+	"g": "g", "gr": "g", "gm": "g", "gram": "g", "grams": "g",
+	"kg": "kg", "kgs": "kg", "kilogram": "kg", "kilograms": "kg",
+	"mg": "mg",
+	"ml": "ml", "mls": "ml", "millilitre": "ml", "millilitres": "ml", "milliliter": "ml", "milliliters": "ml",
+	"cl": "cl",
+	"l":  "l", "litre": "l", "litres": "l", "liter": "l", "liters": "l",
+	"oz": "oz", "ounce": "oz", "ounces": "oz",
+	"fl oz": "fl oz", "fl. oz": "fl oz", "floz": "fl oz",
+	"lb": "lb", "lbs": "lb", "pound": "lb", "pounds": "lb",
+	"tsp": "tsp", "tsps": "tsp", "teaspoon": "tsp", "teaspoons": "tsp",
+	"tbsp": "tbsp", "tbsps": "tbsp", "tbs": "tbsp", "tbls": "tbsp", "tblsp": "tbsp", "tablespoon": "tbsp", "tablespoons": "tbsp",
+	"cup": "cup", "cups": "cup",
+	"pint": "pint", "pints": "pint", "pt": "pint",
+	"quart": "quart", "quarts": "quart", "qt": "quart",
+	"cm": "cm", "mm": "mm", "inch": "inch", "inches": "inch",
+	"clove": "clove", "cloves": "clove",
+	"can": "can", "cans": "can", "tin": "tin", "tins": "tin",
+	"jar": "jar", "jars": "jar", "pot": "pot", "pots": "pot",
+	"bottle": "bottle", "bottles": "bottle",
+	"bunch": "bunch", "bunches": "bunch",
+	"sprig": "sprig", "sprigs": "sprig",
+	"pinch": "pinch", "pinches": "pinch",
+	"handful": "handful", "handfuls": "handful",
+	"dash": "dash", "dashes": "dash", "splash": "splash",
+	"knob": "knob", "knobs": "knob",
+	"slice": "slice", "slices": "slice",
+	"stick": "stick", "sticks": "stick",
+	"packet": "packet", "packets": "packet", "pack": "pack", "packs": "pack",
+	"bag": "bag", "bags": "bag",
+	"head": "head", "heads": "head",
+	"piece": "piece", "pieces": "piece",
+	"sheet": "sheet", "sheets": "sheet",
+	"leaf": "leaf", "leaves": "leaf", "leafs": "leaf",
+	"cube": "cube", "cubes": "cube",
+	"drop": "drop", "drops": "drop",
+}
+
+// unitsWithoutQuantity may start a caption on their own, as in "Pinch Salt".
+var unitsWithoutQuantity = map[string]bool{
+	"pinch": true, "handful": true, "dash": true, "splash": true, "knob": true,
+}
+
+// prepWords lead into a preparation note, as in "6 chopped Spring Onions".
+var prepWords = map[string]bool{
+	"chopped": true, "diced": true, "sliced": true, "minced": true, "grated": true,
+	"crushed": true, "beaten": true, "mashed": true, "peeled": true, "seeded": true,
+	"deseeded": true, "separated": true, "seperated": true, "melted": true,
+	"softened": true, "shredded": true, "halved": true, "cubed": true, "cooked": true,
+	"finely": true, "roughly": true, "thinly": true, "very": true, "and": true,
+	"small": true, "medium": true, "large": true,
+}
+
+// servingPrefixes say what the ingredient is for rather than what it is.
+var servingPrefixes = []string{
+	"to serve", "to garnish", "for garnish", "garnish with", "garnish", "topping", "as required",
+	"to taste", "to glaze",
+	"for brushing", "for frying", "for greasing", "for dusting", "dusting",
+}
+
+// Parse splits an ingredient caption into quantity, unit, alternate
+// measurement, name and preparation note. It never fails: whatever can't be
+// recognised as a measure ends up in Name.
+func Parse(caption string) Parsed {
+	rest := strings.Join(strings.Fields(caption), " ")
+	var p Parsed
+	var notes []string
+
+	for _, prefix := range servingPrefixes {
+		if hasPrefixFold(rest, prefix+" ") {
+			notes = append(notes, prefix)
+			rest = strings.TrimSpace(rest[len(prefix):])
+			break
+		}
+	}
+
+	if m, after, ok := readMeasure(rest, true); ok {
+		p.Measure = m
+		rest = strings.TrimLeft(after, " ")
+		if strings.HasPrefix(rest, "/") {
+			if alt, after, ok := readMeasure(rest[1:], false); ok && alt.Unit != "" {
+				p.Alternate = &alt
+				rest = after
+			}
+		} else if strings.HasPrefix(rest, "(") {
+			if end := strings.Index(rest, ")"); end > 0 {
+				if alt, after, ok := readMeasure(rest[1:end], false); ok && alt.Unit != "" && strings.TrimSpace(after) == "" {
+					p.Alternate = &alt
+					rest = rest[end+1:]
+					// The unit may follow the size, as the tin in "1 (400g) tin Chopped tomatoes"
+					if unit, after, ok := readUnit(rest); ok && p.Unit == "" && strings.TrimSpace(after) != "" {
+						p.Unit = unit
+						rest = after
+					}
+				}
+			}
+		}
+	}
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	if hasPrefixFold(rest, "of ") {
+		rest = rest[len("of "):]
+	}
+
+	// Leading preparation words belong to the note
+	words := strings.Fields(rest)
+	lead := 0
+	for lead < len(words)-1 && prepWords[strings.ToLower(strings.TrimSuffix(words[lead], ","))] {
+		lead++
+	}
+	// "and" alone is not a preparation
+	for lead > 0 && strings.EqualFold(words[lead-1], "and") {
+		lead--
+	}
+	if lead > 0 {
+		notes = append(notes, strings.ToLower(strings.TrimSuffix(strings.Join(words[:lead], " "), ",")))
+		rest = strings.Join(words[lead:], " ")
+	}
+
+	if name, note, found := strings.Cut(rest, ","); found {
+		rest = name
+		if note = strings.TrimSpace(note); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	p.Name = strings.TrimSpace(rest)
+	p.Note = strings.Join(notes, ", ")
+	return p
+}
+
+// readMeasure reads a quantity and an optional unit from the start of s.
+// A measure of pounds may be followed by ounces, as in "1lb 8 oz", which is
+// folded into the pounds.
+//
+// The primary measure of a caption may be a bare unit like "Pinch", and its
+// unit must be followed by a name: in "3 Cloves" the cloves are the spice.
+func readMeasure(s string, primary bool) (Measure, string, bool) {
+	s = strings.TrimLeft(s, " ")
+	var m Measure
+	q, rest, ok := readQuantity(s)
+	if !ok {
+		if !primary {
+			return m, s, false
+		}
+		unit, after, ok := readUnit(s)
+		if !ok || !unitsWithoutQuantity[unit] {
+			return m, s, false
+		}
+		m.Unit = unit
+		return m, after, true
+	}
+	m.Quantity = q
+	if sep := rangeSepRe.FindString(rest); sep != "" {
+		if max, after, ok := readQuantity(rest[len(sep):]); ok {
+			m.QuantityMax = max
+			rest = after
+		}
+	}
+	if unit, after, ok := readUnit(rest); ok && (!primary || strings.TrimSpace(after) != "") {
+		m.Unit = unit
+		rest = after
+	}
+	if m.Unit == "lb" && m.QuantityMax == 0 {
+		if oz, after, ok := readQuantity(strings.TrimLeft(rest, " ")); ok {
+			if unit, after, ok := readUnit(after); ok && unit == "oz" {
+				m.Quantity += oz / 16
+				rest = after
+			}
+		}
+	}
+	return m, rest, true
+}
+
+// readQuantity reads "2", "1.5", "1/2", "1 1/2", "½", "1½", "2 ½" or "1-⅓".
+func readQuantity(s string) (float64, string, bool) {
+	if g := mixedRe.FindStringSubmatch(s); g != nil {
+		whole, _ := strconv.ParseFloat(g[1], 64)
+		num, _ := strconv.ParseFloat(g[2], 64)
+		den, _ := strconv.ParseFloat(g[3], 64)
+		if den != 0 && num < den {
+			return whole + num/den, s[len(g[0]):], true
+		}
+	}
+	if g := slashFractionRe.FindStringSubmatch(s); g != nil {
+		num, _ := strconv.ParseFloat(g[1], 64)
+		den, _ := strconv.ParseFloat(g[2], 64)
+		if den != 0 {
+			return num / den, s[len(g[0]):], true
+		}
+	}
+	if g := numberUnicode.FindStringSubmatch(s); g != nil {
+		whole, _ := strconv.ParseFloat(g[1], 64)
+		frac := []rune(g[2])[0]
+		return whole + unicodeFractions[frac], s[len(g[0]):], true
+	}
+	if g := numberRe.FindString(s); g != "" {
+		v, _ := strconv.ParseFloat(g, 64)
+		return v, s[len(g):], true
+	}
+	for _, r := range s {
+		if v, ok := unicodeFractions[r]; ok {
+			return v, s[len(string(r)):], true
+		}
+		break
+	}
+	return 0, s, false
+}
+
+// readUnit matches the longest known unit at the start of s. The unit must
+// end at a word boundary, so "1 garlic" is not read as grams.
+func readUnit(s string) (string, string, bool) {
+	trimmed := strings.TrimLeft(s, " ")
+	lower := strings.ToLower(trimmed)
+	best := ""
+	for spelling := range units {
+		if len(spelling) <= len(best) || !strings.HasPrefix(lower, spelling) {
+			continue
+		}
+		after := trimmed[len(spelling):]
+		after = strings.TrimPrefix(after, ".")
+		if after != "" {
+			r := []rune(after)[0]
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				continue
+			}
+		}
+		best = spelling
+	}
+	if best == "" {
+		return "", s, false
+	}
+	after := strings.TrimPrefix(trimmed[len(best):], ".")
+	return units[best], after, true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,112 @@
+package ingredient_parse
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	// Every caption below is taken from output/final_items.json
+	tests := []struct {
+		caption string
+		want    Parsed
+	}{
+		// metric with imperial alternate
+		{"175g/6oz digestive biscuits", Parsed{Measure: Measure{Quantity: 175, Unit: "g"}, Alternate: &Measure{Quantity: 6, Unit: "oz"}, Name: "digestive biscuits"}},
+		{"50g/1¾oz flaked almonds", Parsed{Measure: Measure{Quantity: 50, Unit: "g"}, Alternate: &Measure{Quantity: 1.75, Unit: "oz"}, Name: "flaked almonds"}},
+		{"125g/4½oz ground almonds", Parsed{Measure: Measure{Quantity: 125, Unit: "g"}, Alternate: &Measure{Quantity: 4.5, Unit: "oz"}, Name: "ground almonds"}},
+		{"6oz/180g rice stick noodles", Parsed{Measure: Measure{Quantity: 6, Unit: "oz"}, Alternate: &Measure{Quantity: 180, Unit: "g"}, Name: "rice stick noodles"}},
+		{"650g/1lb 8 oz Small Potatoes", Parsed{Measure: Measure{Quantity: 650, Unit: "g"}, Alternate: &Measure{Quantity: 1.5, Unit: "lb"}, Name: "Potatoes", Note: "small"}},
+		{"4 tablespoons (55 grams) Butter", Parsed{Measure: Measure{Quantity: 4, Unit: "tbsp"}, Alternate: &Measure{Quantity: 55, Unit: "g"}, Name: "Butter"}},
+		{"1 (400g) tin Chopped tomatoes", Parsed{Measure: Measure{Quantity: 1, Unit: "tin"}, Alternate: &Measure{Quantity: 400, Unit: "g"}, Name: "tomatoes", Note: "chopped"}},
+
+		// plain numbers, decimals and attached units
+		{"568ml Double Cream", Parsed{Measure: Measure{Quantity: 568, Unit: "ml"}, Name: "Double Cream"}},
+		{"1.5kg Chicken", Parsed{Measure: Measure{Quantity: 1.5, Unit: "kg"}, Name: "Chicken"}},
+		{"1L Fish Stock", Parsed{Measure: Measure{Quantity: 1, Unit: "l"}, Name: "Fish Stock"}},
+		{"1tbsp tomato puree", Parsed{Measure: Measure{Quantity: 1, Unit: "tbsp"}, Name: "tomato puree"}},
+		{"50 ml  Sour Cream", Parsed{Measure: Measure{Quantity: 50, Unit: "ml"}, Name: "Sour Cream"}},
+
+		// fractions
+		{"1/2 bag Rice Stick Noodles", Parsed{Measure: Measure{Quantity: 0.5, Unit: "bag"}, Name: "Rice Stick Noodles"}},
+		{"1 1/2 tsp  Garlic Powder", Parsed{Measure: Measure{Quantity: 1.5, Unit: "tsp"}, Name: "Garlic Powder"}},
+		{"½ tsp Chili Powder", Parsed{Measure: Measure{Quantity: 0.5, Unit: "tsp"}, Name: "Chili Powder"}},
+		{"¾ cup Cream", Parsed{Measure: Measure{Quantity: 0.75, Unit: "cup"}, Name: "Cream"}},
+		{"1½ kg Clams", Parsed{Measure: Measure{Quantity: 1.5, Unit: "kg"}, Name: "Clams"}},
+		{"2 ½ tbsp Thai red curry paste", Parsed{Measure: Measure{Quantity: 2.5, Unit: "tbsp"}, Name: "Thai red curry paste"}},
+		{"1-⅓ cups White Chocolate Chips", Parsed{Measure: Measure{Quantity: 1 + 1.0/3, Unit: "cup"}, Name: "White Chocolate Chips"}},
+
+		// ranges
+		{"4 - 6 Chicken Breasts", Parsed{Measure: Measure{Quantity: 4, QuantityMax: 6}, Name: "Chicken Breasts"}},
+		{"6-8 slices Parma ham", Parsed{Measure: Measure{Quantity: 6, QuantityMax: 8, Unit: "slice"}, Name: "Parma ham"}},
+
+		// unit spellings
+		{"1 tbs Parsley", Parsed{Measure: Measure{Quantity: 1, Unit: "tbsp"}, Name: "Parsley"}},
+		{"1 tbls Sunflower Oil", Parsed{Measure: Measure{Quantity: 1, Unit: "tbsp"}, Name: "Sunflower Oil"}},
+		{"2 tablespoons Pine nuts", Parsed{Measure: Measure{Quantity: 2, Unit: "tbsp"}, Name: "Pine nuts"}},
+		{"2 Lbs Beef", Parsed{Measure: Measure{Quantity: 2, Unit: "lb"}, Name: "Beef"}},
+		{"1 pound Sausages", Parsed{Measure: Measure{Quantity: 1, Unit: "lb"}, Name: "Sausages"}},
+		{"8 cups  Vegetable Stock", Parsed{Measure: Measure{Quantity: 8, Unit: "cup"}, Name: "Vegetable Stock"}},
+		{"2 cloves Garlic", Parsed{Measure: Measure{Quantity: 2, Unit: "clove"}, Name: "Garlic"}},
+		{"2 cans Coconut Milk", Parsed{Measure: Measure{Quantity: 2, Unit: "can"}, Name: "Coconut Milk"}},
+		{"1 Jar Tomato Sauce", Parsed{Measure: Measure{Quantity: 1, Unit: "jar"}, Name: "Tomato Sauce"}},
+
+		// no unit
+		{"2 free-range eggs, beaten", Parsed{Measure: Measure{Quantity: 2}, Name: "free-range eggs", Note: "beaten"}},
+		{"3 Tomatoes", Parsed{Measure: Measure{Quantity: 3}, Name: "Tomatoes"}},
+		{"1 garlic", Parsed{Measure: Measure{Quantity: 1}, Name: "garlic"}},
+		{"2 large Eggs", Parsed{Measure: Measure{Quantity: 2}, Name: "Eggs", Note: "large"}},
+		{"3 Cloves", Parsed{Measure: Measure{Quantity: 3}, Name: "Cloves"}},
+
+		// preparation notes
+		{"6 chopped Spring Onions", Parsed{Measure: Measure{Quantity: 6}, Name: "Spring Onions", Note: "chopped"}},
+		{"2 cloves minced garlic", Parsed{Measure: Measure{Quantity: 2, Unit: "clove"}, Name: "garlic", Note: "minced"}},
+		{"1 clove, peeled and sliced garlic", Parsed{Measure: Measure{Quantity: 1, Unit: "clove"}, Name: "garlic", Note: "peeled and sliced"}},
+		{"Sliced and Seeded Jalapeno", Parsed{Name: "Jalapeno", Note: "sliced and seeded"}},
+		{"to serve Raspberries", Parsed{Name: "Raspberries", Note: "to serve"}},
+		{"To taste Salt", Parsed{Name: "Salt", Note: "to taste"}},
+		{"To Glaze Eggs", Parsed{Name: "Eggs", Note: "to glaze"}},
+		{"For brushing vegetable oil", Parsed{Name: "vegetable oil", Note: "for brushing"}},
+		{"Garnish chopped  Coriander", Parsed{Name: "Coriander", Note: "garnish, chopped"}},
+
+		// bare units and free text
+		{"Pinch Nutmeg", Parsed{Measure: Measure{Unit: "pinch"}, Name: "Nutmeg"}},
+		{"Handful Basil Leaves", Parsed{Measure: Measure{Unit: "handful"}, Name: "Basil Leaves"}},
+		{"Zest of 1 orange", Parsed{Name: "Zest of 1 orange"}},
+		{"Chicken Stock", Parsed{Name: "Chicken Stock"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			got := Parse(tt.caption)
+			assert.InDelta(t, tt.want.Quantity, got.Quantity, 1e-9)
+			got.Quantity = tt.want.Quantity
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_EveryCaptionInOutputHasAName(t *testing.T) {
+	data, err := os.ReadFile("../../output/final_items.json")
+	if err != nil {
+		t.Skip("output/final_items.json not found")
+	}
+	var meals []struct {
+		Ingredents []struct {
+			Caption string `json:"caption"`
+		} `json:"ingredents"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &meals))
+
+	for _, meal := range meals {
+		for _, ing := range meal.Ingredents {
+			if ing.Caption == "" {
+				continue
+			}
+			assert.NotEmpty(t, Parse(ing.Caption).Name, "caption %q", ing.Caption)
+		}
+	}
+}
@@ -1,51 +1,191 @@
 package main_parse
 
 import (
+	"bufio"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/failures"
+	"go_lang/parsing/meal_model"
 
 	"github.com/gocolly/colly"
 )
 
-type Meal struct {
-	Name string `json:"name"`
-	Href string `json:"href"`
+// Meal is one entry of the meal list, see meal_model.Meal.
+type Meal = meal_model.Meal
+
+// MealSink receives the meal list in letter order while it is crawled.
+type MealSink interface {
+	WriteMeal(Meal) error
+	Close() error
 }
 
-var Meals []Meal
+// DefaultBaseURL is the site crawled when ListOptions.BaseURL is empty.
+const DefaultBaseURL = "https://www.themealdb.com"
 
-func ThumnailPageParse() {
-	c := colly.NewCollector()
-	c.OnHTML("section#feature .row .col-sm-3", func(e *colly.HTMLElement) {
-		meal := e.Text
-		href := e.ChildAttr("a", "href")
-		Meals = append(Meals, Meal{Name: meal, Href: href})
-	})
-	for ch := 'a'; ch <= 'z'; ch++ {
-		err := c.Visit(fmt.Sprintf("https://www.themealdb.com/browse/letter/%c", ch))
-		if err != nil {
-			fmt.Println("Error visiting page:", err)
+// ListOptions picks which list pages ThumnailPageParse crawls.
+type ListOptions struct {
+	BaseURL string // site root, DefaultBaseURL when empty
+	Letters string // letters to crawl in order, a-z when empty
+	// Pages, if set, are crawled instead of the list pages of the site
+	Pages []string
+	// Sitemap finds the meals in the sitemaps of the site instead of on its
+	// list pages, see SitemapMeals. Letters is not used then.
+	Sitemap bool
+	// Extractor reads the site, extractor.TheMealDB when nil
+	Extractor extractor.Extractor
+	// Failures, if set, gets the letter pages that could not be loaded and
+	// forgets the ones that could.
+	Failures *failures.Manifest
+}
+
+// ParseLetters expands a letter subset like "a-c,x" into "abcx".
+func ParseLetters(s string) (string, error) {
+	var letters []rune
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		part = strings.TrimSpace(part)
+		r := []rune(part)
+		switch {
+		case len(r) == 0:
+			continue
+		case len(r) == 3 && r[1] == '-' && 'a' <= r[0] && r[0] <= r[2] && r[2] <= 'z':
+			for ch := r[0]; ch <= r[2]; ch++ {
+				letters = append(letters, ch)
+			}
+		case !strings.ContainsFunc(part, func(ch rune) bool { return ch < 'a' || ch > 'z' }):
+			letters = append(letters, r...)
+		default:
+			return "", fmt.Errorf("bad letters %q, want letters or ranges like a-c,x", part)
 		}
-		fmt.Printf("Processed complete char:[%c]\n", ch)
 	}
+	return string(letters), nil
+}
 
-	jsonData, err := json.MarshalIndent(Meals, "", "  ")
-	if err != nil {
-		fmt.Println("Error marshaling to JSON:", err)
-		return
+// letterResult is what a pool worker hands back for one list page.
+type letterResult struct {
+	Meals   []Meal
+	Failed  bool
+	Stopped bool // not fetched, the crawl was stopped
+}
+
+// ThumnailPageParse crawls the list pages, the letter pages on themealdb,
+// writes every meal to sink and returns the full list for the detail phase.
+// List pages that fail to load are skipped and reported in the returned
+// error, next to the meals of the other pages. When the crawl is stopped,
+// see crawl_pool.Stopped, the meals found so far are still written and the
+// error wraps crawl_pool.ErrStopped.
+func ThumnailPageParse(ctx context.Context, pool *crawl_pool.Pool, sink MealSink, opts ListOptions) ([]Meal, error) {
+	ex := opts.Extractor
+	if ex == nil {
+		ex = extractor.TheMealDB{}
 	}
-	err = os.MkdirAll("output", 0755)
-	if err != nil {
-		fmt.Println("Error creating output directory:", err)
-		return
+	baseURL := cmp.Or(strings.TrimSuffix(opts.BaseURL, "/"), DefaultBaseURL)
+	if opts.Sitemap && opts.Pages == nil {
+		return sitemapParse(ctx, pool, ex, baseURL, sink)
 	}
+	pages := opts.Pages
+	if pages == nil {
+		pages = ex.ListPages(baseURL, opts.Letters)
+	}
+	var meals []Meal
+	var sinkErr, stopErr error
+	failed := 0
+	stage := pool.StartStage("list", len(pages))
+	mapErr := crawl_pool.Map(ctx, pool, len(pages), func(i int) letterResult {
+		pageURL := pages[i]
+		if err := pool.TakePage(ctx); err != nil {
+			return letterResult{Stopped: true}
+		}
+		var found []Meal
+		c := pool.Collector()
+		c.OnHTML("html", func(e *colly.HTMLElement) {
+			found = append(found, ex.ListMeals(extractor.FromColly(e))...)
+		})
+		if err := pool.Visit(ctx, c, pageURL); errors.Is(err, crawl_pool.ErrStopped) {
+			return letterResult{Stopped: true}
+		} else if err != nil {
+			pool.Log().Warn("list page failed", "url", pageURL, "err", err)
+			if opts.Failures != nil {
+				opts.Failures.Add(failures.New(failures.List, failures.Fetch, pageURL, err))
+			}
+			return letterResult{Failed: true}
+		}
+		if opts.Failures != nil {
+			opts.Failures.Remove(failures.List, pageURL)
+		}
+		pool.Log().Debug("list page done", "url", pageURL, "meals", len(found))
+		return letterResult{Meals: found}
+	}, func(_ int, res letterResult) {
+		switch {
+		case res.Stopped:
+			if stopErr == nil {
+				stopErr = pool.Stopped(ctx)
+			}
+			return
+		case res.Failed:
+			failed++
+			stage.Failed()
+		default:
+			stage.Done()
+		}
+		meals = append(meals, res.Meals...)
+		for _, meal := range res.Meals {
+			if sinkErr != nil {
+				return
+			}
+			sinkErr = sink.WriteMeal(meal)
+		}
+	})
+
+	stopErr = cmp.Or(stopErr, mapErr)
+
+	if err := errors.Join(sinkErr, sink.Close()); err != nil {
+		return meals, fmt.Errorf("write meal list: %w", err)
+	}
+	stage.End()
+	pool.Log().Info("meal list written", "meals", len(meals))
+	var failedErr error
+	if failed > 0 {
+		failedErr = fmt.Errorf("%d of %d list pages failed", failed, len(pages))
+	}
+	if stopErr != nil {
+		return meals, errors.Join(failedErr, fmt.Errorf("meal list is partial: %w", stopErr))
+	}
+	return meals, failedErr
+}
 
-	// Write JSON to output/items.json
-	err = os.WriteFile("output/items.json", jsonData, 0644)
+// LoadMeals reads a meal list written by the json or jsonl sink.
+func LoadMeals(path string) ([]Meal, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Println("Error writing JSON file:", err)
-		return
+		return nil, err
+	}
+	defer file.Close()
+
+	var meals []Meal
+	if !strings.HasSuffix(path, ".jsonl") {
+		if err := json.NewDecoder(file).Decode(&meals); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return meals, nil
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var meal Meal
+		if err := json.Unmarshal(scanner.Bytes(), &meal); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		meals = append(meals, meal)
 	}
-	fmt.Println("Data written to output/items.json")
+	return meals, scanner.Err()
 }
@@ -0,0 +1,161 @@
+package main_parse
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/replay"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the fixtures")
+
+const goldenItems = "../../testdata/golden/items.json"
+
+type memorySink struct {
+	meals  []Meal
+	closed bool
+}
+
+func (s *memorySink) WriteMeal(m Meal) error {
+	s.meals = append(s.meals, m)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestThumnailPageParse_Replay(t *testing.T) {
+	cfg := crawl_pool.Config{
+		Parallelism: 4,
+		Transport:   &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"},
+	}
+	pool, err := crawl_pool.New(cfg)
+	assert.NoError(t, err)
+
+	sink := &memorySink{}
+	meals, err := ThumnailPageParse(context.Background(), pool, sink, ListOptions{})
+	assert.NoError(t, err)
+	assert.True(t, sink.closed)
+	assert.Equal(t, meals, sink.meals)
+
+	if *update {
+		data, _ := json.MarshalIndent(meals, "", "  ")
+		assert.NoError(t, os.WriteFile(goldenItems, append(data, '\n'), 0644))
+	}
+	want, err := LoadMeals(goldenItems)
+	assert.NoError(t, err)
+	assert.Equal(t, want, meals)
+}
+
+func TestParseLetters(t *testing.T) {
+	letters, err := ParseLetters("a-c, X,yz")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcxyz", letters)
+
+	letters, err = ParseLetters("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", letters)
+
+	for _, bad := range []string{"c-a", "a-", "1", "a-c-e"} {
+		_, err := ParseLetters(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestThumnailPageParse_Subset(t *testing.T) {
+	cfg := crawl_pool.Config{Transport: &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"}}
+	pool, err := crawl_pool.New(cfg)
+	assert.NoError(t, err)
+
+	meals, err := ThumnailPageParse(context.Background(), pool, &memorySink{}, ListOptions{BaseURL: DefaultBaseURL + "/", Letters: "bq"})
+	assert.NoError(t, err)
+	assert.Len(t, meals, 2)
+	assert.Equal(t, "Bakewell tart", meals[0].Name)
+
+	// There is no fixture for this site, every letter page fails
+	meals, err = ThumnailPageParse(context.Background(), pool, &memorySink{}, ListOptions{BaseURL: "https://example.com", Letters: "ab"})
+	assert.ErrorContains(t, err, "2 of 2 list pages failed")
+	assert.Empty(t, meals)
+}
+
+func TestThumnailPageParse_MaxPages(t *testing.T) {
+	cfg := crawl_pool.Config{MaxPages: 1, Transport: &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"}}
+	pool, err := crawl_pool.New(cfg)
+	assert.NoError(t, err)
+
+	sink := &memorySink{}
+	meals, err := ThumnailPageParse(context.Background(), pool, sink, ListOptions{Letters: "bq"})
+	assert.ErrorIs(t, err, crawl_pool.ErrPageBudget)
+	assert.ErrorContains(t, err, "meal list is partial")
+	// What was found before the budget ran out is still written
+	assert.True(t, sink.closed)
+	assert.Equal(t, "Bakewell tart", meals[0].Name)
+	assert.Equal(t, meals, sink.meals)
+}
+
+func TestThumnailPageParse_OtherSite(t *testing.T) {
+	cfg := crawl_pool.Config{Transport: &replay.Transport{Mode: replay.Replay, Dir: "../../testdata/fixtures"}}
+	pool, err := crawl_pool.New(cfg)
+	assert.NoError(t, err)
+
+	opts := ListOptions{BaseURL: "https://recipes.example.com", Extractor: extractor.SchemaOrg{}}
+	meals, err := ThumnailPageParse(context.Background(), pool, &memorySink{}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []Meal{
+		{Name: "Shakshuka", Href: "https://recipes.example.com/recipes/shakshuka"},
+		{Name: "Banana Bread", Href: "https://recipes.example.com/recipes/banana-bread"},
+	}, meals)
+
+	// Pages replaces the list pages of the site
+	opts = ListOptions{Pages: []string{DefaultBaseURL + "/browse/letter/b"}}
+	meals, err = ThumnailPageParse(context.Background(), pool, &memorySink{}, opts)
+	assert.NoError(t, err)
+	assert.Len(t, meals, 2)
+}
+
+func TestThumnailPageParse_Sitemap(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			io.WriteString(w, "User-agent: *\nAllow: /\nSitemap: "+srv.URL+"/sitemap-meals.xml\n")
+		case "/sitemap-meals.xml":
+			io.WriteString(w, `<urlset><url><loc>`+srv.URL+`/meal/52768-Apple-Frangipan-Tart</loc></url>`+
+				`<url><loc>`+srv.URL+`/browse/letter/a</loc></url><url><loc>`+srv.URL+`/meal/53049</loc></url></urlset>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	pool, err := crawl_pool.New(crawl_pool.Config{Robots: true})
+	assert.NoError(t, err)
+
+	sink := &memorySink{}
+	meals, err := ThumnailPageParse(context.Background(), pool, sink, ListOptions{BaseURL: srv.URL, Sitemap: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Meal{
+		{Name: "Apple Frangipan Tart", Href: srv.URL + "/meal/52768-Apple-Frangipan-Tart"},
+		{Href: srv.URL + "/meal/53049"},
+	}, meals)
+	assert.Equal(t, meals, sink.meals)
+	assert.True(t, sink.closed)
+}
+
+func TestMealID(t *testing.T) {
+	assert.Equal(t, 52768, Meal{Href: "https://www.themealdb.com/meal/52768"}.ID())
+	assert.Equal(t, 52768, Meal{Href: "https://www.themealdb.com/meal/52768-Apple-Frangipan-Tart"}.ID())
+	assert.Equal(t, 0, Meal{Href: "https://www.themealdb.com/browse/letter/a"}.ID())
+	assert.Equal(t, 0, Meal{}.ID())
+}
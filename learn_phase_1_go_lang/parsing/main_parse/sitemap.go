@@ -0,0 +1,55 @@
+package main_parse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/extractor"
+	"go_lang/parsing/sitemap"
+)
+
+// SitemapMeals finds the meals of the site at baseURL in its sitemaps: the
+// ones its robots.txt lists, or else /sitemap.xml. The pages ex doesn't
+// take for meal pages are left out.
+func SitemapMeals(ctx context.Context, pool *crawl_pool.Pool, ex extractor.Extractor, baseURL string) ([]Meal, error) {
+	roots, err := pool.Sitemaps(ctx, baseURL)
+	if err != nil {
+		pool.Log().Warn("no sitemaps from robots.txt, trying /sitemap.xml", "err", err)
+	}
+	if len(roots) == 0 {
+		roots = []string{baseURL + "/sitemap.xml"}
+	}
+	pages, err := sitemap.Discover(ctx, pool, roots)
+	var meals []Meal
+	for _, page := range pages {
+		if meal, ok := ex.SitemapMeal(page); ok {
+			meals = append(meals, meal)
+		}
+	}
+	pool.Log().Info("sitemaps read", "pages", len(pages), "meals", len(meals))
+	return meals, err
+}
+
+// sitemapParse is ThumnailPageParse with ListOptions.Sitemap.
+func sitemapParse(ctx context.Context, pool *crawl_pool.Pool, ex extractor.Extractor, baseURL string, sink MealSink) ([]Meal, error) {
+	meals, discoverErr := SitemapMeals(ctx, pool, ex, baseURL)
+	var sinkErr error
+	for _, meal := range meals {
+		if sinkErr = sink.WriteMeal(meal); sinkErr != nil {
+			break
+		}
+	}
+	if err := errors.Join(sinkErr, sink.Close()); err != nil {
+		return meals, fmt.Errorf("write meal list: %w", err)
+	}
+	pool.Log().Info("meal list written", "meals", len(meals))
+	if errors.Is(discoverErr, crawl_pool.ErrStopped) {
+		return meals, fmt.Errorf("meal list is partial: %w", discoverErr)
+	}
+	if discoverErr != nil {
+		return meals, fmt.Errorf("read sitemaps: %w", discoverErr)
+	}
+	return meals, nil
+}
@@ -0,0 +1,41 @@
+package meal_fixture
+
+import (
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_parse"
+)
+
+// Ingredient is an ingredient as the crawl writes it, with its caption
+// parsed. It builds the meals of the tests that read a crawl.
+func Ingredient(caption string) detail_parse.Ingredient {
+	return detail_parse.Ingredient{Caption: caption, Parsed: ingredient_parse.Parse(caption)}
+}
+
+// ImageIngredient is an Ingredient with its image URL and the path of its
+// mirrored copy, empty when it was not mirrored.
+func ImageIngredient(caption, imageURL, imagePath string) detail_parse.Ingredient {
+	ing := Ingredient(caption)
+	ing.ImageURL, ing.ImagePath = imageURL, imagePath
+	return ing
+}
+
+// Ingredients is an Ingredient per caption.
+func Ingredients(captions ...string) []detail_parse.Ingredient {
+	var ings []detail_parse.Ingredient
+	for _, caption := range captions {
+		ings = append(ings, Ingredient(caption))
+	}
+	return ings
+}
+
+// Meal is a meal named like on themealdb, "<name> Recipe", with an
+// ingredient per caption.
+func Meal(name string, captions ...string) detail_parse.MealDetail {
+	return detail_parse.MealDetail{ReceiptName: name + " Recipe", Ingredents: Ingredients(captions...)}
+}
+
+// ImageURL is the themealdb image of the ingredient of the given file name,
+// "Butter" for https://www.themealdb.com/images/ingredients/Butter-medium.png.
+func ImageURL(name string) string {
+	return "https://www.themealdb.com/images/ingredients/" + name + "-medium.png"
+}
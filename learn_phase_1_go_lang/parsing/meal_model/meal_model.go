@@ -0,0 +1,108 @@
+package meal_model
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/step_parse"
+)
+
+// Meal is one entry of the meal list: a name and the link to its page.
+type Meal struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+// ID is the numeric meal ID in the last segment of Href, e.g. 52768 for
+// https://www.themealdb.com/meal/52768 or .../meal/52768-Apple-Frangipan-Tart,
+// or 0 if Href has none.
+func (m Meal) ID() int {
+	u, err := url.Parse(m.Href)
+	if err != nil {
+		return 0
+	}
+	last := path.Base(u.Path)
+	digits := strings.IndexFunc(last, func(r rune) bool { return r < '0' || r > '9' })
+	if digits == -1 {
+		digits = len(last)
+	}
+	id, err := strconv.Atoi(last[:digits])
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+type MealDetail struct {
+	ID        int    `json:"id,omitempty"`         // numeric meal ID, e.g. 52768
+	SourceURL string `json:"source_url,omitempty"` // canonical URL of the meal page
+	Receipt   string `json:"receipt"`
+	// Steps is Receipt split into numbered steps, with the times and oven
+	// temperatures found in each
+	Steps       []step_parse.Step `json:"steps,omitempty"`
+	Flag        string            `json:"flag"`
+	FlagURL     string            `json:"flag_url,omitempty"`
+	Ingredents  []Ingredient      `json:"ingredents"`
+	ReceiptName string            `json:"receipt_name"`
+	Category    string            `json:"category,omitempty"`
+	Area        string            `json:"area,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	YouTube     string            `json:"youtube,omitempty"`
+	// RecipeSource is the site the recipe was originally published on
+	RecipeSource string `json:"recipe_source,omitempty"`
+	Thumbnail    string `json:"thumbnail,omitempty"`
+	// FlagPath and ThumbnailPath are the mirrored copies of FlagURL and
+	// Thumbnail, relative to the output directory
+	FlagPath      string     `json:"flag_path,omitempty"`
+	ThumbnailPath string     `json:"thumbnail_path,omitempty"`
+	Crawl         *CrawlInfo `json:"crawl,omitempty"`
+}
+
+// CrawlInfo records the response a MealDetail was extracted from. Details
+// crawled before it was added have none.
+type CrawlInfo struct {
+	FetchedAt   time.Time `json:"fetched_at"`
+	HTTPStatus  int       `json:"http_status"`
+	ContentHash string    `json:"content_hash"` // see detail_parse.hashDetails
+}
+type Ingredient struct {
+	ImageURL  string `json:"image_url"`
+	ImagePath string `json:"image_path,omitempty"` // mirrored copy of ImageURL
+	Caption   string `json:"caption"`
+	// IngredientID is the entry of the ingredient catalog, set by its
+	// normalization pass
+	IngredientID string `json:"ingredient_id,omitempty"`
+	// Quantity, unit, name and note parsed from Caption
+	ingredient_parse.Parsed
+}
+
+// DisplayName is the meal name without the " Recipe" suffix of og:title.
+func (m MealDetail) DisplayName() string {
+	return strings.TrimSpace(strings.TrimSuffix(m.ReceiptName, " Recipe"))
+}
+
+// Country names the country of the flag, e.g. "France" for "fr". Flags
+// themealdb doesn't use fall back to the area, then to the flag itself.
+func (m MealDetail) Country() string {
+	if name, ok := countryNames[strings.ToLower(m.Flag)]; ok {
+		return name
+	}
+	if m.Area != "" {
+		return m.Area
+	}
+	return strings.ToUpper(m.Flag)
+}
+
+// countryNames names the flags themealdb uses.
+var countryNames = map[string]string{
+	"ca": "Canada", "cn": "China", "eg": "Egypt", "es": "Spain", "fr": "France", "gb": "United Kingdom",
+	"gr": "Greece", "hr": "Croatia", "ie": "Ireland", "in": "India", "it": "Italy", "jm": "Jamaica",
+	"jp": "Japan", "kn": "Saint Kitts and Nevis", "ke": "Kenya", "ma": "Morocco", "mx": "Mexico",
+	"my": "Malaysia", "nl": "Netherlands", "ph": "Philippines", "pl": "Poland", "pt": "Portugal",
+	"ru": "Russia", "sa": "Saudi Arabia", "sk": "Slovakia", "sy": "Syria", "th": "Thailand", "tn": "Tunisia",
+	"tr": "Turkey", "ua": "Ukraine", "us": "United States", "uy": "Uruguay", "vn": "Vietnam",
+}
@@ -0,0 +1,174 @@
+package output_sink
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/step_parse"
+)
+
+var (
+	listHeader = []string{"name", "href"}
+	mealHeader = []string{
+		"meal_no", "meal_id", "source_url", "receipt_name", "flag", "flag_url", "flag_path", "receipt",
+		"category", "area", "tags", "youtube", "recipe_source", "thumbnail", "thumbnail_path",
+		"fetched_at", "http_status", "content_hash",
+	}
+	ingredientHeader = []string{
+		"meal_no", "position", "caption", "image_url", "image_path",
+		"quantity", "quantity_max", "unit", "alternate_quantity", "alternate_unit",
+		"name", "note", "ingredient_id",
+	}
+	stepHeader = []string{
+		"meal_no", "position", "text", "min_seconds", "max_seconds",
+		"celsius", "fahrenheit", "fan_celsius", "gas_mark",
+	}
+)
+
+// csvSink flattens the records into CSV files: items.csv for the list, and
+// meals.csv plus ingredients.csv and steps.csv for the details, joined on
+// meal_no. A step row has the total time of the step and its first oven
+// temperature, see stepColumns.
+type csvSink struct {
+	files  []*os.File
+	meals  *csv.Writer // items.csv or meals.csv
+	ingr   *csv.Writer
+	steps  *csv.Writer
+	mealNo int
+}
+
+func newCSVSink(dir string, k kind) (*csvSink, error) {
+	s := &csvSink{}
+	if k == list {
+		w, err := s.create(filepath.Join(dir, "items.csv"), listHeader)
+		if err != nil {
+			return nil, err
+		}
+		s.meals = w
+		return s, nil
+	}
+	w, err := s.create(filepath.Join(dir, "meals.csv"), mealHeader)
+	if err != nil {
+		return nil, err
+	}
+	s.meals = w
+	if s.ingr, err = s.create(filepath.Join(dir, "ingredients.csv"), ingredientHeader); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if s.steps, err = s.create(filepath.Join(dir, "steps.csv"), stepHeader); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvSink) create(path string, header []string) (*csv.Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, file)
+	w := csv.NewWriter(file)
+	return w, w.Write(header)
+}
+
+func (s *csvSink) WriteMeal(meal main_parse.Meal) error {
+	if err := s.meals.Write([]string{meal.Name, meal.Href}); err != nil {
+		return err
+	}
+	s.meals.Flush()
+	return s.meals.Error()
+}
+
+func (s *csvSink) WriteDetail(detail detail_parse.MealDetail) error {
+	s.mealNo++
+	no := strconv.Itoa(s.mealNo)
+	fetchedAt, status, hash := "", "", ""
+	if c := detail.Crawl; c != nil {
+		fetchedAt, status, hash = c.FetchedAt.Format(time.RFC3339), strconv.Itoa(c.HTTPStatus), c.ContentHash
+	}
+	row := []string{
+		no, formatID(detail.ID), detail.SourceURL, detail.ReceiptName, detail.Flag, detail.FlagURL, detail.FlagPath, detail.Receipt,
+		detail.Category, detail.Area, strings.Join(detail.Tags, ","), detail.YouTube, detail.RecipeSource, detail.Thumbnail, detail.ThumbnailPath,
+		fetchedAt, status, hash,
+	}
+	if err := s.meals.Write(row); err != nil {
+		return err
+	}
+	for i, ing := range detail.Ingredents {
+		altQuantity, altUnit := "", ""
+		if ing.Alternate != nil {
+			altQuantity, altUnit = formatFloat(ing.Alternate.Quantity), ing.Alternate.Unit
+		}
+		err := s.ingr.Write([]string{
+			no, strconv.Itoa(i + 1), ing.Caption, ing.ImageURL, ing.ImagePath,
+			formatFloat(ing.Quantity), formatFloat(ing.QuantityMax), ing.Unit, altQuantity, altUnit,
+			ing.Name, ing.Note, ing.IngredientID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, step := range detail.Steps {
+		total, temp := stepColumns(step)
+		err := s.steps.Write([]string{
+			no, strconv.Itoa(step.Number), step.Text, formatID(total.MinSeconds), formatID(total.MaxSeconds),
+			formatID(temp.Celsius), formatID(temp.Fahrenheit), formatID(temp.FanCelsius), formatFloat(temp.GasMark),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.meals.Flush()
+	s.ingr.Flush()
+	s.steps.Flush()
+	return errors.Join(s.meals.Error(), s.ingr.Error(), s.steps.Error())
+}
+
+func (s *csvSink) Close() error {
+	var errs []error
+	for _, w := range []*csv.Writer{s.meals, s.ingr, s.steps} {
+		if w != nil {
+			w.Flush()
+			errs = append(errs, w.Error())
+		}
+	}
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// stepColumns is what a flat step row holds: the total time of the step and
+// its first oven temperature. The json and jsonl sinks keep every one.
+func stepColumns(step step_parse.Step) (step_parse.Duration, step_parse.Temperature) {
+	var temp step_parse.Temperature
+	if len(step.Temperatures) > 0 {
+		temp = step.Temperatures[0]
+	}
+	return step.Total(), temp
+}
+
+// formatID leaves a missing meal ID or number empty instead of writing 0.
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// formatFloat leaves missing quantities empty instead of writing 0.
+func formatFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
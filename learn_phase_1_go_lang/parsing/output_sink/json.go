@@ -0,0 +1,43 @@
+package output_sink
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+)
+
+// jsonSink writes one pretty printed JSON array, the original output format.
+// JSON arrays can't be streamed, so the records are kept until Close.
+type jsonSink struct {
+	path  string
+	items []any
+}
+
+func newJSONSink(dir string, k kind) *jsonSink {
+	name := "items.json"
+	if k == details {
+		name = "final_items.json"
+	}
+	return &jsonSink{path: filepath.Join(dir, name), items: []any{}}
+}
+
+func (s *jsonSink) WriteMeal(meal main_parse.Meal) error {
+	s.items = append(s.items, meal)
+	return nil
+}
+
+func (s *jsonSink) WriteDetail(detail detail_parse.MealDetail) error {
+	s.items = append(s.items, detail)
+	return nil
+}
+
+func (s *jsonSink) Close() error {
+	jsonData, err := json.MarshalIndent(s.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, jsonData, 0644)
+}
@@ -0,0 +1,40 @@
+package output_sink
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+)
+
+// jsonlSink writes one JSON object per line as soon as it arrives.
+type jsonlSink struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+func newJSONLSink(dir string, k kind) (*jsonlSink, error) {
+	name := "items.jsonl"
+	if k == details {
+		name = "final_items.jsonl"
+	}
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (s *jsonlSink) WriteMeal(meal main_parse.Meal) error {
+	return s.enc.Encode(meal)
+}
+
+func (s *jsonlSink) WriteDetail(detail detail_parse.MealDetail) error {
+	return s.enc.Encode(detail)
+}
+
+func (s *jsonlSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,70 @@
+package output_sink
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+)
+
+// sink is implemented by every format. It is opened for one kind and only
+// handed out as the main_parse.MealSink or detail_parse.DetailSink of it.
+type sink interface {
+	main_parse.MealSink
+	detail_parse.DetailSink
+}
+
+// kind says which phase of the crawl a sink is opened for.
+type kind int
+
+const (
+	list    kind = iota // the meal list from the letter pages
+	details             // the meal details
+)
+
+// Formats lists every supported output format, the first one is the default.
+var Formats = []string{"json", "jsonl", "csv", "sqlite", "postgres"}
+
+type Config struct {
+	Format string // one of Formats
+	Dir    string // output directory of the file based formats
+	DSN    string // PostgreSQL connection string
+}
+
+// OpenMeals creates the sink of the meal list for cfg.Format, items.* in
+// the file based formats like the original output.
+func OpenMeals(cfg Config) (main_parse.MealSink, error) {
+	return open(cfg, list)
+}
+
+// OpenDetails creates the sink of the meal details for cfg.Format,
+// final_items.* in the file based formats like the original output.
+func OpenDetails(cfg Config) (detail_parse.DetailSink, error) {
+	return open(cfg, details)
+}
+
+func open(cfg Config, k kind) (sink, error) {
+	if cfg.Format != "postgres" {
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	switch cfg.Format {
+	case "json":
+		return newJSONSink(cfg.Dir, k), nil
+	case "jsonl":
+		return newJSONLSink(cfg.Dir, k)
+	case "csv":
+		return newCSVSink(cfg.Dir, k)
+	case "sqlite":
+		return newSQLSink(sqliteDialect, sqlitePath(cfg.Dir), k)
+	case "postgres":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("the postgres format needs a connection string")
+		}
+		return newSQLSink(postgresDialect, cfg.DSN, k)
+	}
+	return nil, fmt.Errorf("unknown output format %q (want one of %s)", cfg.Format, strings.Join(Formats, ", "))
+}
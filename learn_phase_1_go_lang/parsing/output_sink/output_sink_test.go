@@ -0,0 +1,210 @@
+package output_sink
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/step_parse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []main_parse.Meal{
+	{Name: "Apple Frangipan Tart", Href: "https://www.themealdb.com/meal/52768"},
+	{Name: "Bakewell tart", Href: "https://www.themealdb.com/meal/52767"},
+}
+
+var testDetails = []detail_parse.MealDetail{
+	{
+		ID:            52768,
+		SourceURL:     "https://www.themealdb.com/meal/52768",
+		Crawl:         &detail_parse.CrawlInfo{FetchedAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), HTTPStatus: 200, ContentHash: "abc123"},
+		Receipt:       "Preheat the oven to 200C/180C Fan/Gas 6.\nBake for 20-25 minutes.",
+		Steps:         step_parse.Parse("Preheat the oven to 200C/180C Fan/Gas 6.\nBake for 20-25 minutes."),
+		Flag:          "gb",
+		FlagURL:       "https://www.themealdb.com/images/icons/flags/big/64/gb.png",
+		FlagPath:      "images/0b7e.png",
+		ReceiptName:   "Apple Frangipan Tart Recipe",
+		Category:      "Dessert",
+		Area:          "British",
+		Tags:          []string{"Tart", "Baking"},
+		YouTube:       "https://www.youtube.com/watch?v=rp8Slv4INLk",
+		Thumbnail:     "https://www.themealdb.com/images/media/meals/wxywrq1468235067.jpg",
+		ThumbnailPath: "images/c3d9.jpg",
+		Ingredents: []detail_parse.Ingredient{
+			{ImageURL: "https://www.themealdb.com/images/ingredients/butter-medium.png", ImagePath: "images/5f1a.png", Caption: "75g/3oz butter", IngredientID: "butter", Parsed: ingredient_parse.Parse("75g/3oz butter")},
+			{ImageURL: "https://www.themealdb.com/images/ingredients/free-range_eggs,_beaten-medium.png", Caption: "2 free-range eggs, beaten", Parsed: ingredient_parse.Parse("2 free-range eggs, beaten")},
+		},
+	},
+	{Receipt: "Bake it.", Steps: step_parse.Parse("Bake it."), Flag: "gb", ReceiptName: "Bakewell tart Recipe"},
+}
+
+func writeAll(t *testing.T, cfg Config) {
+	list, err := OpenMeals(cfg)
+	assert.NoError(t, err)
+	for _, meal := range testMeals {
+		assert.NoError(t, list.WriteMeal(meal))
+	}
+	assert.NoError(t, list.Close())
+
+	details, err := OpenDetails(cfg)
+	assert.NoError(t, err)
+	for _, detail := range testDetails {
+		assert.NoError(t, details.WriteDetail(detail))
+	}
+	assert.NoError(t, details.Close())
+}
+
+func TestJSONSink(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, Config{Format: "json", Dir: dir})
+
+	meals, err := main_parse.LoadMeals(filepath.Join(dir, "items.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, testMeals, meals)
+
+	details, err := detail_parse.LoadFinalMeals(filepath.Join(dir, "final_items.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, testDetails, details)
+}
+
+func TestJSONLSink(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, Config{Format: "jsonl", Dir: dir})
+
+	meals, err := main_parse.LoadMeals(filepath.Join(dir, "items.jsonl"))
+	assert.NoError(t, err)
+	assert.Equal(t, testMeals, meals)
+
+	data, err := os.ReadFile(filepath.Join(dir, "final_items.jsonl"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	var first detail_parse.MealDetail
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, testDetails[0], first)
+}
+
+func TestCSVSink(t *testing.T) {
+	dir := t.TempDir()
+	writeAll(t, Config{Format: "csv", Dir: dir})
+
+	read := func(name string) [][]string {
+		f, err := os.Open(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		assert.NoError(t, err)
+		return rows
+	}
+	assert.Equal(t, [][]string{listHeader, {testMeals[0].Name, testMeals[0].Href}, {testMeals[1].Name, testMeals[1].Href}}, read("items.csv"))
+
+	meals := read("meals.csv")
+	assert.Len(t, meals, 3)
+	assert.Equal(t, []string{
+		"1", "52768", "https://www.themealdb.com/meal/52768", "Apple Frangipan Tart Recipe",
+		"gb", "https://www.themealdb.com/images/icons/flags/big/64/gb.png", "images/0b7e.png", "Preheat the oven to 200C/180C Fan/Gas 6.\nBake for 20-25 minutes.",
+		"Dessert", "British", "Tart,Baking", "https://www.youtube.com/watch?v=rp8Slv4INLk", "", "https://www.themealdb.com/images/media/meals/wxywrq1468235067.jpg", "images/c3d9.jpg",
+		"2026-10-17T12:00:00Z", "200", "abc123",
+	}, meals[1])
+	assert.Equal(t, []string{"2", "", "", "Bakewell tart Recipe", "gb", "", "", "Bake it.", "", "", "", "", "", "", "", "", "", ""}, meals[2])
+
+	ingredients := read("ingredients.csv")
+	assert.Len(t, ingredients, 3)
+	assert.Equal(t, []string{"1", "1", "75g/3oz butter", "https://www.themealdb.com/images/ingredients/butter-medium.png", "images/5f1a.png", "75", "", "g", "3", "oz", "butter", "", "butter"}, ingredients[1])
+	assert.Equal(t, []string{"1", "2", "2 free-range eggs, beaten", "https://www.themealdb.com/images/ingredients/free-range_eggs,_beaten-medium.png", "", "2", "", "", "", "", "free-range eggs", "beaten", ""}, ingredients[2])
+
+	assert.Equal(t, [][]string{
+		stepHeader,
+		{"1", "1", "Preheat the oven to 200C/180C Fan/Gas 6.", "", "", "200", "390", "180", "6"},
+		{"1", "2", "Bake for 20-25 minutes.", "1200", "1500", "", "", "", ""},
+		{"2", "1", "Bake it.", "", "", "", "", "", ""},
+	}, read("steps.csv"))
+}
+
+func TestSQLiteSink(t *testing.T) {
+	dir := t.TempDir()
+	// Writing twice must replace the first run, not add to it
+	writeAll(t, Config{Format: "sqlite", Dir: dir})
+	writeAll(t, Config{Format: "sqlite", Dir: dir})
+
+	db, err := sql.Open("sqlite", sqlitePath(dir))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	count := func(table string) int {
+		var n int
+		assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 2, count("meal_list"))
+	assert.Equal(t, 2, count("meals"))
+	assert.Equal(t, 2, count("ingredients"))
+
+	var name, note string
+	var quantity float64
+	var unit sql.NullString
+	err = db.QueryRow(`SELECT i.name, i.note, i.quantity, i.unit FROM ingredients i
+		JOIN meals m ON m.meal_no = i.meal_no
+		WHERE m.receipt_name = 'Apple Frangipan Tart Recipe' AND i.position = 2`).Scan(&name, &note, &quantity, &unit)
+	assert.NoError(t, err)
+	assert.Equal(t, "free-range eggs", name)
+	assert.Equal(t, "beaten", note)
+	assert.Equal(t, 2.0, quantity)
+	assert.False(t, unit.Valid)
+
+	var id, status int
+	var source, hash, tags string
+	var recipeSource sql.NullString
+	err = db.QueryRow(`SELECT meal_id, source_url, http_status, content_hash, tags, recipe_source FROM meals WHERE meal_no = 1`).
+		Scan(&id, &source, &status, &hash, &tags, &recipeSource)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tart,Baking", tags)
+	assert.False(t, recipeSource.Valid)
+	assert.Equal(t, 52768, id)
+	assert.Equal(t, "https://www.themealdb.com/meal/52768", source)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "abc123", hash)
+
+	var flagPath, imagePath string
+	err = db.QueryRow(`SELECT m.flag_path, i.image_path FROM meals m
+		JOIN ingredients i ON i.meal_no = m.meal_no AND i.position = 1 WHERE m.meal_no = 1`).Scan(&flagPath, &imagePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "images/0b7e.png", flagPath)
+	assert.Equal(t, "images/5f1a.png", imagePath)
+
+	assert.Equal(t, 3, count("steps"))
+	var minSeconds, maxSeconds int
+	err = db.QueryRow(`SELECT min_seconds, max_seconds FROM steps WHERE meal_no = 1 AND position = 2`).Scan(&minSeconds, &maxSeconds)
+	assert.NoError(t, err)
+	assert.Equal(t, 1200, minSeconds)
+	assert.Equal(t, 1500, maxSeconds)
+}
+
+func TestSQLiteSink_OldSchema(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite", sqlitePath(dir))
+	assert.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE meals (meal_no INTEGER PRIMARY KEY, receipt_name TEXT NOT NULL, flag TEXT NOT NULL, receipt TEXT NOT NULL)`)
+	assert.NoError(t, err)
+	db.Close()
+
+	writeAll(t, Config{Format: "sqlite", Dir: dir})
+}
+
+func TestOpen_Errors(t *testing.T) {
+	_, err := OpenMeals(Config{Format: "xml", Dir: t.TempDir()})
+	assert.ErrorContains(t, err, "unknown output format")
+
+	_, err = OpenDetails(Config{Format: "postgres"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+package output_sink
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// dialect holds what differs between SQLite and PostgreSQL.
+type dialect struct {
+	driver string
+	// setup runs before the tables are created
+	setup []string
+	// prefix is put in front of every table name
+	prefix string
+	real   string
+	time   string
+	// bind returns the placeholder of the n-th argument, starting at 1
+	bind func(n int) string
+}
+
+var sqliteDialect = dialect{
+	driver: "sqlite",
+	real:   "REAL",
+	time:   "TIMESTAMP",
+	bind:   func(int) string { return "?" },
+}
+
+// postgresDialect keeps the crawl in its own schema, away from the tables of
+// the quiz server that may live in the same database.
+var postgresDialect = dialect{
+	driver: "postgres",
+	setup:  []string{"CREATE SCHEMA IF NOT EXISTS themealdb"},
+	prefix: "themealdb.",
+	real:   "DOUBLE PRECISION",
+	time:   "TIMESTAMPTZ",
+	bind:   func(n int) string { return fmt.Sprintf("$%d", n) },
+}
+
+func sqlitePath(dir string) string {
+	return filepath.Join(dir, "meals.db")
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS {p}meal_list (
+	position INTEGER PRIMARY KEY,
+	name     TEXT NOT NULL,
+	href     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS {p}meals (
+	meal_no        INTEGER PRIMARY KEY,
+	meal_id        INTEGER,
+	source_url     TEXT,
+	receipt_name   TEXT NOT NULL,
+	flag           TEXT NOT NULL,
+	flag_url       TEXT,
+	flag_path      TEXT,
+	receipt        TEXT NOT NULL,
+	category       TEXT,
+	area           TEXT,
+	tags           TEXT,
+	youtube        TEXT,
+	recipe_source  TEXT,
+	thumbnail      TEXT,
+	thumbnail_path TEXT,
+	fetched_at     {time},
+	http_status    INTEGER,
+	content_hash   TEXT
+);
+CREATE TABLE IF NOT EXISTS {p}ingredients (
+	meal_no            INTEGER NOT NULL REFERENCES {p}meals (meal_no),
+	position           INTEGER NOT NULL,
+	caption            TEXT NOT NULL,
+	image_url          TEXT NOT NULL,
+	image_path         TEXT,
+	quantity           {real},
+	quantity_max       {real},
+	unit               TEXT,
+	alternate_quantity {real},
+	alternate_unit     TEXT,
+	name               TEXT NOT NULL,
+	note               TEXT,
+	ingredient_id      TEXT,
+	PRIMARY KEY (meal_no, position)
+);
+CREATE TABLE IF NOT EXISTS {p}steps (
+	meal_no     INTEGER NOT NULL REFERENCES {p}meals (meal_no),
+	position    INTEGER NOT NULL,
+	text        TEXT NOT NULL,
+	min_seconds INTEGER,
+	max_seconds INTEGER,
+	celsius     INTEGER,
+	fahrenheit  INTEGER,
+	fan_celsius INTEGER,
+	gas_mark    {real},
+	PRIMARY KEY (meal_no, position)
+);`
+
+// sqlSink inserts every record as it arrives, one transaction per meal.
+// Opening it clears the tables of its kind, so each run replaces the last.
+// The detail tables are dropped and created again, which also brings a
+// database written by an older version up to the current columns.
+type sqlSink struct {
+	db    *sql.DB
+	d     dialect
+	kind  kind
+	count int
+}
+
+func newSQLSink(d dialect, dsn string, k kind) (*sqlSink, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqlSink{db: db, d: d, kind: k}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlSink) init() error {
+	ddl := strings.NewReplacer("{p}", s.d.prefix, "{real}", s.d.real, "{time}", s.d.time).Replace(schema)
+	stmts := append([]string{}, s.d.setup...)
+	if s.kind == details {
+		stmts = append(stmts, "DROP TABLE IF EXISTS "+s.d.prefix+"steps", "DROP TABLE IF EXISTS "+s.d.prefix+"ingredients", "DROP TABLE IF EXISTS "+s.d.prefix+"meals")
+	}
+	for _, stmt := range strings.Split(ddl, ";") {
+		if strings.TrimSpace(stmt) != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	if s.kind == list {
+		stmts = append(stmts, "DELETE FROM "+s.d.prefix+"meal_list")
+	}
+	for _, stmt := range stmts {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert builds an INSERT statement with the placeholders of the dialect.
+func (s *sqlSink) insert(table string, columns ...string) string {
+	binds := make([]string, len(columns))
+	for i := range columns {
+		binds[i] = s.d.bind(i + 1)
+	}
+	return fmt.Sprintf("INSERT INTO %s%s (%s) VALUES (%s)",
+		s.d.prefix, table, strings.Join(columns, ", "), strings.Join(binds, ", "))
+}
+
+func (s *sqlSink) WriteMeal(meal main_parse.Meal) error {
+	s.count++
+	_, err := s.db.Exec(s.insert("meal_list", "position", "name", "href"), s.count, meal.Name, meal.Href)
+	return err
+}
+
+func (s *sqlSink) WriteDetail(detail detail_parse.MealDetail) error {
+	s.count++
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var fetchedAt sql.NullTime
+	var status sql.NullInt64
+	var hash sql.NullString
+	if c := detail.Crawl; c != nil {
+		fetchedAt = sql.NullTime{Time: c.FetchedAt, Valid: !c.FetchedAt.IsZero()}
+		status = sql.NullInt64{Int64: int64(c.HTTPStatus), Valid: c.HTTPStatus != 0}
+		hash = nullString(c.ContentHash)
+	}
+	_, err = tx.Exec(s.insert("meals",
+		"meal_no", "meal_id", "source_url", "receipt_name", "flag", "flag_url", "flag_path", "receipt",
+		"category", "area", "tags", "youtube", "recipe_source", "thumbnail", "thumbnail_path",
+		"fetched_at", "http_status", "content_hash"),
+		s.count, sql.NullInt64{Int64: int64(detail.ID), Valid: detail.ID != 0}, nullString(detail.SourceURL),
+		detail.ReceiptName, detail.Flag, nullString(detail.FlagURL), nullString(detail.FlagPath), detail.Receipt,
+		nullString(detail.Category), nullString(detail.Area), nullString(strings.Join(detail.Tags, ",")),
+		nullString(detail.YouTube), nullString(detail.RecipeSource), nullString(detail.Thumbnail), nullString(detail.ThumbnailPath),
+		fetchedAt, status, hash)
+	if err != nil {
+		return err
+	}
+	stmt := s.insert("ingredients",
+		"meal_no", "position", "caption", "image_url", "image_path",
+		"quantity", "quantity_max", "unit", "alternate_quantity", "alternate_unit",
+		"name", "note", "ingredient_id")
+	for i, ing := range detail.Ingredents {
+		var altQuantity sql.NullFloat64
+		var altUnit sql.NullString
+		if ing.Alternate != nil {
+			altQuantity = nullFloat(ing.Alternate.Quantity)
+			altUnit = nullString(ing.Alternate.Unit)
+		}
+		_, err := tx.Exec(stmt,
+			s.count, i+1, ing.Caption, ing.ImageURL, nullString(ing.ImagePath),
+			nullFloat(ing.Quantity), nullFloat(ing.QuantityMax), nullString(ing.Unit), altQuantity, altUnit,
+			ing.Name, nullString(ing.Note), nullString(ing.IngredientID))
+		if err != nil {
+			return err
+		}
+	}
+	stmt = s.insert("steps",
+		"meal_no", "position", "text", "min_seconds", "max_seconds",
+		"celsius", "fahrenheit", "fan_celsius", "gas_mark")
+	for _, step := range detail.Steps {
+		total, temp := stepColumns(step)
+		_, err := tx.Exec(stmt,
+			s.count, step.Number, step.Text, nullInt(total.MinSeconds), nullInt(total.MaxSeconds),
+			nullInt(temp.Celsius), nullInt(temp.Fahrenheit), nullInt(temp.FanCelsius), nullFloat(temp.GasMark))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlSink) Close() error {
+	return s.db.Close()
+}
+
+func nullFloat(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: v != 0}
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
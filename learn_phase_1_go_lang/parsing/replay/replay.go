@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Mode says whether the crawler talks to the live site and what it does
+// with the fixtures directory.
+type Mode string
+
+const (
+	Live   Mode = "live"   // plain HTTP, fixtures are not touched
+	Record Mode = "record" // plain HTTP, every response is saved as a fixture
+	Replay Mode = "replay" // no network, every response comes from a fixture
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case Live, Record, Replay:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown replay mode %q (want live, record or replay)", s)
+}
+
+// meta is stored next to a fixture body. It is optional: a fixture without
+// it replays as a 200 text/html response, so fixtures can be written by hand.
+type meta struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// Transport is an http.RoundTripper for the colly collectors that records
+// responses to, or replays them from, a fixtures directory.
+type Transport struct {
+	Mode Mode
+	Dir  string
+	// Next does the real requests in Live and Record mode,
+	// http.DefaultTransport when nil.
+	Next http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.Mode {
+	case Replay:
+		return t.replay(req)
+	case Record:
+		return t.record(req)
+	}
+	return t.next().RoundTrip(req)
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	res, err := t.next().RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	path, err := FixturePath(t.Dir, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return nil, err
+	}
+	header := res.Header.Clone()
+	// The body is saved decoded, the recorded length may not match any more
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	m, _ := json.MarshalIndent(meta{Status: res.StatusCode, Header: header}, "", "  ")
+	return res, os.WriteFile(metaPath(path), m, 0644)
+}
+
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	path, err := FixturePath(t.Dir, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no fixture for %s (looked for %s)", req.URL, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := meta{Status: http.StatusOK, Header: http.Header{"Content-Type": {"text/html; charset=utf-8"}}}
+	if data, err := os.ReadFile(metaPath(path)); err == nil {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", metaPath(path), err)
+		}
+	}
+	if m.Header == nil {
+		m.Header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", m.Status, http.StatusText(m.Status)),
+		StatusCode:    m.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        m.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// FixturePath maps a URL to its fixture file, e.g.
+// https://www.themealdb.com/meal/52768 to <dir>/www.themealdb.com/meal/52768.html.
+// The ".." in a path or query don't lead out of the host's directory; a
+// host that would lead out of dir is an error.
+func FixturePath(dir string, u *url.URL) (string, error) {
+	p := strings.Trim(u.EscapedPath(), "/")
+	if p == "" {
+		p = "index"
+	}
+	if u.RawQuery != "" {
+		p += "_" + u.RawQuery
+	}
+	p = strings.Map(func(r rune) rune {
+		switch r {
+		case '?', '&', '=', ':', '*', '"', '<', '>', '|', '\\':
+			return '_'
+		}
+		return r
+	}, p)
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		p = "index"
+	}
+	rel := filepath.Join(u.Host, filepath.FromSlash(p)) + ".html"
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("no fixture path for %s, it would be outside %s", u, dir)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+func metaPath(fixture string) string {
+	return strings.TrimSuffix(fixture, ".html") + ".meta.json"
+}
@@ -0,0 +1,77 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixturePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.themealdb.com/meal/52768", filepath.Join("fx", "www.themealdb.com", "meal", "52768.html")},
+		{"https://www.themealdb.com/", filepath.Join("fx", "www.themealdb.com", "index.html")},
+		{"https://www.themealdb.com/search?q=tart&page=2", filepath.Join("fx", "www.themealdb.com", "search_q_tart_page_2.html")},
+		// ".." stays inside the host's directory
+		{"https://www.themealdb.com/meal/../../../etc/passwd", filepath.Join("fx", "www.themealdb.com", "etc", "passwd.html")},
+		{"https://www.themealdb.com/?next=/../../up", filepath.Join("fx", "www.themealdb.com", "up.html")},
+		{"https://www.themealdb.com/..", filepath.Join("fx", "www.themealdb.com", "index.html")},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		assert.NoError(t, err)
+		got, err := FixturePath("fx", u)
+		assert.NoError(t, err, tt.url)
+		assert.Equal(t, tt.want, got, tt.url)
+	}
+
+	u, err := url.Parse("http://../meal/52768")
+	assert.NoError(t, err)
+	_, err = FixturePath("fx", u)
+	assert.Error(t, err)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "<html>"+r.URL.Path+"</html>")
+	}))
+	dir := t.TempDir()
+
+	client := &http.Client{Transport: &Transport{Mode: Record, Dir: dir}}
+	res, err := client.Get(origin.URL + "/meal/52768")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "<html>/meal/52768</html>", string(body))
+
+	// Replaying must not need the origin any more
+	origin.Close()
+	client = &http.Client{Transport: &Transport{Mode: Replay, Dir: dir}}
+	res, err = client.Get(origin.URL + "/meal/52768")
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, `"v1"`, res.Header.Get("ETag"))
+	assert.Equal(t, "<html>/meal/52768</html>", string(body))
+
+	_, err = client.Get(origin.URL + "/meal/1")
+	assert.ErrorContains(t, err, "no fixture")
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("Replay")
+	assert.NoError(t, err)
+	assert.Equal(t, Replay, m)
+	_, err = ParseMode("offline")
+	assert.Error(t, err)
+}
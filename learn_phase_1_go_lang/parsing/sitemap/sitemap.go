@@ -0,0 +1,119 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go_lang/parsing/crawl_pool"
+
+	"github.com/gocolly/colly"
+)
+
+// maxSitemaps caps how many sitemap files Discover reads, indexes included,
+// so a site with sitemaps linking in circles can't keep it busy.
+const maxSitemaps = 100
+
+// document is a sitemap (<urlset>) or a sitemap index (<sitemapindex>), see
+// https://www.sitemaps.org/protocol.html.
+type document struct {
+	XMLName  xml.Name
+	URLs     []loc `xml:"url"`
+	Sitemaps []loc `xml:"sitemap"`
+}
+
+type loc struct {
+	Loc string `xml:"loc"`
+}
+
+// Parse reads a sitemap, gzipped or not. It returns the pages of a
+// <urlset>, or the sitemaps a <sitemapindex> points to.
+func Parse(data []byte) (pages, sitemaps []string, err error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, nil, err
+		}
+	}
+	var doc document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse sitemap: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		return locs(doc.URLs), nil, nil
+	case "sitemapindex":
+		return nil, locs(doc.Sitemaps), nil
+	}
+	return nil, nil, fmt.Errorf("parse sitemap: unknown root element <%s>", doc.XMLName.Local)
+}
+
+func locs(entries []loc) []string {
+	var urls []string
+	for _, e := range entries {
+		if u := strings.TrimSpace(e.Loc); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// Discover reads the sitemaps at roots with the pool, following sitemap
+// indexes, and returns the pages they list in order, each once. Sitemaps
+// that fail are skipped and reported in the returned error, next to the
+// pages of the others.
+func Discover(ctx context.Context, pool *crawl_pool.Pool, roots []string) ([]string, error) {
+	var pages []string
+	var errs []error
+	seenPage := map[string]bool{}
+	seenSitemap := map[string]bool{}
+	queue := append([]string(nil), roots...)
+	read := 0
+	for len(queue) > 0 {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if seenSitemap[sitemapURL] {
+			continue
+		}
+		seenSitemap[sitemapURL] = true
+		if read == maxSitemaps {
+			errs = append(errs, fmt.Errorf("stopped after %d sitemaps", maxSitemaps))
+			break
+		}
+		read++
+
+		var body []byte
+		c := pool.Collector()
+		c.OnResponse(func(r *colly.Response) {
+			body = r.Body
+		})
+		if err := pool.Visit(ctx, c, sitemapURL); errors.Is(err, crawl_pool.ErrStopped) {
+			return pages, err
+		} else if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		found, children, err := Parse(body)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sitemapURL, err))
+			continue
+		}
+		pool.Log().Debug("sitemap read", "url", sitemapURL, "pages", len(found), "sitemaps", len(children))
+		queue = append(queue, children...)
+		for _, page := range found {
+			if !seenPage[page] {
+				seenPage[page] = true
+				pages = append(pages, page)
+			}
+		}
+	}
+	return pages, errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go_lang/parsing/crawl_pool"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := io.WriteString(w, s)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	pages, sitemaps, err := Parse([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://www.themealdb.com/meal/52768</loc><lastmod>2026-10-01</lastmod></url>
+  <url><loc>
+    https://www.themealdb.com/meal/53049
+  </loc></url>
+</urlset>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://www.themealdb.com/meal/52768", "https://www.themealdb.com/meal/53049"}, pages)
+	assert.Empty(t, sitemaps)
+
+	pages, sitemaps, err = Parse(gzipped(t, `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://www.themealdb.com/sitemap-meals.xml.gz</loc></sitemap>
+</sitemapindex>`))
+	assert.NoError(t, err)
+	assert.Empty(t, pages)
+	assert.Equal(t, []string{"https://www.themealdb.com/sitemap-meals.xml.gz"}, sitemaps)
+
+	_, _, err = Parse([]byte(`<html><body>Not found</body></html>`))
+	assert.ErrorContains(t, err, "unknown root element <html>")
+}
+
+func TestDiscover(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.xml":
+			// Links to itself too, it must be read only once
+			io.WriteString(w, `<sitemapindex><sitemap><loc>`+srv.URL+`/meals.xml.gz</loc></sitemap>`+
+				`<sitemap><loc>`+srv.URL+`/index.xml</loc></sitemap><sitemap><loc>`+srv.URL+`/gone.xml</loc></sitemap></sitemapindex>`)
+		case "/meals.xml.gz":
+			w.Write(gzipped(t, `<urlset><url><loc>https://www.themealdb.com/meal/52768</loc></url>`+
+				`<url><loc>https://www.themealdb.com/meal/52768</loc></url><url><loc>https://www.themealdb.com/meal/53049</loc></url></urlset>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	pool, err := crawl_pool.New(crawl_pool.Config{})
+	assert.NoError(t, err)
+	pages, err := Discover(context.Background(), pool, []string{srv.URL + "/index.xml"})
+	assert.ErrorContains(t, err, "/gone.xml")
+	assert.Equal(t, []string{"https://www.themealdb.com/meal/52768", "https://www.themealdb.com/meal/53049"}, pages)
+}
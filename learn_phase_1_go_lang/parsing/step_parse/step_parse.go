@@ -0,0 +1,418 @@
+package step_parse
+
+import (
+	"cmp"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Step is one instruction of a recipe, in cooking order.
+type Step struct {
+	Number       int           `json:"number"` // 1 for the first step
+	Text         string        `json:"text"`
+	Durations    []Duration    `json:"durations,omitempty"`
+	Temperatures []Temperature `json:"temperatures,omitempty"`
+}
+
+// Duration is a time found in a step, e.g. "20-25 minutes". Min and Max are
+// equal when no range is given.
+type Duration struct {
+	MinSeconds int    `json:"min_seconds"`
+	MaxSeconds int    `json:"max_seconds"`
+	Text       string `json:"text"` // as written in the step
+}
+
+// Temperature is an oven setting found in a step. A setting is often given
+// in several ways at once, as in "200C/180C Fan/Gas 6"; they are kept
+// together. Celsius and Fahrenheit are always set, converted from whatever
+// the step gives and rounded to 5 degrees like oven dials are.
+type Temperature struct {
+	Celsius    int     `json:"celsius"`
+	Fahrenheit int     `json:"fahrenheit"`
+	FanCelsius int     `json:"fan_celsius,omitempty"`
+	GasMark    float64 `json:"gas_mark,omitempty"`
+	Text       string  `json:"text"` // as written in the step
+}
+
+var (
+	// stepMarkerRe matches the numbering some recipes put in front of their
+	// steps, as in "STEP 1", "Step 2:" or "3.".
+	stepMarkerRe = regexp.MustCompile(`(?i)^(?:step\s*\d+\s*[:.)\-–]?\s*|\d{1,2}\s*[.)]\s+)`)
+
+	number     = `\d+(?:\.\d+)?(?:\s?(?:[½¼¾⅓⅔]|\d+/\d+))?|[½¼¾⅓⅔]|half(?:\s+an)?|an?|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|fifteen|twenty|thirty|forty|sixty|ninety`
+	durationRe = regexp.MustCompile(`(?i)\b(` + number + `)(?:\s*(?:-|–|to|or)\s*(` + number + `))?` +
+		`(\s+(?:more|further|additional|extra))?(\s*)` +
+		`(?:(hours?|hrs?|h)\b(?:\s*(?:and\s+)?(\d+)\s*(?:minutes?|mins?)\b)?|(minutes?|mins?|seconds?|secs?)\b)`)
+
+	temperatureRe = regexp.MustCompile(`(?i)(\bfan\s?)?(\d{2,3})\s?(°\s?|º\s?|degrees?\s?)?(?:(celsius|celcius|fahrenheit|c|f)\b)?(\s?\(?fan\)?)?`)
+	gasRe         = regexp.MustCompile(`(?i)\bgas(?:\s+mark)?\s*(\d+\s?⁄\s?\d+|\d*[½¼¾]|\d+)`)
+	// separatorRe is what may stand between the parts of one oven setting
+	separatorRe = regexp.MustCompile(`(?i)^[\s/,()]*(?:or|conventional|fan)?[\s/,()]*$`)
+)
+
+var wordNumbers = map[string]float64{
+	"a": 1, "an": 1, "half": 0.5, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"fifteen": 15, "twenty": 20, "thirty": 30, "forty": 40, "sixty": 60, "ninety": 90,
+}
+
+var fractions = map[string]float64{"½": 0.5, "¼": 0.25, "¾": 0.75, "⅓": 1.0 / 3, "⅔": 2.0 / 3}
+
+// gasMarks maps a gas mark to its temperature in °C.
+var gasMarks = map[float64]int{
+	0.25: 110, 0.5: 120, 1: 140, 2: 150, 3: 170, 4: 180, 5: 190, 6: 200, 7: 220, 8: 230, 9: 240, 10: 260,
+}
+
+// Parse splits the instructions of a recipe into steps. Every non-blank
+// line is a step, unless the recipe numbers its steps with "STEP n" lines:
+// then the lines up to the next marker belong to the same step. Numbering
+// in front of a step is dropped, the step's Number replaces it.
+func Parse(receipt string) []Step {
+	receipt = strings.ReplaceAll(receipt, "\r\n", "\n")
+	lines := strings.Split(receipt, "\n")
+	markers := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), "step") && stepMarkerRe.MatchString(strings.TrimSpace(line)) {
+			markers = true
+			break
+		}
+	}
+
+	var texts []string
+	open := false // the last text takes more lines until the next marker
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		marker := stepMarkerRe.FindString(line)
+		line = strings.TrimSpace(line[len(marker):])
+		switch {
+		case markers && marker != "":
+			texts = append(texts, line)
+			open = true
+		case open:
+			texts[len(texts)-1] = strings.TrimSpace(texts[len(texts)-1] + "\n" + line)
+		case line != "":
+			texts = append(texts, line)
+		}
+	}
+
+	var steps []Step
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		steps = append(steps, Step{
+			Number:       len(steps) + 1,
+			Text:         text,
+			Durations:    Durations(text),
+			Temperatures: Temperatures(text),
+		})
+	}
+	return steps
+}
+
+// Total adds up the durations of the step, the time to set a timer to.
+// Its Text is empty.
+func (s Step) Total() Duration {
+	var total Duration
+	for _, d := range s.Durations {
+		total.MinSeconds += d.MinSeconds
+		total.MaxSeconds += d.MaxSeconds
+	}
+	return total
+}
+
+// Durations finds the cooking times in text, like "15 mins", "20-25
+// minutes", "1½ hours" or "1hr 30 minutes".
+func Durations(text string) []Duration {
+	var found []Duration
+	for _, m := range durationRe.FindAllStringSubmatchIndex(text, -1) {
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return text[m[2*i]:m[2*i+1]]
+		}
+		minText, maxText, filler, space := group(1), group(2), group(3), group(4)
+		unit := strings.ToLower(cmp.Or(group(5), group(7)))
+		word := unicode.IsLetter(rune(minText[0]))
+		// "ah" is no hour, "a second time" is no time at all
+		if word && (filler == "" && space == "" || unit == "h" || unit == "second") {
+			continue
+		}
+		lo, ok := parseNumber(minText)
+		if !ok {
+			continue
+		}
+		hi := lo
+		if maxText != "" {
+			if hi, ok = parseNumber(maxText); !ok || hi < lo {
+				continue
+			}
+		}
+		seconds := unitSeconds(unit)
+		extra := 0
+		if minutes := group(6); minutes != "" {
+			n, _ := strconv.Atoi(minutes)
+			extra = n * 60
+		}
+		found = append(found, Duration{
+			MinSeconds: int(math.Round(lo*seconds)) + extra,
+			MaxSeconds: int(math.Round(hi*seconds)) + extra,
+			Text:       text[m[0]:m[1]],
+		})
+	}
+	return found
+}
+
+func unitSeconds(unit string) float64 {
+	switch {
+	case strings.HasPrefix(unit, "h"):
+		return 3600
+	case strings.HasPrefix(unit, "m"):
+		return 60
+	}
+	return 1
+}
+
+// parseNumber reads "2", "2.5", "1½", "1 1/2", "½" or a number word.
+func parseNumber(s string) (float64, bool) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if n, ok := wordNumbers[s]; ok {
+		return n, true
+	}
+	if strings.HasPrefix(s, "half") {
+		return 0.5, true
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, true
+	}
+	s = strings.ReplaceAll(s, "⁄", "/")
+	whole, frac := "", s
+	if w, f, found := strings.Cut(s, " "); found {
+		whole, frac = w, f
+	} else if i := strings.IndexAny(s, "½¼¾⅓⅔"); i > 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	n := 0.0
+	if whole != "" {
+		w, err := strconv.Atoi(whole)
+		if err != nil {
+			return 0, false
+		}
+		n = float64(w)
+	}
+	if f, ok := fractions[frac]; ok {
+		return n + f, true
+	}
+	num, den, found := strings.Cut(frac, "/")
+	if !found {
+		return 0, false
+	}
+	a, err1 := strconv.Atoi(num)
+	b, err2 := strconv.Atoi(den)
+	if err1 != nil || err2 != nil || b == 0 || whole != "" && a >= b {
+		return 0, false
+	}
+	return n + float64(a)/float64(b), true
+}
+
+// mention is one way a temperature is written in a step, before the
+// mentions of one oven setting are put together.
+type mention struct {
+	start, end int
+	celsius    int // conventional °C
+	fahrenheit int
+	fan        int // fan °C
+	gas        float64
+}
+
+// Reading is one temperature as written in a step, as the "180C" of
+// "200C/180C fan". Start and End are the byte offsets of the degrees and
+// their unit in the text, without the fan words around them.
+type Reading struct {
+	Start, End int
+	Degrees    int
+	Unit       string // "C" or "F"
+	Fan        bool
+}
+
+// Readings finds the temperatures written in text one by one, read the way
+// Temperatures reads them. Gas marks are left out.
+func Readings(text string) []Reading {
+	var rs []Reading
+	for _, r := range readings(text) {
+		rs = append(rs, r.Reading)
+	}
+	return rs
+}
+
+// reading is a Reading with the span of its whole mention, fan words
+// included.
+type reading struct {
+	Reading
+	start, end int
+}
+
+func readings(text string) []reading {
+	var rs []reading
+	for _, m := range temperatureRe.FindAllStringSubmatchIndex(text, -1) {
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return text[m[2*i]:m[2*i+1]]
+		}
+		fanBefore, value, degrees, unit, fanAfter := group(1), group(2), group(3), strings.ToLower(group(4)), group(5)
+		// "x180C" is no temperature, "fan180C" is
+		if fanBefore == "" && m[4] > 0 && (unicode.IsLetter(rune(text[m[4]-1])) || unicode.IsDigit(rune(text[m[4]-1]))) {
+			continue
+		}
+		n, _ := strconv.Atoi(value)
+		if unit == "" && degrees == "" {
+			continue
+		}
+		end := m[9]
+		if unit == "" {
+			// "a 90º angle" is no oven
+			if n < 100 {
+				continue
+			}
+			unit = "c"
+			if n >= 250 {
+				unit = "f"
+			}
+			end = m[4] + len(strings.TrimRight(text[m[4]:m[7]], " \t"))
+		}
+		r := reading{Reading: Reading{Start: m[4], End: end, Degrees: n, Unit: "C"}, start: m[0], end: m[1]}
+		switch {
+		case unit == "f" || unit == "fahrenheit":
+			// Fan ovens are given in °C only
+			r.Unit = "F"
+		case fanBefore != "" || fanAfter != "":
+			r.Fan = true
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// Temperatures finds the oven settings in text. "180C", "350°F", "180
+// degrees Celsius", "fan 160C" and "gas mark 4" are understood, and so is a
+// bare "350 degrees", taken as °F from 250 degrees up and as °C below.
+func Temperatures(text string) []Temperature {
+	var mentions []mention
+	for _, r := range readings(text) {
+		m := mention{start: r.start, end: r.end}
+		switch {
+		case r.Unit == "F":
+			m.fahrenheit = r.Degrees
+		case r.Fan:
+			m.fan = r.Degrees
+		default:
+			m.celsius = r.Degrees
+		}
+		mentions = append(mentions, m)
+	}
+	for _, m := range gasRe.FindAllStringSubmatchIndex(text, -1) {
+		mark, ok := parseNumber(text[m[2]:m[3]])
+		if !ok {
+			continue
+		}
+		mentions = append(mentions, mention{start: m[0], end: m[1], gas: mark})
+	}
+	slices.SortFunc(mentions, func(a, b mention) int { return cmp.Compare(a.start, b.start) })
+
+	var temps []Temperature
+	var group []mention
+	flush := func() {
+		if t, ok := setting(text, group); ok {
+			temps = append(temps, t)
+		}
+		group = nil
+	}
+	for _, m := range mentions {
+		if len(group) > 0 {
+			last := group[len(group)-1]
+			if m.start < last.end || !separatorRe.MatchString(text[last.end:m.start]) || taken(group, m) {
+				flush()
+			}
+		}
+		group = append(group, m)
+	}
+	flush()
+	return temps
+}
+
+// taken reports whether the setting in group already has the kind of value
+// m gives, so m starts the next setting.
+func taken(group []mention, m mention) bool {
+	for _, g := range group {
+		if g.celsius != 0 && m.celsius != 0 || g.fahrenheit != 0 && m.fahrenheit != 0 ||
+			g.fan != 0 && m.fan != 0 || g.gas != 0 && m.gas != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setting puts the mentions of one oven setting together and fills in the
+// units it was not given in.
+func setting(text string, group []mention) (Temperature, bool) {
+	if len(group) == 0 {
+		return Temperature{}, false
+	}
+	var t Temperature
+	for _, m := range group {
+		t.Celsius += m.celsius
+		t.Fahrenheit += m.fahrenheit
+		t.FanCelsius += m.fan
+		t.GasMark += m.gas
+	}
+	if t.Celsius == 0 {
+		switch {
+		case t.Fahrenheit != 0:
+			t.Celsius = Celsius(t.Fahrenheit)
+		case t.GasMark != 0:
+			t.Celsius = gasMarks[t.GasMark]
+		case t.FanCelsius != 0:
+			// Fan ovens are set about 20 degrees lower
+			t.Celsius = t.FanCelsius + 20
+		}
+	}
+	if t.Celsius == 0 {
+		// a gas mark that isn't on the dial
+		return Temperature{}, false
+	}
+	if t.Fahrenheit == 0 {
+		t.Fahrenheit = Fahrenheit(t.Celsius)
+	}
+	end := group[len(group)-1].end
+	// Keep the bracket of "190C (375F)" closed
+	if end < len(text) && text[end] == ')' && strings.Count(text[group[0].start:end], "(") > strings.Count(text[group[0].start:end], ")") {
+		end++
+	}
+	t.Text = strings.TrimSpace(text[group[0].start:end])
+	return t, true
+}
+
+// Celsius converts degrees Fahrenheit to Celsius, rounded to 5 degrees like
+// oven dials are.
+func Celsius(fahrenheit int) int {
+	return roundTo5(float64(fahrenheit-32) * 5 / 9)
+}
+
+// Fahrenheit converts degrees Celsius to Fahrenheit, rounded to 5 degrees.
+func Fahrenheit(celsius int) int {
+	return roundTo5(float64(celsius)*9/5 + 32)
+}
+
+func roundTo5(x float64) int {
+	return int(math.Round(x/5) * 5)
+}
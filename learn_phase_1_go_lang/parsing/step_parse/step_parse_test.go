@@ -0,0 +1,155 @@
+package step_parse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	receipt := "Preheat the oven to 200C/180C Fan/Gas 6.\r\n" +
+		"Cream together the butter and sugar. Process for 2-3 minutes.\n\n" +
+		"Bake for 20-25 minutes until golden.\n"
+	steps := Parse(receipt)
+	assert.Equal(t, []Step{
+		{
+			Number: 1,
+			Text:   "Preheat the oven to 200C/180C Fan/Gas 6.",
+			Temperatures: []Temperature{
+				{Celsius: 200, Fahrenheit: 390, FanCelsius: 180, GasMark: 6, Text: "200C/180C Fan/Gas 6"},
+			},
+		},
+		{
+			Number:    2,
+			Text:      "Cream together the butter and sugar. Process for 2-3 minutes.",
+			Durations: []Duration{{MinSeconds: 120, MaxSeconds: 180, Text: "2-3 minutes"}},
+		},
+		{
+			Number:    3,
+			Text:      "Bake for 20-25 minutes until golden.",
+			Durations: []Duration{{MinSeconds: 1200, MaxSeconds: 1500, Text: "20-25 minutes"}},
+		},
+	}, steps)
+
+	assert.Empty(t, Parse(""))
+	assert.Empty(t, Parse("\n \n"))
+}
+
+func TestParse_Numbered(t *testing.T) {
+	// Every receipt below is shaped like one in output/final_items.json
+	steps := Parse("1. Roast the aubergine.\n2. Peel the skin.\n10. Add the chillies.")
+	assert.Len(t, steps, 3)
+	assert.Equal(t, "Peel the skin.", steps[1].Text)
+	assert.Equal(t, 3, steps[2].Number)
+	assert.Equal(t, "Add the chillies.", steps[2].Text)
+
+	steps = Parse("STEP 1 - MARINATING THE BEEF\nMix the beef with soy sauce.\nLeave for 15 mins.\n" +
+		"STEP 2\nBoil the noodles.")
+	assert.Len(t, steps, 2)
+	assert.Equal(t, "MARINATING THE BEEF\nMix the beef with soy sauce.\nLeave for 15 mins.", steps[0].Text)
+	assert.Equal(t, []Duration{{MinSeconds: 900, MaxSeconds: 900, Text: "15 mins"}}, steps[0].Durations)
+	assert.Equal(t, "Boil the noodles.", steps[1].Text)
+
+	// A number is not a marker when it is a quantity
+	steps = Parse("2 eggs, beaten, go in last.")
+	assert.Equal(t, "2 eggs, beaten, go in last.", steps[0].Text)
+}
+
+func TestDurations(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{"bake for 15 mins or until lightly coloured", 900, 900},
+		{"cover for 30-60 seconds", 30, 60},
+		{"simmer for 10–15 minutes", 600, 900},
+		{"cook 3- 4 mins", 180, 240},
+		{"leave for 1 to 1 1/2 hours", 3600, 5400},
+		{"roast for 1½-2 hours", 5400, 7200},
+		{"braise for 2-2¼ hrs", 7200, 8100},
+		{"cook for 1hr 30 minute", 5400, 5400},
+		{"roast for 1 hr and 15 mins", 4500, 4500},
+		{"stir for 1-2 more minutes", 60, 120},
+		{"rest for half an hour", 1800, 1800},
+		{"cook for a further minute", 60, 60},
+		{"chill for an hour", 3600, 3600},
+		{"fry for two minutes", 120, 120},
+		{"soak 24 hrs", 86400, 86400},
+	}
+	for _, tt := range tests {
+		d := Durations(tt.text)
+		if assert.Len(t, d, 1, tt.text) {
+			assert.Equal(t, tt.min, d[0].MinSeconds, tt.text)
+			assert.Equal(t, tt.max, d[0].MaxSeconds, tt.text)
+		}
+	}
+
+	d := Durations("Cook for 3 mins until caramel. Stir in the apples and cook for 3 mins, then 10 minutes more.")
+	assert.Len(t, d, 3)
+
+	for _, text := range []string{
+		"Fry the chicken a second time",
+		"Ah, the smell",
+		"cook for a few minutes",
+		"cut into 2cm dice",
+		"add 2 mince pies",
+	} {
+		assert.Empty(t, Durations(text), text)
+	}
+}
+
+func TestTemperatures(t *testing.T) {
+	tests := []struct {
+		text string
+		want Temperature
+	}{
+		{"Heat oven to 190C/170C fan/gas 5.", Temperature{Celsius: 190, Fahrenheit: 375, FanCelsius: 170, GasMark: 5, Text: "190C/170C fan/gas 5"}},
+		{"Heat to 180C/350F/Gas 4 and line a tin", Temperature{Celsius: 180, Fahrenheit: 350, GasMark: 4, Text: "180C/350F/Gas 4"}},
+		{"Heat to 200C/gas 6/fan 180C", Temperature{Celsius: 200, Fahrenheit: 390, FanCelsius: 180, GasMark: 6, Text: "200C/gas 6/fan 180C"}},
+		{"Heat to 190°C/fan170°C/gas 5", Temperature{Celsius: 190, Fahrenheit: 375, FanCelsius: 170, GasMark: 5, Text: "190°C/fan170°C/gas 5"}},
+		{"Heat fan 90C/conventional 110C/gas 1⁄4", Temperature{Celsius: 110, Fahrenheit: 230, FanCelsius: 90, GasMark: 0.25, Text: "fan 90C/conventional 110C/gas 1⁄4"}},
+		{"Heat oven to 375°F (190°C). Grease a pan.", Temperature{Celsius: 190, Fahrenheit: 375, Text: "375°F (190°C)"}},
+		{"Preheat to 350 degrees F (175 degrees C)", Temperature{Celsius: 175, Fahrenheit: 350, Text: "350 degrees F (175 degrees C)"}},
+		{"Bake at 175 degrees C for 25 minutes", Temperature{Celsius: 175, Fahrenheit: 345, Text: "175 degrees C"}},
+		{"Preheat the oven to 350 degrees.", Temperature{Celsius: 175, Fahrenheit: 350, Text: "350 degrees"}},
+		{"Set the oven to 180 degrees.", Temperature{Celsius: 180, Fahrenheit: 355, Text: "180 degrees"}},
+		{"Roast at 160 C / Gas 2", Temperature{Celsius: 160, Fahrenheit: 320, GasMark: 2, Text: "160 C / Gas 2"}},
+		{"Heat the oven to gas 4", Temperature{Celsius: 180, Fahrenheit: 355, GasMark: 4, Text: "gas 4"}},
+	}
+	for _, tt := range tests {
+		temps := Temperatures(tt.text)
+		if assert.Len(t, temps, 1, tt.text) {
+			assert.Equal(t, tt.want, temps[0], tt.text)
+		}
+	}
+
+	temps := Temperatures("Bake at 200C for 10 minutes, then lower to 160C/gas 3.")
+	assert.Equal(t, []Temperature{
+		{Celsius: 200, Fahrenheit: 390, Text: "200C"},
+		{Celsius: 160, Fahrenheit: 320, GasMark: 3, Text: "160C/gas 3"},
+	}, temps)
+
+	for _, text := range []string{
+		"cut at a 90º angle",
+		"add 20 cloves",
+		"turn 15 degrees",
+		"serves 12",
+	} {
+		assert.Empty(t, Temperatures(text), text)
+	}
+}
+
+func TestReadings(t *testing.T) {
+	text := "Heat to 200C/fan 180C/gas 6, or 350 degrees F"
+	assert.Equal(t, []Reading{
+		{Start: 8, End: 12, Degrees: 200, Unit: "C"},
+		{Start: 17, End: 21, Degrees: 180, Unit: "C", Fan: true},
+		{Start: 32, End: 45, Degrees: 350, Unit: "F"},
+	}, Readings(text))
+	// The space after a bare "degrees" is not part of it
+	text = "Preheat to 350 degrees for 20 minutes"
+	if rs := Readings(text); assert.Len(t, rs, 1) {
+		assert.Equal(t, "350 degrees", text[rs[0].Start:rs[0].End])
+		assert.Equal(t, "F", rs[0].Unit)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/recipe/quiz_gen"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// runQuiz turns a finished crawl into multiple-choice questions for the quiz
+// server, written as JSON or loaded into its quiz_table.
+func runQuiz(args []string) error {
+	fs := newFlagSet("quiz", "")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l])")
+	count := fs.Int("count", 20, "number of questions, 0 for one per meal and kind")
+	choices := fs.Int("choices", 4, "options per question")
+	kinds := fs.String("kinds", "", "comma separated kinds of question: country, missing-ingredient, meal-with (default all)")
+	seed := fs.Uint64("seed", 1, "seed of the random picks, the same seed gives the same questions")
+	out := fs.String("out", "", "file to write the questions to as JSON instead of stdout")
+	dsn := fs.String("pg-dsn", "", "PostgreSQL connection string of the quiz server database, to insert into quiz_table")
+	api := fs.String("api", "", "base URL of the quiz server, e.g. http://localhost:8080, to create the questions through POST /api/quiz")
+	token := fs.String("token", os.Getenv("QUIZ_TOKEN"), "JWT from /api/auth/login for -api (default $QUIZ_TOKEN)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	opts := quiz_gen.Options{Count: *count, Choices: *choices, Seed: *seed}
+	for _, kind := range splitList(*kinds) {
+		if !slices.Contains(quiz_gen.Kinds, quiz_gen.Kind(kind)) {
+			return usageErrorf("unknown kind of question %q", kind)
+		}
+		opts.Kinds = append(opts.Kinds, quiz_gen.Kind(kind))
+	}
+	if *count < 0 || *choices < 2 {
+		return usageErrorf("-count must be 0 or more and -choices at least 2")
+	}
+	if *dsn != "" && *api != "" {
+		return usageErrorf("pick one of -pg-dsn and -api")
+	}
+	if *api != "" && *token == "" {
+		return usageErrorf("-api needs a -token")
+	}
+
+	path := findCrawlFile(*from, "final_items")
+	meals, err := detail_parse.LoadFinalMeals(path)
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+	questions := quiz_gen.Generate(meals, opts)
+	if len(questions) == 0 {
+		return fmt.Errorf("no questions could be made from %s", path)
+	}
+
+	switch {
+	case *dsn != "":
+		db, err := sql.Open("postgres", *dsn)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		added, err := quiz_gen.Insert(db, questions)
+		if err != nil {
+			return fmt.Errorf("insert questions: %w", err)
+		}
+		fmt.Printf("Added %d questions to quiz_table, %d were already there\n", added, len(questions)-added)
+	case *api != "":
+		client := &http.Client{Timeout: 30 * time.Second}
+		posted, err := quiz_gen.Post(client, *api, *token, questions)
+		if err != nil {
+			return fmt.Errorf("post questions (%d of %d created): %w", posted, len(questions), err)
+		}
+		fmt.Printf("Created %d questions on %s\n", posted, *api)
+	default:
+		w := os.Stdout
+		if *out != "" {
+			if w, err = os.Create(*out); err != nil {
+				return err
+			}
+			defer w.Close()
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(questions); err != nil {
+			return err
+		}
+		if *out != "" {
+			return w.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cookbook
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/step_parse"
+)
+
+// Options configure the exports.
+type Options struct {
+	// Title is the title of the book, "Cookbook" when empty
+	Title string
+	// From is the output directory of the crawl. The mirrored image paths of
+	// the meals are relative to it.
+	From string
+	// Modified is when the book says it was last changed, the zero time for
+	// the latest crawl of its meals. A fixed value gives the same file for
+	// the same meals.
+	Modified time.Time
+}
+
+func titleOf(opts Options) string {
+	return cmp.Or(opts.Title, "Cookbook")
+}
+
+// Result counts what was written.
+type Result struct {
+	Meals  int
+	Images int
+	// MissingImages were not mirrored and are left out
+	MissingImages int
+}
+
+// chapter is a meal as the book and the Markdown files show it.
+type chapter struct {
+	detail_parse.MealDetail
+	Name  string
+	Slug  string // file name without extension, unique in the export
+	Steps []step_parse.Step
+}
+
+// chapters sorts the meals by name, so the order of the crawl doesn't
+// change the export, and gives every meal a file name. Names in reserved
+// are not given out.
+func chapters(meals []detail_parse.MealDetail, reserved ...string) []chapter {
+	var out []chapter
+	for _, meal := range meals {
+		c := chapter{MealDetail: meal, Name: meal.DisplayName(), Steps: meal.Steps}
+		if len(c.Steps) == 0 {
+			c.Steps = step_parse.Parse(meal.Receipt)
+		}
+		out = append(out, c)
+	}
+	slices.SortStableFunc(out, func(a, b chapter) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), cmp.Compare(a.ID, b.ID))
+	})
+	taken := map[string]bool{}
+	for _, name := range reserved {
+		taken[name] = true
+	}
+	for i := range out {
+		slug := cmp.Or(ingredient_catalog.ID(out[i].Name), "meal")
+		out[i].Slug = slug
+		for n := 2; taken[out[i].Slug]; n++ {
+			out[i].Slug = fmt.Sprintf("%s-%d", slug, n)
+		}
+		taken[out[i].Slug] = true
+	}
+	return out
+}
+
+// ingredientNames are the names the ingredients of a meal are filed under,
+// each once.
+func ingredientNames(meal detail_parse.MealDetail) []string {
+	var names []string
+	for _, ing := range meal.Ingredents {
+		if name := ingredient_catalog.IngredientName(ing); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
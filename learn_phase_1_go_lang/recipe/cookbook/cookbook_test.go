@@ -0,0 +1,198 @@
+package cookbook
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []detail_parse.MealDetail{
+	{
+		ReceiptName: "Tarte Tatin Recipe",
+		Flag:        "fr",
+		Receipt:     "Heat the oven.\nBake for 25 mins.",
+		Ingredents: []detail_parse.Ingredient{
+			meal_fixture.ImageIngredient("100g butter", meal_fixture.ImageURL("Butter"), "images/butter.png"),
+			meal_fixture.ImageIngredient("6 apples", meal_fixture.ImageURL("Apples"), ""),
+		},
+	},
+	{
+		ReceiptName: `Apple "Yes: No" Tart Recipe`,
+		ID:          52768,
+		Flag:        "gb",
+		Category:    "Dessert",
+		Tags:        []string{"Tart", "Baking"},
+		Receipt:     "STEP 1\nMake the pastry.\nChill it.\nSTEP 2\nBake & serve.",
+		Ingredents: []detail_parse.Ingredient{
+			meal_fixture.ImageIngredient("75g butter", meal_fixture.ImageURL("Butter"), "images/butter.png"),
+			meal_fixture.ImageIngredient("2 Bramley apples", "", ""),
+		},
+	},
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	res, err := WriteMarkdown(dir, testMeals, Options{Title: "Tarts"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.Meals)
+
+	data, err := os.ReadFile(filepath.Join(dir, "apple-yes-no-tart.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+name: "Apple \"Yes: No\" Tart"
+id: 52768
+flag: "gb"
+category: "Dessert"
+tags:
+  - "Tart"
+  - "Baking"
+ingredients:
+  - "Butter"
+  - "Bramley apples"
+---
+
+# Apple "Yes: No" Tart
+
+## Ingredients
+
+- 75g butter
+- 2 Bramley apples
+
+## Instructions
+
+1. Make the pastry.
+   Chill it.
+2. Bake & serve.
+`, string(data))
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# Tarts\n\n- [Apple \"Yes: No\" Tart](apple-yes-no-tart.md)\n- [Tarte Tatin](tarte-tatin.md)\n", string(index))
+
+	// The order of the crawl doesn't change a byte
+	again := t.TempDir()
+	_, err = WriteMarkdown(again, []detail_parse.MealDetail{testMeals[1], testMeals[0]}, Options{Title: "Tarts"})
+	assert.NoError(t, err)
+	for _, name := range []string{"index.md", "apple-yes-no-tart.md", "tarte-tatin.md"} {
+		a, _ := os.ReadFile(filepath.Join(dir, name))
+		b, _ := os.ReadFile(filepath.Join(again, name))
+		assert.Equal(t, string(a), string(b), name)
+	}
+
+	// Exporting again drops the files of meals gone from the crawl, not the
+	// other files of the directory
+	notes := filepath.Join(dir, "notes.md")
+	assert.NoError(t, os.WriteFile(notes, []byte("# Notes\n"), 0644))
+	res, err = WriteMarkdown(dir, testMeals[:1], Options{Title: "Tarts"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res.Meals)
+	assert.NoFileExists(t, filepath.Join(dir, "apple-yes-no-tart.md"))
+	assert.FileExists(t, filepath.Join(dir, "tarte-tatin.md"))
+	assert.FileExists(t, notes)
+}
+
+func TestChapters_UniqueSlugs(t *testing.T) {
+	meals := []detail_parse.MealDetail{{ReceiptName: "Index"}, {ReceiptName: "Tart"}, {ReceiptName: "tart"}}
+	var slugs []string
+	for _, c := range chapters(meals, "index") {
+		slugs = append(slugs, c.Slug)
+	}
+	assert.Equal(t, []string{"index-2", "tart", "tart-2"}, slugs)
+}
+
+func readZip(t *testing.T, data []byte) (*zip.Reader, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	return zr, files
+}
+
+func TestWriteEPUB(t *testing.T) {
+	from := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(from, "images"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(from, "images", "butter.png"), []byte("png"), 0644))
+	opts := Options{Title: "Tarts & Pies", From: from, Modified: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+
+	var buf bytes.Buffer
+	res, err := WriteEPUB(&buf, testMeals, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Meals: 2, Images: 1, MissingImages: 1}, res)
+
+	zr, files := readZip(t, buf.Bytes())
+	// The mimetype is first and stored, readers check it by offset
+	assert.Equal(t, "mimetype", zr.File[0].Name)
+	assert.Equal(t, zip.Store, zr.File[0].Method)
+	assert.Equal(t, "application/epub+zip", files["mimetype"])
+	assert.Equal(t, "png", files["OEBPS/images/butter.png"])
+	assert.Contains(t, files["META-INF/container.xml"], `full-path="OEBPS/content.opf"`)
+
+	opf := files["OEBPS/content.opf"]
+	assert.Contains(t, opf, "<dc:title>Tarts &amp; Pies</dc:title>")
+	assert.Contains(t, opf, `<meta property="dcterms:modified">2026-10-01T12:00:00Z</meta>`)
+	assert.Contains(t, opf, `<item id="image-1" href="images/butter.png" media-type="image/png"/>`)
+	assert.Contains(t, opf, "<itemref idref=\"nav\"/>\n<itemref idref=\"meal-apple-yes-no-tart\"/>\n<itemref idref=\"meal-tarte-tatin\"/>")
+	assert.Contains(t, files["OEBPS/nav.xhtml"], `<li><a href="meals/tarte-tatin.xhtml">Tarte Tatin</a></li>`)
+
+	tart := files["OEBPS/meals/apple-yes-no-tart.xhtml"]
+	assert.True(t, strings.HasPrefix(tart, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, tart, `<li><img src="../images/butter.png" alt=""/> 75g butter</li>`)
+	assert.Contains(t, tart, "<li>2 Bramley apples</li>")
+	assert.Contains(t, tart, "<li>Bake &amp; serve.</li>")
+	assert.Contains(t, tart, `<p class="info">GB · Dessert</p>`)
+
+	// Every file is well formed XML
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".xhtml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".xml") {
+			continue
+		}
+		d := xml.NewDecoder(strings.NewReader(content))
+		d.Strict, d.Entity = true, xml.HTMLEntity
+		for {
+			if _, err := d.Token(); err != nil {
+				assert.ErrorIs(t, err, io.EOF, name)
+				break
+			}
+		}
+	}
+
+	// The same meals give the same book, in any order
+	var again bytes.Buffer
+	reversed := slices.Clone(testMeals)
+	slices.Reverse(reversed)
+	_, err = WriteEPUB(&again, reversed, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), again.Bytes())
+}
+
+func TestWriteEPUB_Modified(t *testing.T) {
+	for _, modified := range []time.Time{{}, time.Unix(0, 0)} {
+		var buf bytes.Buffer
+		_, err := WriteEPUB(&buf, testMeals, Options{From: t.TempDir(), Modified: modified})
+		assert.NoError(t, err)
+		// Zip can't store dates before 1980, a fixed one is used instead
+		zr, files := readZip(t, buf.Bytes())
+		assert.Equal(t, 2000, zr.File[0].Modified.Year())
+		assert.Contains(t, files["OEBPS/content.opf"], `<meta property="dcterms:modified">2000-01-01T00:00:00Z</meta>`)
+	}
+}
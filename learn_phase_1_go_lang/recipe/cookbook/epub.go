@@ -0,0 +1,268 @@
+package cookbook
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+)
+
+//go:embed templates
+var files embed.FS
+
+// The pages of the book are XHTML, which html/template escapes correctly.
+// It would escape an XML declaration too, so render writes that one.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).ParseFS(files, "templates/*.opf", "templates/*.xhtml"))
+
+const container = xml.Header + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+// imageTypes are the image formats every EPUB 3 reader shows.
+var imageTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+type bookImage struct {
+	ID        string
+	Href      string // relative to OEBPS
+	MediaType string
+	data      []byte
+}
+
+type bookChapter struct {
+	chapter
+	Info      []string
+	Thumbnail string
+	Figures   []bookFigure
+}
+
+type bookFigure struct {
+	Caption string
+	Image   string
+}
+
+type book struct {
+	opts       Options
+	Identifier string
+	Title      string
+	Modified   string
+	Chapters   []bookChapter
+	Images     []*bookImage
+	byPath     map[string]*bookImage // mirrored path or URL -> image, nil when missing
+	result     Result
+}
+
+// WriteEPUB writes the meals as one EPUB 3 book: a table of contents, then
+// a chapter per meal with its thumbnail, ingredients and steps. The
+// mirrored images are embedded, see image_mirror; the ones that were not
+// mirrored are left out, since a book is read offline. The same meals and
+// Modified give the same bytes.
+func WriteEPUB(w io.Writer, meals []detail_parse.MealDetail, opts Options) (Result, error) {
+	b := &book{opts: opts, Title: titleOf(opts), byPath: map[string]*bookImage{}}
+	modified := opts.Modified
+	if modified.IsZero() {
+		modified = latestCrawl(meals)
+	}
+	if modified.Before(minModified) {
+		modified = defaultModified
+	}
+	b.Modified = modified.UTC().Format(time.RFC3339)
+
+	id := sha256.New()
+	for _, c := range chapters(meals, "nav") {
+		fmt.Fprintf(id, "%s\n", c.Slug)
+		bc := bookChapter{chapter: c}
+		for _, v := range []string{strings.ToUpper(c.Flag), c.Category, c.Area} {
+			if v != "" {
+				bc.Info = append(bc.Info, v)
+			}
+		}
+		var err error
+		if bc.Thumbnail, err = b.image(c.ThumbnailPath, c.Thumbnail); err != nil {
+			return b.result, err
+		}
+		for _, ing := range c.Ingredents {
+			f := bookFigure{Caption: ing.Caption}
+			if f.Image, err = b.image(ing.ImagePath, ing.ImageURL); err != nil {
+				return b.result, err
+			}
+			bc.Figures = append(bc.Figures, f)
+		}
+		b.Chapters = append(b.Chapters, bc)
+	}
+	b.result.Meals = len(b.Chapters)
+	// A UUID made from the meals, so the book keeps its identity when it is
+	// exported again
+	sum := id.Sum(nil)
+	b.Identifier = fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	return b.result, b.write(w, modified)
+}
+
+// Zip timestamps start in 1980. An earlier or unknown modification date,
+// as for meals crawled before fetch times were recorded, is replaced by
+// defaultModified.
+var (
+	minModified     = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultModified = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// latestCrawl is when the newest of the meals was fetched, zero when none
+// of them recorded it.
+func latestCrawl(meals []detail_parse.MealDetail) time.Time {
+	var latest time.Time
+	for _, meal := range meals {
+		if meal.Crawl != nil && meal.Crawl.FetchedAt.After(latest) {
+			latest = meal.Crawl.FetchedAt
+		}
+	}
+	return latest
+}
+
+// image embeds a mirrored image once and returns its path from OEBPS.
+func (b *book) image(mirrored, rawURL string) (string, error) {
+	key := cmp.Or(mirrored, rawURL)
+	if key == "" {
+		return "", nil
+	}
+	img, seen := b.byPath[key]
+	if !seen {
+		var err error
+		if img, err = b.load(mirrored); err != nil {
+			return "", err
+		}
+		if img == nil {
+			b.result.MissingImages++
+		}
+		b.byPath[key] = img
+	}
+	if img == nil {
+		return "", nil
+	}
+	return img.Href, nil
+}
+
+// load reads a mirrored image, nil when it is not there or not a format
+// readers show.
+func (b *book) load(mirrored string) (*bookImage, error) {
+	mediaType := imageTypes[strings.ToLower(path.Ext(mirrored))]
+	if mirrored == "" || mediaType == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(b.opts.From, mirrored))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	img := &bookImage{
+		ID:        fmt.Sprintf("image-%d", len(b.Images)+1),
+		Href:      "images/" + path.Base(filepath.ToSlash(mirrored)),
+		MediaType: mediaType,
+		data:      data,
+	}
+	b.Images = append(b.Images, img)
+	b.result.Images++
+	return img, nil
+}
+
+// msDosTime is t in the date and time fields of a zip header.
+func msDosTime(t time.Time) (date, clock uint16) {
+	t = t.UTC()
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+func (b *book) write(w io.Writer, modified time.Time) error {
+	zw := zip.NewWriter(w)
+	// The mimetype comes first and uncompressed, without a data descriptor,
+	// so readers can tell an EPUB by its first bytes
+	mimetype := []byte("application/epub+zip")
+	header := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		Modified:           modified,
+		CRC32:              crc32.ChecksumIEEE(mimetype),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	}
+	// CreateRaw keeps the header as it is, the MS-DOS date is set here
+	header.ModifiedDate, header.ModifiedTime = msDosTime(modified)
+	fw, err := zw.CreateRaw(header)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(mimetype); err != nil {
+		return err
+	}
+
+	add := func(name string, data []byte) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(data)
+		return err
+	}
+	render := func(name, tmpl string, data any) error {
+		buf := bytes.NewBufferString(xml.Header)
+		if err := templates.ExecuteTemplate(buf, tmpl, data); err != nil {
+			return fmt.Errorf("render %s: %w", name, err)
+		}
+		return add(name, buf.Bytes())
+	}
+
+	style, err := files.ReadFile("templates/style.css")
+	if err != nil {
+		return err
+	}
+	if err := add("META-INF/container.xml", []byte(container)); err != nil {
+		return err
+	}
+	if err := render("OEBPS/content.opf", "content.opf", b); err != nil {
+		return err
+	}
+	if err := render("OEBPS/nav.xhtml", "nav.xhtml", b); err != nil {
+		return err
+	}
+	if err := add("OEBPS/style.css", style); err != nil {
+		return err
+	}
+	for _, c := range b.Chapters {
+		if err := render("OEBPS/meals/"+c.Slug+".xhtml", "chapter.xhtml", c); err != nil {
+			return err
+		}
+	}
+	for _, img := range b.Images {
+		if err := add("OEBPS/"+img.Href, img.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
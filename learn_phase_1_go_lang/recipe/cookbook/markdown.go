@@ -0,0 +1,123 @@
+package cookbook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+)
+
+// WriteMarkdown writes a Markdown file per meal into dir, and an index.md
+// linking them. Every file starts with YAML front matter (name, id, flag,
+// category, area, tags, source and ingredients) for note-taking apps. The
+// same meals always give the same bytes: files are named after the meal,
+// keys come in a fixed order and no time is written, so the diff between
+// two crawls shows only what changed. The meal files of an earlier export,
+// the ones its index.md links, are removed first; other files in dir are
+// left alone.
+func WriteMarkdown(dir string, meals []detail_parse.MealDetail, opts Options) (Result, error) {
+	var res Result
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return res, err
+	}
+	if err := removeExported(dir); err != nil {
+		return res, err
+	}
+	var index bytes.Buffer
+	fmt.Fprintf(&index, "# %s\n\n", titleOf(opts))
+	for _, c := range chapters(meals, "index") {
+		if err := os.WriteFile(filepath.Join(dir, c.Slug+".md"), markdown(c), 0644); err != nil {
+			return res, err
+		}
+		fmt.Fprintf(&index, "- [%s](%s.md)\n", c.Name, c.Slug)
+		res.Meals++
+	}
+	return res, os.WriteFile(filepath.Join(dir, "index.md"), index.Bytes(), 0644)
+}
+
+// indexLinkRe matches a line of index.md linking a meal file.
+var indexLinkRe = regexp.MustCompile(`(?m)^- \[.*\]\(([^/\\()]+\.md)\)$`)
+
+// removeExported removes the meal files the index.md in dir links, so meals
+// gone from the crawl don't stay behind.
+func removeExported(dir string) error {
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, m := range indexLinkRe.FindAllSubmatch(index, -1) {
+		name := string(m[1])
+		if name == "index.md" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func markdown(c chapter) []byte {
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "name: %s\n", yamlString(c.Name))
+	if c.ID != 0 {
+		fmt.Fprintf(&b, "id: %d\n", c.ID)
+	}
+	for _, field := range []struct{ key, value string }{
+		{"flag", c.Flag},
+		{"category", c.Category},
+		{"area", c.Area},
+		{"source", c.SourceURL},
+		{"youtube", c.YouTube},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", field.key, yamlString(field.value))
+		}
+	}
+	yamlList(&b, "tags", c.Tags)
+	yamlList(&b, "ingredients", ingredientNames(c.MealDetail))
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# %s\n\n## Ingredients\n\n", c.Name)
+	for _, ing := range c.Ingredents {
+		fmt.Fprintf(&b, "- %s\n", ing.Caption)
+	}
+	b.WriteString("\n## Instructions\n\n")
+	for _, step := range c.Steps {
+		prefix := fmt.Sprintf("%d. ", step.Number)
+		text := strings.ReplaceAll(step.Text, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+		fmt.Fprintf(&b, "%s%s\n", prefix, text)
+	}
+	return b.Bytes()
+}
+
+func yamlList(b *bytes.Buffer, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", key)
+	for _, v := range values {
+		fmt.Fprintf(b, "  - %s\n", yamlString(v))
+	}
+}
+
+// yamlString quotes s. A JSON string is a valid YAML one, and quoting
+// every value keeps names like "No" or "1:2" from being read as something
+// else.
+func yamlString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
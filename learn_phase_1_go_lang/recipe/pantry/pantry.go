@@ -0,0 +1,229 @@
+package pantry
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/search_index"
+)
+
+// Substitutions maps an ingredient to the ones that can stand in for it,
+// like "Butter": ["Salted Butter", "Margarine"]. It is one way: having
+// butter does not cover a recipe asking for margarine.
+type Substitutions map[string][]string
+
+// DefaultSubstitutions are swaps most recipes don't mind.
+var DefaultSubstitutions = Substitutions{
+	"Butter":             {"Salted Butter", "Unsalted Butter", "Margarine"},
+	"Salted Butter":      {"Butter", "Unsalted Butter"},
+	"Unsalted Butter":    {"Butter", "Salted Butter"},
+	"Plain Flour":        {"All Purpose Flour", "Flour"},
+	"Flour":              {"Plain Flour", "All Purpose Flour"},
+	"Caster Sugar":       {"Sugar", "Granulated Sugar", "Superfine Sugar"},
+	"Sugar":              {"Caster Sugar", "Granulated Sugar"},
+	"Double Cream":       {"Heavy Cream", "Whipping Cream"},
+	"Heavy Cream":        {"Double Cream", "Whipping Cream"},
+	"Eggs":               {"Free-range Eggs", "Large Eggs"},
+	"Free-range Eggs":    {"Eggs", "Large Eggs"},
+	"Vegetable Oil":      {"Sunflower Oil", "Rapeseed Oil", "Canola Oil"},
+	"Olive Oil":          {"Extra Virgin Olive Oil"},
+	"Spring Onions":      {"Scallions", "Green Onions"},
+	"Chicken Stock":      {"Vegetable Stock", "Chicken Stock Cube"},
+	"Vegetable Stock":    {"Chicken Stock", "Vegetable Stock Cube"},
+	"Milk":               {"Whole Milk", "Semi-skimmed Milk", "Skimmed Milk"},
+	"Greek Yogurt":       {"Natural Yogurt", "Plain Yogurt"},
+	"Natural Yogurt":     {"Greek Yogurt", "Plain Yogurt"},
+	"Dark Brown Sugar":   {"Brown Sugar", "Light Brown Sugar"},
+	"Light Brown Sugar":  {"Brown Sugar", "Dark Brown Sugar"},
+	"Parmesan":           {"Parmesan Cheese", "Grana Padano"},
+	"Parmesan Cheese":    {"Parmesan", "Grana Padano"},
+	"Red Wine Vinegar":   {"White Wine Vinegar"},
+	"White Wine Vinegar": {"Red Wine Vinegar", "Cider Vinegar"},
+}
+
+// DefaultStaples are assumed to be in every kitchen.
+var DefaultStaples = []string{"Water", "Salt", "Pepper", "Black Pepper"}
+
+// LoadList reads a pantry file, one ingredient per line. Blank lines and
+// lines starting with # are skipped.
+func LoadList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var items []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			items = append(items, line)
+		}
+	}
+	return items, scanner.Err()
+}
+
+// LoadSubstitutions reads the user's substitutions and adds them to the
+// defaults. An empty path means only the defaults.
+func LoadSubstitutions(path string) (Substitutions, error) {
+	subs := Substitutions{}
+	for need, use := range DefaultSubstitutions {
+		subs[need] = slices.Clone(use)
+	}
+	if path == "" {
+		return subs, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var user Substitutions
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for need, use := range user {
+		subs[need] = append(subs[need], use...)
+	}
+	return subs, nil
+}
+
+// Matcher checks recipes against what is at hand.
+type Matcher struct {
+	have    map[string]string // key -> pantry item as written
+	subs    map[string][]string
+	staples map[string]bool
+	catalog *ingredient_catalog.Catalog
+}
+
+// Options configure a Matcher.
+type Options struct {
+	Substitutions Substitutions
+	// Staples are never missing and don't count as required
+	Staples []string
+	// Catalog, if set, matches an ingredient by any of its aliases
+	Catalog *ingredient_catalog.Catalog
+}
+
+// New returns a Matcher for the pantry items have.
+func New(have []string, opts Options) *Matcher {
+	m := &Matcher{have: map[string]string{}, subs: map[string][]string{}, staples: map[string]bool{}, catalog: opts.Catalog}
+	for _, item := range have {
+		if k := m.key(item); k != "" {
+			m.have[k] = strings.TrimSpace(item)
+		}
+	}
+	for need, use := range opts.Substitutions {
+		k := m.key(need)
+		for _, u := range use {
+			m.subs[k] = append(m.subs[k], m.key(u))
+		}
+	}
+	for _, staple := range opts.Staples {
+		m.staples[m.key(staple)] = true
+	}
+	return m
+}
+
+// key makes the spellings of one ingredient equal: the catalog ID if the
+// catalog knows it, else search_index.Key.
+func (m *Matcher) key(name string) string {
+	if m.catalog != nil {
+		if e, ok := m.catalog.Lookup(name); ok {
+			return e.ID
+		}
+	}
+	return search_index.Key(name)
+}
+
+// Substitution is a pantry item used in place of a recipe ingredient.
+type Substitution struct {
+	Need string `json:"need"`
+	Use  string `json:"use"`
+}
+
+// Match is how well the pantry covers one meal.
+type Match struct {
+	Meal        detail_parse.MealDetail
+	Required    int            // ingredients the meal needs, staples left out
+	Have        []string       // covered by the pantry as they are
+	Substituted []Substitution // covered by a substitute
+	Missing     []string
+}
+
+// Coverage is the share of required ingredients at hand, 1 when the meal
+// needs nothing but staples.
+func (m Match) Coverage() float64 {
+	if m.Required == 0 {
+		return 1
+	}
+	return float64(len(m.Have)+len(m.Substituted)) / float64(m.Required)
+}
+
+// Check matches one meal. An ingredient listed twice is required once.
+func (m *Matcher) Check(meal detail_parse.MealDetail) Match {
+	match := Match{Meal: meal}
+	seen := map[string]bool{}
+	for _, ing := range meal.Ingredents {
+		name := ingredient_catalog.IngredientName(ing)
+		keys := []string{m.key(name)}
+		if ing.Name != "" {
+			keys = append(keys, m.key(ing.Name))
+		}
+		if keys[0] == "" || seen[keys[0]] {
+			continue
+		}
+		seen[keys[0]] = true
+		if slices.ContainsFunc(keys, func(k string) bool { return m.staples[k] }) {
+			continue
+		}
+		match.Required++
+		if slices.ContainsFunc(keys, func(k string) bool { return m.have[k] != "" }) {
+			match.Have = append(match.Have, name)
+			continue
+		}
+		if use, ok := m.substitute(keys); ok {
+			match.Substituted = append(match.Substituted, Substitution{Need: name, Use: use})
+			continue
+		}
+		match.Missing = append(match.Missing, name)
+	}
+	return match
+}
+
+func (m *Matcher) substitute(keys []string) (string, bool) {
+	for _, k := range keys {
+		for _, sub := range m.subs[k] {
+			if item := m.have[sub]; item != "" {
+				return item, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Rank checks every meal and returns the ones using at least one pantry
+// item, best covered first. Ties go to the meal missing fewer ingredients,
+// then to the one using fewer substitutes.
+func (m *Matcher) Rank(meals []detail_parse.MealDetail) []Match {
+	var matches []Match
+	for _, meal := range meals {
+		if match := m.Check(meal); len(match.Have)+len(match.Substituted) > 0 {
+			matches = append(matches, match)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return cmp.Or(
+			cmp.Compare(b.Coverage(), a.Coverage()),
+			cmp.Compare(len(a.Missing), len(b.Missing)),
+			cmp.Compare(len(a.Substituted), len(b.Substituted)),
+			cmp.Compare(a.Meal.DisplayName(), b.Meal.DisplayName()),
+		)
+	})
+	return matches
+}
@@ -0,0 +1,81 @@
+package pantry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []detail_parse.MealDetail{
+	meal_fixture.Meal("Pancakes", "100g plain flour", "2 Eggs", "300ml milk", "Pinch salt", "25g butter"),
+	meal_fixture.Meal("Omelette", "3 free-range eggs", "knob of butter", "1 tbsp chives"),
+	meal_fixture.Meal("Chicken Curry", "500g chicken thighs", "1 onion", "2 tbsp curry paste"),
+}
+
+func TestCheck(t *testing.T) {
+	m := New([]string{"Flour", "egg", "Milk", "Salted Butter"}, Options{Substitutions: DefaultSubstitutions, Staples: DefaultStaples})
+
+	pancakes := m.Check(testMeals[0])
+	// Salt is a staple
+	assert.Equal(t, 4, pancakes.Required)
+	assert.Equal(t, []string{"Eggs", "milk"}, pancakes.Have)
+	assert.Equal(t, []Substitution{{Need: "plain flour", Use: "Flour"}, {Need: "butter", Use: "Salted Butter"}}, pancakes.Substituted)
+	assert.Empty(t, pancakes.Missing)
+	assert.Equal(t, 1.0, pancakes.Coverage())
+
+	omelette := m.Check(testMeals[1])
+	assert.Equal(t, []string{"chives"}, omelette.Missing)
+	assert.InDelta(t, 2.0/3, omelette.Coverage(), 1e-9)
+
+	// Substitutions are one way
+	m = New([]string{"butter"}, Options{Substitutions: Substitutions{"Butter": {"Margarine"}}})
+	assert.Equal(t, []string{"margarine"}, m.Check(meal_fixture.Meal("Toast", "margarine")).Missing)
+}
+
+func TestRank(t *testing.T) {
+	m := New([]string{"eggs", "butter", "chives"}, Options{Substitutions: DefaultSubstitutions})
+	matches := m.Rank(testMeals)
+	// The curry uses nothing at hand and is left out
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, "Omelette", matches[0].Meal.DisplayName())
+		assert.Equal(t, "Pancakes", matches[1].Meal.DisplayName())
+		assert.Equal(t, []string{"plain flour", "milk", "salt"}, matches[1].Missing)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	catalog := ingredient_catalog.Build(testMeals, ingredient_catalog.Aliases{"Beurre": "Butter"})
+	m := New([]string{"beurre"}, Options{Catalog: catalog})
+	assert.Equal(t, []string{"butter"}, m.Check(testMeals[0]).Have)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "pantry.txt")
+	assert.NoError(t, os.WriteFile(list, []byte("# fridge\nEggs\n\n  Milk \n"), 0644))
+	items, err := LoadList(list)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Eggs", "Milk"}, items)
+
+	subs, err := LoadSubstitutions("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSubstitutions, subs)
+	_, err = LoadSubstitutions(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	path := filepath.Join(dir, "substitutions.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"Butter": ["Ghee"], "Chives": ["Spring Onions"]}`), 0644))
+	subs, err = LoadSubstitutions(path)
+	assert.NoError(t, err)
+	assert.Contains(t, subs["Butter"], "Margarine")
+	assert.Contains(t, subs["Butter"], "Ghee")
+	assert.Equal(t, []string{"Spring Onions"}, subs["Chives"])
+	// The defaults are not changed
+	assert.NotContains(t, DefaultSubstitutions["Butter"], "Ghee")
+}
@@ -0,0 +1,204 @@
+package quiz_gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/search_index"
+)
+
+// Question is a multiple-choice question in the shape of the quiz_table of
+// the quiz server (learn_phase_2_local_server). Every answer is one of the
+// options.
+type Question struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Answers  []string `json:"answers"`
+}
+
+// Kind is a type of question.
+type Kind string
+
+const (
+	// Country asks "Which country is X from?" with the country of the flag
+	Country Kind = "country"
+	// MissingIngredient asks "Which ingredient is NOT in X?"
+	MissingIngredient Kind = "missing-ingredient"
+	// MealWith asks "Which meal is made with Y?"
+	MealWith Kind = "meal-with"
+)
+
+// Kinds are all kinds of question, in the order Generate mixes them.
+var Kinds = []Kind{Country, MissingIngredient, MealWith}
+
+// Options tweak Generate.
+type Options struct {
+	// Count is the number of questions, at most one per meal and kind.
+	// Zero asks for all of them.
+	Count int
+	// Choices is the number of options of a question, 4 when zero
+	Choices int
+	// Kinds are the kinds of question to ask, all when empty
+	Kinds []Kind
+	// Seed picks the meals and the distractors. The same meals and seed
+	// give the same questions.
+	Seed uint64
+}
+
+// meal is a crawled meal with its ingredients filed by search_index.Key.
+type meal struct {
+	name        string
+	country     string
+	ingredients map[string]string // key -> name
+	keys        []string          // sorted keys of ingredients
+}
+
+type generator struct {
+	rng       *rand.Rand
+	choices   int
+	meals     []meal
+	countries []string          // sorted
+	names     map[string]string // ingredient key -> name, over all meals
+	keys      []string          // sorted keys of names
+}
+
+// Generate asks questions about the meals. The wrong options are drawn from
+// the other meals: the countries they come from, the ingredients they use
+// or their names. Meals with the same name are asked about once.
+func Generate(meals []detail_parse.MealDetail, opts Options) []Question {
+	g := &generator{
+		rng:     rand.New(rand.NewPCG(opts.Seed, opts.Seed)),
+		choices: opts.Choices,
+		names:   map[string]string{},
+	}
+	if g.choices <= 0 {
+		g.choices = 4
+	}
+	seen := map[string]bool{}
+	for _, d := range meals {
+		m := meal{name: d.DisplayName(), ingredients: map[string]string{}}
+		if m.name == "" || seen[m.name] {
+			continue
+		}
+		seen[m.name] = true
+		if d.Flag != "" {
+			m.country = d.Country()
+			if !slices.Contains(g.countries, m.country) {
+				g.countries = append(g.countries, m.country)
+			}
+		}
+		for _, ing := range d.Ingredents {
+			name := ingredient_catalog.IngredientName(ing)
+			key := search_index.Key(name)
+			if key == "" || m.ingredients[key] != "" {
+				continue
+			}
+			m.ingredients[key] = name
+			m.keys = append(m.keys, key)
+			if g.names[key] == "" {
+				g.names[key] = name
+				g.keys = append(g.keys, key)
+			}
+		}
+		slices.Sort(m.keys)
+		g.meals = append(g.meals, m)
+	}
+	slices.Sort(g.countries)
+	slices.Sort(g.keys)
+
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+	// Every meal and kind once, shuffled, so a short quiz still mixes both
+	type ask struct {
+		meal int
+		kind Kind
+	}
+	var asks []ask
+	for i := range g.meals {
+		for _, kind := range kinds {
+			asks = append(asks, ask{i, kind})
+		}
+	}
+	g.rng.Shuffle(len(asks), func(i, j int) { asks[i], asks[j] = asks[j], asks[i] })
+
+	var questions []Question
+	for _, a := range asks {
+		if opts.Count > 0 && len(questions) == opts.Count {
+			break
+		}
+		if q, ok := g.question(g.meals[a.meal], a.kind); ok {
+			questions = append(questions, q)
+		}
+	}
+	return questions
+}
+
+// question asks about m, false when there are not enough distractors.
+func (g *generator) question(m meal, kind Kind) (Question, bool) {
+	switch kind {
+	case Country:
+		if m.country == "" {
+			return Question{}, false
+		}
+		wrong := g.pick(g.countries, g.choices-1, func(c string) bool { return c != m.country })
+		return g.ask(fmt.Sprintf("Which country is %s from?", m.name), m.country, wrong)
+
+	case MissingIngredient:
+		if len(m.keys) < g.choices-1 {
+			return Question{}, false
+		}
+		missing := g.pick(g.keys, 1, func(key string) bool { return m.ingredients[key] == "" })
+		if len(missing) == 0 {
+			return Question{}, false
+		}
+		var in []string
+		for _, key := range g.pick(m.keys, g.choices-1, nil) {
+			in = append(in, m.ingredients[key])
+		}
+		return g.ask(fmt.Sprintf("Which ingredient is NOT in %s?", m.name), g.names[missing[0]], in)
+
+	case MealWith:
+		if len(m.keys) == 0 {
+			return Question{}, false
+		}
+		key := m.keys[g.rng.IntN(len(m.keys))]
+		var others []string
+		for _, other := range g.meals {
+			if other.ingredients[key] == "" {
+				others = append(others, other.name)
+			}
+		}
+		wrong := g.pick(others, g.choices-1, nil)
+		return g.ask(fmt.Sprintf("Which meal is made with %s?", m.ingredients[key]), m.name, wrong)
+	}
+	return Question{}, false
+}
+
+// pick draws n of the values that keep accepts, all of them when there are
+// fewer. A nil keep accepts every value.
+func (g *generator) pick(values []string, n int, keep func(string) bool) []string {
+	var kept []string
+	for _, v := range values {
+		if keep == nil || keep(v) {
+			kept = append(kept, v)
+		}
+	}
+	g.rng.Shuffle(len(kept), func(i, j int) { kept[i], kept[j] = kept[j], kept[i] })
+	return kept[:min(n, len(kept))]
+}
+
+// ask shuffles the answer in among the wrong options, false when there are
+// fewer wrong ones than the question needs.
+func (g *generator) ask(question, answer string, wrong []string) (Question, bool) {
+	if len(wrong) < g.choices-1 {
+		return Question{}, false
+	}
+	options := append([]string{answer}, wrong...)
+	g.rng.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	return Question{Question: question, Options: options, Answers: []string{answer}}, true
+}
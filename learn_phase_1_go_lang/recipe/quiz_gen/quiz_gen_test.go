@@ -0,0 +1,113 @@
+package quiz_gen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []detail_parse.MealDetail{
+	{ReceiptName: "Apple Frangipan Tart Recipe", Flag: "gb", Ingredents: meal_fixture.Ingredients("175g digestive biscuits", "75g butter", "200g sugar", "3 apples")},
+	{ReceiptName: "Tarte Tatin Recipe", Flag: "fr", Ingredents: meal_fixture.Ingredients("100g butter", "6 apples", "100g sugar", "1 sheet puff pastry")},
+	{ReceiptName: "Pad Thai Recipe", Flag: "th", Ingredents: meal_fixture.Ingredients("200g rice noodles", "2 eggs", "1 tbsp fish sauce", "100g prawns")},
+	{ReceiptName: "Tacos Recipe", Flag: "mx", Ingredents: meal_fixture.Ingredients("8 tortillas", "500g beef mince", "1 onion", "1 lime")},
+	{ReceiptName: "Ramen Recipe", Flag: "jp", Ingredents: meal_fixture.Ingredients("200g noodles", "2 eggs", "1 litre stock", "2 spring onions")},
+	{ReceiptName: "Tarte Tatin Recipe", Flag: "fr", Ingredents: meal_fixture.Ingredients("duplicate")},
+}
+
+func TestGenerate(t *testing.T) {
+	questions := Generate(testMeals, Options{Seed: 7})
+	// Five meals and three kinds
+	assert.Len(t, questions, 15)
+
+	kinds := map[string]int{}
+	for _, q := range questions {
+		assert.Len(t, q.Options, 4, q.Question)
+		assert.Len(t, q.Answers, 1, q.Question)
+		assert.Contains(t, q.Options, q.Answers[0], q.Question)
+		assert.Len(t, slices.Compact(slices.Sorted(slices.Values(q.Options))), 4, q.Question)
+
+		switch q.Question {
+		case "Which country is Apple Frangipan Tart from?":
+			assert.Equal(t, []string{"United Kingdom"}, q.Answers)
+		case "Which ingredient is NOT in Tarte Tatin?":
+			assert.NotContains(t, []string{"butter", "apples", "sugar", "puff pastry"}, q.Answers[0])
+			assert.Subset(t, append([]string{q.Answers[0]}, "butter", "apples", "sugar", "puff pastry"), q.Options)
+		case "Which meal is made with fish sauce?", "Which meal is made with prawns?":
+			assert.Equal(t, []string{"Pad Thai"}, q.Answers)
+		}
+		switch {
+		case strings.HasPrefix(q.Question, "Which country"):
+			kinds["country"]++
+		case strings.HasPrefix(q.Question, "Which ingredient"):
+			kinds["missing"]++
+		default:
+			kinds["meal"]++
+		}
+	}
+	assert.Equal(t, map[string]int{"country": 5, "missing": 5, "meal": 5}, kinds)
+
+	// The same seed gives the same quiz, another seed another one
+	assert.Equal(t, questions, Generate(testMeals, Options{Seed: 7}))
+	assert.NotEqual(t, questions, Generate(testMeals, Options{Seed: 8}))
+}
+
+func TestGenerate_MealWithSkipsMealsUsingTheIngredient(t *testing.T) {
+	for seed := range uint64(20) {
+		for _, q := range Generate(testMeals, Options{Seed: seed, Kinds: []Kind{MealWith}}) {
+			switch q.Question {
+			case "Which meal is made with eggs?":
+				// Pad Thai and Ramen both use eggs, never both options
+				assert.False(t, slices.Contains(q.Options, "Pad Thai") && slices.Contains(q.Options, "Ramen"), q.Options)
+			case "Which meal is made with butter?", "Which meal is made with apples?", "Which meal is made with sugar?":
+				assert.False(t, slices.Contains(q.Options, "Apple Frangipan Tart") && slices.Contains(q.Options, "Tarte Tatin"), q.Options)
+			}
+		}
+	}
+}
+
+func TestGenerate_Options(t *testing.T) {
+	questions := Generate(testMeals, Options{Count: 3, Choices: 3, Kinds: []Kind{Country}})
+	assert.Len(t, questions, 3)
+	for _, q := range questions {
+		assert.Len(t, q.Options, 3)
+	}
+
+	// Not enough countries for six options
+	assert.Empty(t, Generate(testMeals, Options{Choices: 6, Kinds: []Kind{Country}}))
+}
+
+func TestPost(t *testing.T) {
+	var got []Question
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/quiz/", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Invalid or expired token"}`))
+			return
+		}
+		var q Question
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+		got = append(got, q)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	questions := Generate(testMeals, Options{Count: 2})
+	n, err := Post(server.Client(), server.URL+"/", "secret", questions)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, questions, got)
+
+	n, err = Post(server.Client(), server.URL, "expired", questions)
+	assert.Equal(t, 0, n)
+	assert.ErrorContains(t, err, "401 Unauthorized: Invalid or expired token")
+}
@@ -0,0 +1,80 @@
+package quiz_gen
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Insert adds the questions to the quiz_table of the quiz server's
+// PostgreSQL database. Questions already in the table are skipped, so
+// seeding twice with the same seed adds nothing. It returns how many were
+// added.
+func Insert(db *sql.DB, questions []Question) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, q := range questions {
+		res, err := tx.Exec(`INSERT INTO quiz_table (question, options, answers)
+			SELECT $1::text, $2::text[], $3::text[]
+			WHERE NOT EXISTS (SELECT 1 FROM quiz_table WHERE question = $1::text)`,
+			q.Question, pq.Array(q.Options), pq.Array(q.Answers))
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+	return added, tx.Commit()
+}
+
+// Post creates the questions through POST /api/quiz of the quiz server at
+// baseURL, e.g. http://localhost:8080. token is the JWT returned by
+// /api/auth/login. The server keeps duplicates, so every call adds all the
+// questions. It returns how many were created before an error.
+func Post(client *http.Client, baseURL, token string, questions []Question) (int, error) {
+	// The quiz routes are registered on "/" of their group, which also
+	// saves the redirect of gin
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/api/quiz/"
+	for i, q := range questions {
+		body, err := json.Marshal(q)
+		if err != nil {
+			return i, err
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return i, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		if err != nil {
+			return i, err
+		}
+		reply, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			var apiErr struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(reply, &apiErr) != nil || apiErr.Error == "" {
+				apiErr.Error = strings.TrimSpace(string(reply))
+			}
+			return i, fmt.Errorf("POST %s: %s: %s", endpoint, resp.Status, apiErr.Error)
+		}
+	}
+	return len(questions), nil
+}
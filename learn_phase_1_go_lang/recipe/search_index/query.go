@@ -0,0 +1,337 @@
+package search_index
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go_lang/parsing/ingredient_catalog"
+)
+
+// Op is the kind of a Query node.
+type Op int
+
+const (
+	Term Op = iota
+	And
+	Or
+	Not
+)
+
+// Query is a parsed search query, a tree of terms joined by And, Or and Not.
+type Query struct {
+	Op   Op
+	Kids []*Query
+	// Field of a Term: "" for any text, or a key of textFields or filterFields
+	Field string
+	Value string   // the term as written
+	Words []string // Value tokenized, for the text fields
+}
+
+// textFields are the fields a term of each text field looks in.
+var textFields = map[string][]Field{
+	"":             {Name, Ingredients, Instructions},
+	"name":         {Name},
+	"ingredient":   {Ingredients},
+	"instructions": {Instructions},
+}
+
+// filterFields compare a meal attribute as a whole, ignoring case.
+var filterFields = map[string]func(Doc) string{
+	"flag":     func(d Doc) string { return d.Flag },
+	"area":     func(d Doc) string { return d.Area },
+	"category": func(d Doc) string { return d.Category },
+}
+
+// Parse reads a query. Words must all be found, in any field:
+//
+//	apple tart                both words
+//	apple OR pear             either word
+//	tart -apple, tart NOT apple
+//	(apple OR pear) AND tart  AND is optional
+//	name:tart                 only in the meal name
+//	ingredient:"plain flour"  an ingredient with both words
+//	instructions:bake
+//	flag:gb area:british category:dessert
+//
+// An empty query matches every meal.
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos].text)
+	}
+	return q, nil
+}
+
+type token struct {
+	text   string
+	quoted bool // an operator in quotes is a plain word
+}
+
+// lex splits a query into words, quoted phrases, parentheses and the "-"
+// of a negated term. A field prefix stays with its value, quoted or not.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	r := []rune(query)
+	for i := 0; i < len(r); {
+		switch {
+		case unicode.IsSpace(r[i]):
+			i++
+		case r[i] == '(' || r[i] == ')':
+			tokens = append(tokens, token{text: string(r[i])})
+			i++
+		case r[i] == '-' && i+1 < len(r) && !unicode.IsSpace(r[i+1]):
+			tokens = append(tokens, token{text: "NOT"})
+			i++
+		default:
+			start, quoted := i, false
+			for i < len(r) && !unicode.IsSpace(r[i]) && r[i] != '(' && r[i] != ')' {
+				if r[i] == '"' {
+					end := slices.Index(r[i+1:], '"')
+					if end < 0 {
+						return nil, fmt.Errorf("unclosed quote in query")
+					}
+					i += end + 1
+					quoted = true
+				}
+				i++
+			}
+			tokens = append(tokens, token{text: string(r[start:i]), quoted: quoted})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) operator(op string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && t.text == op
+}
+
+func (p *parser) or() (*Query, error) {
+	q, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	kids := []*Query{q}
+	for p.operator("OR") {
+		p.pos++
+		q, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, q)
+	}
+	for _, kid := range kids {
+		if len(kids) > 1 && kid.Op == And && len(kid.Kids) == 0 {
+			return nil, fmt.Errorf("OR needs a term on both sides")
+		}
+	}
+	if len(kids) == 1 {
+		return kids[0], nil
+	}
+	return &Query{Op: Or, Kids: kids}, nil
+}
+
+func (p *parser) and() (*Query, error) {
+	var kids []*Query
+	var dropped []string
+	for {
+		if _, ok := p.peek(); !ok || p.operator(")") || p.operator("OR") {
+			break
+		}
+		if p.operator("AND") {
+			p.pos++
+			continue
+		}
+		q, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if q.noWords() {
+			dropped = append(dropped, q.Value)
+			continue
+		}
+		kids = append(kids, q)
+	}
+	// Stop words next to other terms are left out, on their own they are
+	// most likely a mistake
+	if len(kids) == 0 && len(dropped) > 0 {
+		return nil, fmt.Errorf("nothing to search for in %q, only stop words", strings.Join(dropped, " "))
+	}
+	if len(kids) == 1 {
+		return kids[0], nil
+	}
+	return &Query{Op: And, Kids: kids}, nil
+}
+
+func (p *parser) unary() (*Query, error) {
+	t, _ := p.peek()
+	p.pos++
+	switch {
+	case p.pos > len(p.tokens):
+		return nil, fmt.Errorf("query ends too early")
+	case !t.quoted && t.text == "NOT":
+		if _, ok := p.peek(); !ok || p.operator(")") || p.operator("OR") {
+			return nil, fmt.Errorf("nothing to exclude after NOT")
+		}
+		q, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if q.noWords() {
+			return nil, fmt.Errorf("nothing to exclude in %q, only stop words", q.Value)
+		}
+		return &Query{Op: Not, Kids: []*Query{q}}, nil
+	case !t.quoted && t.text == "(":
+		q, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.operator(")") {
+			return nil, fmt.Errorf("missing ) in query")
+		}
+		p.pos++
+		return q, nil
+	case !t.quoted && t.text == ")":
+		return nil, fmt.Errorf("unexpected ) in query")
+	}
+	return term(t.text)
+}
+
+// term reads a word or phrase with its optional field prefix.
+func term(text string) (*Query, error) {
+	field, value := "", text
+	if prefix, rest, found := strings.Cut(text, ":"); found && prefix != "" && !strings.ContainsFunc(prefix, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		field, value = strings.ToLower(prefix), rest
+		if field == "ingredients" {
+			field = "ingredient"
+		}
+		if _, ok := textFields[field]; !ok && filterFields[field] == nil {
+			return nil, fmt.Errorf("unknown field %q in query, want name, ingredient, instructions, flag, area or category", prefix)
+		}
+	}
+	value = strings.TrimSpace(strings.ReplaceAll(value, `"`, ""))
+	if value == "" {
+		return nil, fmt.Errorf("empty term %q in query", text)
+	}
+	return &Query{Op: Term, Field: field, Value: value, Words: Tokenize(value)}, nil
+}
+
+// noWords reports whether q is a text term made only of stop words or
+// punctuation, which would match every meal.
+func (q *Query) noWords() bool {
+	return q.Op == Term && filterFields[q.Field] == nil && len(q.Words) == 0
+}
+
+// searcher evaluates a query against an index.
+type searcher struct {
+	ix      *Index
+	catalog *ingredient_catalog.Catalog
+}
+
+// eval returns which docs match q.
+func (s *searcher) eval(q *Query) []bool {
+	n := len(s.ix.Docs)
+	matched := make([]bool, n)
+	switch q.Op {
+	case And:
+		for i := range matched {
+			matched[i] = true
+		}
+		for _, kid := range q.Kids {
+			m := s.eval(kid)
+			for i := range matched {
+				matched[i] = matched[i] && m[i]
+			}
+		}
+	case Or:
+		for _, kid := range q.Kids {
+			m := s.eval(kid)
+			for i := range matched {
+				matched[i] = matched[i] || m[i]
+			}
+		}
+	case Not:
+		m := s.eval(q.Kids[0])
+		for i := range matched {
+			matched[i] = !m[i]
+		}
+	case Term:
+		s.evalTerm(q, matched)
+	}
+	return matched
+}
+
+func (s *searcher) evalTerm(q *Query, matched []bool) {
+	if attr := filterFields[q.Field]; attr != nil {
+		for i, doc := range s.ix.Docs {
+			matched[i] = strings.EqualFold(strings.TrimSpace(attr(doc)), q.Value)
+		}
+		return
+	}
+	fields := textFields[q.Field]
+	for i := range matched {
+		matched[i] = true
+	}
+	for _, word := range q.Words {
+		found := make([]bool, len(matched))
+		for _, p := range s.ix.Terms[word] {
+			for _, f := range fields {
+				if p.TF[f] > 0 {
+					found[p.Doc] = true
+				}
+			}
+		}
+		for i := range matched {
+			matched[i] = matched[i] && found[i]
+		}
+	}
+	if q.Field != "ingredient" {
+		return
+	}
+	// All words must be in one ingredient, or the catalog must file one of
+	// the meal's ingredients under the term
+	var entry ingredient_catalog.Entry
+	inCatalog := false
+	if s.catalog != nil {
+		entry, inCatalog = s.catalog.Lookup(q.Value)
+	}
+	for i, doc := range s.ix.Docs {
+		if matched[i] && len(q.Words) > 1 {
+			matched[i] = slices.ContainsFunc(doc.Ingredients, func(ing Ingredient) bool {
+				words := Tokenize(ing.Name)
+				return !slices.ContainsFunc(q.Words, func(w string) bool { return !slices.Contains(words, w) })
+			})
+		}
+		if !matched[i] && inCatalog {
+			matched[i] = slices.ContainsFunc(doc.Ingredients, func(ing Ingredient) bool {
+				if ing.ID == "" {
+					e, ok := s.catalog.Lookup(ing.Name)
+					return ok && e.ID == entry.ID
+				}
+				return ing.ID == entry.ID
+			})
+		}
+	}
+}
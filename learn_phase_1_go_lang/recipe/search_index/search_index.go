@@ -0,0 +1,324 @@
+package search_index
+
+import (
+	"cmp"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+)
+
+// version is bumped whenever the saved layout or the tokenizer changes, so
+// an index written by an older build is rebuilt instead of misread.
+const version = 1
+
+// Field is a part of a recipe the index holds words of.
+type Field int
+
+const (
+	Name Field = iota
+	Ingredients
+	Instructions
+	numFields
+)
+
+// weights make a word in the name count more than one in the instructions.
+var weights = [numFields]float64{Name: 3, Ingredients: 2, Instructions: 1}
+
+// Doc is what the index keeps of one meal, enough to print a result without
+// loading the crawl.
+type Doc struct {
+	Name        string
+	MealID      int
+	SourceURL   string
+	Flag        string
+	Area        string
+	Category    string
+	Ingredients []Ingredient
+	Lengths     [numFields]int // number of words per field
+}
+
+// Ingredient is the name an ingredient is filed under and its catalog ID,
+// if the catalog was built before indexing.
+type Ingredient struct {
+	Name string
+	ID   string
+}
+
+// Posting says how often a word occurs in each field of one doc.
+type Posting struct {
+	Doc int
+	TF  [numFields]int
+}
+
+// Source identifies the crawl file an index was built from.
+type Source struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Index is an inverted index over the meals of a crawl.
+type Index struct {
+	Version int
+	Source  Source
+	Docs    []Doc
+	Terms   map[string][]Posting
+	avg     [numFields]float64
+}
+
+// Build indexes meals. Words are lower cased and plurals folded, so
+// "Tomatoes" finds "tomato".
+func Build(meals []detail_parse.MealDetail) *Index {
+	ix := &Index{Version: version, Terms: map[string][]Posting{}}
+	for i, meal := range meals {
+		doc := Doc{
+			Name:      meal.DisplayName(),
+			MealID:    meal.ID,
+			SourceURL: meal.SourceURL,
+			Flag:      meal.Flag,
+			Area:      meal.Area,
+			Category:  meal.Category,
+		}
+		var ingredients []string
+		for _, ing := range meal.Ingredents {
+			name := ingredient_catalog.IngredientName(ing)
+			doc.Ingredients = append(doc.Ingredients, Ingredient{Name: name, ID: ing.IngredientID})
+			ingredients = append(ingredients, name)
+			if ing.Name != "" && !strings.EqualFold(ing.Name, name) {
+				ingredients = append(ingredients, ing.Name)
+			}
+		}
+		counts := map[string]*Posting{}
+		for field, text := range [numFields]string{
+			Name:         doc.Name,
+			Ingredients:  strings.Join(ingredients, " "),
+			Instructions: meal.Receipt,
+		} {
+			words := Tokenize(text)
+			doc.Lengths[field] = len(words)
+			for _, word := range words {
+				p := counts[word]
+				if p == nil {
+					p = &Posting{Doc: i}
+					counts[word] = p
+				}
+				p.TF[field]++
+			}
+		}
+		for word, p := range counts {
+			ix.Terms[word] = append(ix.Terms[word], *p)
+		}
+		ix.Docs = append(ix.Docs, doc)
+	}
+	ix.average()
+	return ix
+}
+
+func (ix *Index) average() {
+	ix.avg = [numFields]float64{}
+	for _, doc := range ix.Docs {
+		for f := range numFields {
+			ix.avg[f] += float64(doc.Lengths[f])
+		}
+	}
+	for f := range numFields {
+		ix.avg[f] = max(ix.avg[f]/float64(max(len(ix.Docs), 1)), 1)
+	}
+}
+
+// stopWords are too common in recipes to tell them apart.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true, "in": true,
+	"into": true, "on": true, "or": true, "with": true, "for": true, "it": true, "is": true,
+}
+
+// Tokenize splits text into the words the index holds.
+func Tokenize(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[word] {
+			words = append(words, stem(word))
+		}
+	}
+	return words
+}
+
+// Key makes the spellings of one name equal: its words lower cased and
+// without plurals, so "Free-range Eggs" and "free range egg" match.
+func Key(name string) string {
+	return strings.Join(Tokenize(name), " ")
+}
+
+// stem folds plurals: "tomatoes" and "tomato", "berries" and "berry".
+func stem(word string) string {
+	switch {
+	case len(word) <= 3:
+		return word
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "oes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// Result is one meal found by Search.
+type Result struct {
+	Doc
+	Score float64
+}
+
+// Options tune a search.
+type Options struct {
+	// Limit is the most results returned, all of them when 0
+	Limit int
+	// Catalog, if set, lets ingredient terms find every spelling of an
+	// ingredient, e.g. "Salted Butter" for "butter"
+	Catalog *ingredient_catalog.Catalog
+}
+
+// Search runs query, see Parse for the syntax, and returns the matching
+// meals best first. Matches are ranked with BM25 over the three fields, a
+// word in the name weighing most. A query of only filters ranks by name.
+func (ix *Index) Search(query string, opts Options) ([]Result, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	s := &searcher{ix: ix, catalog: opts.Catalog}
+	matched := s.eval(q)
+	var results []Result
+	for i, ok := range matched {
+		if ok {
+			results = append(results, Result{Doc: ix.Docs[i], Score: s.score(q, i)})
+		}
+	}
+	slices.SortStableFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// BM25 parameters, the usual values.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// score adds up the BM25 score of every word of q that is not negated.
+func (s *searcher) score(q *Query, doc int) float64 {
+	switch q.Op {
+	case Not:
+		return 0
+	case Term:
+	default:
+		total := 0.0
+		for _, kid := range q.Kids {
+			total += s.score(kid, doc)
+		}
+		return total
+	}
+	fields, ok := textFields[q.Field]
+	if !ok {
+		return 0
+	}
+	total := 0.0
+	for _, word := range q.Words {
+		postings := s.ix.Terms[word]
+		i, found := slices.BinarySearchFunc(postings, doc, func(p Posting, doc int) int { return cmp.Compare(p.Doc, doc) })
+		if !found {
+			continue
+		}
+		tf := 0.0
+		for _, f := range fields {
+			norm := 1 - b + b*float64(s.ix.Docs[doc].Lengths[f])/s.ix.avg[f]
+			tf += weights[f] * float64(postings[i].TF[f]) / norm
+		}
+		n, df := float64(len(s.ix.Docs)), float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		total += idf * tf * (k1 + 1) / (tf + k1)
+	}
+	return total
+}
+
+// Stale reports whether the crawl file changed since the index was built,
+// or the index was written by another version.
+func (ix *Index) Stale(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || ix.Version != version {
+		return true
+	}
+	return ix.Source.Path != path || ix.Source.Size != info.Size() || !ix.Source.ModTime.Equal(info.ModTime())
+}
+
+// BuildFile indexes the crawl at path and remembers the file, see Stale.
+func BuildFile(path string) (*Index, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	meals, err := detail_parse.LoadFinalMeals(path)
+	if err != nil {
+		return nil, err
+	}
+	ix := Build(meals)
+	ix.Source = Source{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	return ix, nil
+}
+
+// Save writes the index in gob, through a temporary file so a crash never
+// leaves half an index behind.
+func (ix *Index) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(ix); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads an index written by Save.
+func Load(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ix := &Index{}
+	if err := gob.NewDecoder(file).Decode(ix); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	ix.average()
+	return ix, nil
+}
@@ -0,0 +1,127 @@
+package search_index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []detail_parse.MealDetail{
+	{
+		ReceiptName: "Apple Frangipan Tart Recipe", Flag: "gb", Area: "British", Category: "Dessert",
+		Receipt:    "Peel the apples. Bake for 20-25 minutes.",
+		Ingredents: []detail_parse.Ingredient{meal_fixture.Ingredient("175g/6oz digestive biscuits"), meal_fixture.Ingredient("75g butter"), meal_fixture.Ingredient("2 Braeburn Apples")},
+	},
+	{
+		ReceiptName: "Apple & Blackberry Crumble Recipe", Flag: "gb", Area: "British", Category: "Dessert",
+		Receipt:    "Tip the flour and sugar into a bowl. Cook the apples for 3 mins.",
+		Ingredents: []detail_parse.Ingredient{meal_fixture.Ingredient("120g plain flour"), meal_fixture.Ingredient("60g Salted Butter"), meal_fixture.Ingredient("300g blackberries")},
+	},
+	{
+		ReceiptName: "Tarte Tatin Recipe", Flag: "fr", Area: "French", Category: "Dessert",
+		Receipt:    "Melt the butter, add the pears and the apples.",
+		Ingredents: []detail_parse.Ingredient{meal_fixture.Ingredient("100g butter"), meal_fixture.Ingredient("4 pears")},
+	},
+	{
+		ReceiptName: "Chicken Curry Recipe", Flag: "in", Area: "Indian", Category: "Chicken",
+		Receipt:    "Fry the onion, add the chicken and simmer.",
+		Ingredents: []detail_parse.Ingredient{meal_fixture.Ingredient("1 onion"), meal_fixture.Ingredient("500g chicken thighs"), meal_fixture.Ingredient("1 tbsp plain yogurt")},
+	},
+}
+
+func names(results []Result) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Name)
+	}
+	return out
+}
+
+func search(t *testing.T, ix *Index, query string, opts Options) []string {
+	results, err := ix.Search(query, opts)
+	assert.NoError(t, err, query)
+	return names(results)
+}
+
+func TestSearch(t *testing.T) {
+	ix := Build(testMeals)
+
+	// A word in the name ranks first, plurals are folded
+	assert.Equal(t, []string{"Apple Frangipan Tart", "Apple & Blackberry Crumble", "Tarte Tatin"}, search(t, ix, "apple", Options{}))
+	assert.Equal(t, []string{"Tarte Tatin"}, search(t, ix, "pear", Options{}))
+
+	assert.Equal(t, []string{"Apple & Blackberry Crumble"}, search(t, ix, "apple flour", Options{}))
+	assert.ElementsMatch(t, []string{"Tarte Tatin", "Chicken Curry"}, search(t, ix, "pears OR chicken", Options{}))
+	assert.ElementsMatch(t, []string{"Apple Frangipan Tart", "Apple & Blackberry Crumble"}, search(t, ix, "apple -pear", Options{}))
+	assert.ElementsMatch(t, []string{"Apple Frangipan Tart", "Apple & Blackberry Crumble"}, search(t, ix, "apple AND NOT (pear OR chicken)", Options{}))
+	assert.Equal(t, []string{"Tarte Tatin"}, search(t, ix, "name:tarte", Options{}))
+	assert.Empty(t, search(t, ix, "instructions:blackberry", Options{}))
+	assert.Equal(t, []string{"Apple & Blackberry Crumble"}, search(t, ix, "ingredient:blackberry", Options{}))
+
+	// Filters compare the whole value
+	assert.Equal(t, []string{"Tarte Tatin"}, search(t, ix, "butter flag:fr", Options{}))
+	assert.Equal(t, []string{"Apple & Blackberry Crumble", "Apple Frangipan Tart"}, search(t, ix, `area:british`, Options{}))
+	assert.Len(t, search(t, ix, "category:dessert", Options{Limit: 2}), 2)
+	assert.Len(t, search(t, ix, "", Options{}), 4)
+}
+
+func TestSearch_Ingredients(t *testing.T) {
+	ix := Build(testMeals)
+
+	// All the words of an ingredient must be in the same one
+	assert.Equal(t, []string{"Apple & Blackberry Crumble"}, search(t, ix, `ingredient:"plain flour"`, Options{}))
+	assert.Empty(t, search(t, ix, `ingredient:"plain chicken"`, Options{}))
+	assert.ElementsMatch(t, []string{"Apple Frangipan Tart", "Apple & Blackberry Crumble", "Tarte Tatin"},
+		search(t, ix, `ingredient:butter`, Options{}))
+	assert.Equal(t, []string{"Apple & Blackberry Crumble"}, search(t, ix, `ingredient:butter ingredient:"plain flour"`, Options{}))
+
+	// The catalog finds an ingredient by any of its aliases
+	catalog := ingredient_catalog.Build(testMeals, ingredient_catalog.Aliases{"Beurre": "Butter"})
+	assert.Empty(t, search(t, ix, `ingredient:beurre`, Options{}))
+	// Salted butter is an ingredient of its own
+	assert.ElementsMatch(t, []string{"Apple Frangipan Tart", "Tarte Tatin"}, search(t, ix, `ingredient:beurre`, Options{Catalog: catalog}))
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, query := range []string{"(apple", "apple)", "NOT", `"apple`, "colour:red", "apple OR", "OR apple", "name:", "the", "ingredient:of", "&", "apple OR the", "tart -the"} {
+		_, err := Parse(query)
+		assert.Error(t, err, query)
+	}
+	q, err := Parse(`"NOT" apple`)
+	assert.NoError(t, err)
+	assert.Equal(t, And, q.Op)
+	// Stop words next to other terms are left out
+	q, err = Parse("the apple & tart")
+	assert.NoError(t, err)
+	assert.Equal(t, And, q.Op)
+	assert.Len(t, q.Kids, 2)
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	crawl := filepath.Join(dir, "final_items.json")
+	assert.NoError(t, os.WriteFile(crawl, []byte(`[{"receipt_name": "Tarte Tatin Recipe", "receipt": "Add the pears.", "flag": "fr"}]`), 0644))
+
+	ix, err := BuildFile(crawl)
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "search_index.gob")
+	assert.NoError(t, ix.Save(path))
+
+	loaded, err := Load(path)
+	assert.NoError(t, err)
+	assert.False(t, loaded.Stale(crawl))
+	assert.Equal(t, []string{"Tarte Tatin"}, search(t, loaded, "pear", Options{}))
+	assert.Equal(t, search(t, ix, "pear", Options{}), search(t, loaded, "pear", Options{}))
+
+	// Writing the crawl again makes the index stale
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(crawl, later, later))
+	assert.True(t, loaded.Stale(crawl))
+}
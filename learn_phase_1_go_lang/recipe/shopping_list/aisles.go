@@ -0,0 +1,131 @@
+package shopping_list
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"go_lang/recipe/search_index"
+)
+
+// Other is the aisle of ingredients no keyword matches.
+const Other = "Other"
+
+// aisleKeywords file an ingredient under the aisle of the longest keyword
+// found in its name, so "Peanut Butter" is not dairy and "Garlic Clove" is
+// not a spice. On a tie the aisle listed first wins. Keywords are compared
+// after search_index.Tokenize, so most plurals match; the few it leaves
+// alone, like "peaches", are listed as well.
+var aisleKeywords = []struct {
+	aisle    string
+	keywords []string
+}{
+	{"Frozen", []string{"fries", "frozen", "ice cream"}},
+	{"Produce", []string{
+		"apple", "apricot", "artichoke", "asparagus", "aubergine", "avocado", "banana", "basil", "bean sprout",
+		"beetroot", "bell pepper", "blackberry", "blueberry", "broccoli", "cabbage", "carrot", "cauliflower",
+		"celeriac", "celery", "cherry", "chili", "chilli", "chive", "cilantro", "coriander", "corn", "courgette",
+		"cranberry", "cucumber", "date", "dill", "eggplant", "fennel", "fig", "garlic", "garlic clove", "ginger",
+		"grape", "green bean", "green pepper", "jalapeno", "kale", "leek", "lemon", "lemongrass", "lettuce",
+		"lime", "mango", "marjoram", "mint", "mushroom", "okra", "onion", "orange", "parsley", "parsnip", "pea",
+		"peach", "peaches", "pear", "pineapple", "plum", "potato", "pumpkin", "radish", "radishes", "raspberry",
+		"red pepper", "redcurrant", "rhubarb", "rocket", "rosemary", "sage", "scallion", "scotch bonnet",
+		"shallot", "spinach", "spring onion", "squash", "strawberry", "swede", "sweet potato", "sweetcorn",
+		"tarragon", "thyme", "tomato", "turnip", "yellow pepper", "zucchini",
+	}},
+	{"Meat & Fish", []string{
+		"anchovy", "bacon", "beef", "black pudding", "chicken", "chorizo", "clam", "cod", "crab", "doner", "duck",
+		"fish", "goat meat", "haddock", "ham", "herring", "kielbasa", "lamb", "lobster", "mackerel", "mince",
+		"monkfish", "mussel", "oxtail", "oyster", "pancetta", "pilchard", "pork", "prawn", "prosciutto", "salami",
+		"salmon", "sardine", "sausage", "scallop", "shrimp", "snapper", "squid", "steak", "tuna", "turkey", "veal",
+	}},
+	{"Dairy & Eggs", []string{
+		"brie", "butter", "buttermilk", "cheddar", "cheese", "cream", "creme fraiche", "custard", "egg", "feta",
+		"fromage frais", "ghee", "goat cheese", "gruyere", "gruyère", "lard", "mascarpone", "milk", "mozzarella",
+		"paneer", "parmesan", "pecorino", "ricotta", "sour cream", "tofu", "yoghurt", "yogurt",
+	}},
+	{"Bakery", []string{
+		"baguette", "bread", "brioche", "bun", "ciabatta", "croissant", "naan", "pastry", "pita", "pitta", "tortilla",
+	}},
+	{"Baking", []string{
+		"almond", "baking powder", "bicarbonate soda", "biscuit", "buckwheat", "cacao", "candied peel", "cashew",
+		"chocolate", "cocoa", "cornflour", "cornstarch", "currant", "custard powder", "desiccated coconut",
+		"dried fruit", "flour", "gelatine", "hazelnut", "icing sugar", "marshmallow", "mixed peel", "nut", "oat",
+		"oatmeal", "peanut", "pecan", "pistachio", "prune", "raisin", "starch", "suet", "sugar", "sultana",
+		"treacle", "vanilla", "walnut", "yeast",
+	}},
+	{"Dry & Canned Goods", []string{
+		"bean", "breadcrumb", "bulgur", "chickpea", "chopped tomato", "couscous", "dal", "dried apricot",
+		"farfalle", "fettuccine", "lasagne", "lentil", "macaroni", "noodle", "pasta", "penne", "plum tomato",
+		"polenta", "quinoa", "rice", "rigatoni", "spaghetti", "tagliatelle", "tinned tomato",
+	}},
+	{"Oils, Sauces & Condiments", []string{
+		"beef stock", "brandy", "caper", "chicken stock", "cider", "coconut cream", "coconut milk", "curry paste",
+		"doubanjiang", "fish sauce", "goose fat", "honey", "horseradish", "hotsauce", "jam", "ketchup",
+		"mayonnaise", "mirin", "mustard", "oil", "olive", "passata", "peanut butter", "pickle", "rose water",
+		"rum", "sake", "salsa", "sauce", "sherry", "shortening", "soy sauce", "stock", "stock cube", "stout",
+		"syrup", "tahini", "tamarind", "tomato paste", "tomato puree", "vegetable stock", "vinaigrette", "vinegar",
+		"wine", "worcestershire",
+	}},
+	{"Spices & Seasonings", []string{
+		"allspice", "bay", "black pepper", "bouquet garni", "cajun", "caraway seed", "cardamom",
+		"cayenne pepper", "chilli flake", "chilli powder", "cinnamon", "clove", "coriander seed", "cumin",
+		"curry powder", "fajita seasoning", "fennel seed", "fenugreek", "five spice", "garam masala",
+		"ground coriander", "harissa", "italian seasoning", "mixed spice", "mustard seed", "nutmeg", "oregano",
+		"paprika", "pepper", "ras el hanout", "saffron", "salt", "sesame seed", "star anise", "turmeric",
+	}},
+}
+
+// aisleOrder is the order aisles are printed in, roughly a walk through a
+// supermarket. Aisles of the user come after these, Other last.
+var aisleOrder = func() []string {
+	var names []string
+	for _, a := range aisleKeywords[1:] {
+		names = append(names, a.aisle)
+	}
+	return append(names, aisleKeywords[0].aisle)
+}()
+
+// aisleOf files an ingredient, first by the user's aisles, then by keyword.
+func aisleOf(name, key string, user map[string]string) string {
+	words := search_index.Tokenize(name)
+	for _, k := range []string{key, strings.Join(words, " ")} {
+		if aisle := user[k]; aisle != "" {
+			return aisle
+		}
+	}
+	best, bestLen := Other, 0
+	for _, a := range aisleKeywords {
+		for _, keyword := range a.keywords {
+			kw := search_index.Tokenize(keyword)
+			if len(kw) > bestLen && containsRun(words, kw) {
+				best, bestLen = a.aisle, len(kw)
+			}
+		}
+	}
+	return best
+}
+
+// containsRun reports whether words holds run as consecutive words.
+func containsRun(words, run []string) bool {
+	for i := 0; i+len(run) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(run)], run) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareAisles(a, b string) int {
+	rank := func(name string) int {
+		switch i := slices.Index(aisleOrder, name); {
+		case name == Other:
+			return len(aisleOrder) + 1
+		case i < 0:
+			return len(aisleOrder)
+		default:
+			return i
+		}
+	}
+	return cmp.Or(cmp.Compare(rank(a), rank(b)), cmp.Compare(a, b))
+}
@@ -0,0 +1,110 @@
+package shopping_list
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"go_lang/recipe/unit_convert"
+)
+
+// Formats are the names WriteFormat takes.
+var Formats = []string{"text", "markdown", "csv"}
+
+// WriteFormat writes the list in one of Formats.
+func (l *List) WriteFormat(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		return l.WriteText(w)
+	case "markdown":
+		return l.WriteMarkdown(w)
+	case "csv":
+		return l.WriteCSV(w)
+	}
+	return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+// line renders an item like "400 g Chopped Tomatoes".
+func line(it Item) string {
+	amount := it.Amount()
+	switch {
+	case amount == "":
+		return it.Name
+	case it.Unmeasured:
+		return amount + " " + it.Name + " (and some more)"
+	}
+	return amount + " " + it.Name
+}
+
+func (r Recipe) String() string {
+	return fmt.Sprintf("%s (%d servings)", r.Meal.DisplayName(), r.Servings)
+}
+
+// WriteText writes the list for a terminal, one aisle after another.
+func (l *List) WriteText(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("Shopping list for:\n")
+	for _, r := range l.Recipes {
+		fmt.Fprintf(&b, "  %s\n", r)
+	}
+	for _, a := range l.Aisles {
+		fmt.Fprintf(&b, "\n%s\n", a.Name)
+		for _, it := range a.Items {
+			fmt.Fprintf(&b, "  - %s\n", line(it))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMarkdown writes the list as a checklist, an h2 per aisle.
+func (l *List) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Shopping list\n\n")
+	for _, r := range l.Recipes {
+		fmt.Fprintf(&b, "- %s\n", r)
+	}
+	for _, a := range l.Aisles {
+		fmt.Fprintf(&b, "\n## %s\n\n", a.Name)
+		for _, it := range a.Items {
+			fmt.Fprintf(&b, "- [ ] %s\n", line(it))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteCSV writes a row per amount of an item, so an item bought in grams
+// and in cans has two rows. An item no recipe measures has one row with an
+// empty quantity.
+func (l *List) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"aisle", "ingredient", "quantity", "quantity_max", "unit", "meals"}); err != nil {
+		return err
+	}
+	for _, a := range l.Aisles {
+		for _, it := range a.Items {
+			meals := strings.Join(it.Meals, "; ")
+			if len(it.Amounts) == 0 {
+				if err := cw.Write([]string{a.Name, it.Name, "", "", "", meals}); err != nil {
+					return err
+				}
+			}
+			for _, m := range it.Amounts {
+				quantityMax := ""
+				if m.QuantityMax != 0 {
+					quantityMax = unit_convert.FormatQuantity(m.QuantityMax, m.Unit)
+				}
+				err := cw.Write([]string{
+					a.Name, it.Name, unit_convert.FormatQuantity(m.Quantity, m.Unit), quantityMax, m.Unit, meals,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
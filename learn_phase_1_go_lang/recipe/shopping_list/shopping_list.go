@@ -0,0 +1,218 @@
+package shopping_list
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/recipe/search_index"
+	"go_lang/recipe/unit_convert"
+)
+
+// Recipe is a meal to shop for and the servings to cook of it.
+type Recipe struct {
+	Meal     detail_parse.MealDetail
+	Servings int
+}
+
+// Options configure Build.
+type Options struct {
+	// BaseServings is how many servings the crawled recipes make, 4 when 0
+	BaseServings int
+	// Units is the system summed amounts are given in. Amounts in different
+	// units are summed in grams or millilitres, so Original means metric.
+	Units unit_convert.System
+	// Catalog, if set, merges the spellings of one ingredient
+	Catalog *ingredient_catalog.Catalog
+	// Aisles files ingredients by name under an aisle, before the built-in
+	// keywords are tried
+	Aisles Aisles
+}
+
+// Item is one ingredient to buy, summed over the recipes using it.
+type Item struct {
+	Name  string
+	Aisle string
+	// Amounts has one measure per kind of unit that could not be summed
+	// with the others, e.g. 400 g and 2 can
+	Amounts []ingredient_parse.Measure
+	// Unmeasured is set when a recipe gives no amount, like "Salt"
+	Unmeasured bool
+	Meals      []string
+}
+
+// Amount renders the amounts of the item like "400 g + 2 can", or "" when
+// no recipe measures it.
+func (it Item) Amount() string {
+	var parts []string
+	for _, m := range it.Amounts {
+		parts = append(parts, unit_convert.FormatMeasure(m))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// Aisle is a part of the shop and what to buy there, sorted by name.
+type Aisle struct {
+	Name  string
+	Items []Item
+}
+
+// List is a shopping list for some recipes.
+type List struct {
+	Recipes []Recipe
+	Aisles  []Aisle
+}
+
+// sum adds up the measures of one kind of unit. Known units are summed in
+// their base unit, g or ml.
+type sum struct {
+	unit     string
+	min, max float64
+	ranged   bool
+	spoons   bool // every measure was in tsp or tbsp
+}
+
+type entry struct {
+	item Item
+	sums []*sum
+}
+
+// Build scales every recipe to its servings, the base servings when 0, and
+// merges their ingredients. An ingredient is the same in two recipes when
+// the catalog files them under one entry, or when their names only differ
+// in case and plurals.
+func Build(recipes []Recipe, opts Options) *List {
+	base := cmp.Or(opts.BaseServings, 4)
+	sys := opts.Units
+	if sys == "" || sys == unit_convert.Original {
+		sys = unit_convert.Metric
+	}
+	aisles := opts.Aisles.normalized()
+	list := &List{}
+	var order []string
+	entries := map[string]*entry{}
+	for _, r := range recipes {
+		r.Servings = cmp.Or(r.Servings, base)
+		list.Recipes = append(list.Recipes, r)
+		meal := unit_convert.ScaleMeal(r.Meal, base, r.Servings)
+		for _, ing := range meal.Ingredents {
+			key, name := identify(ing, opts.Catalog)
+			if key == "" {
+				continue
+			}
+			e := entries[key]
+			if e == nil {
+				e = &entry{item: Item{Name: name, Aisle: aisleOf(name, key, aisles)}}
+				entries[key] = e
+				order = append(order, key)
+			}
+			if !slices.Contains(e.item.Meals, meal.DisplayName()) {
+				e.item.Meals = append(e.item.Meals, meal.DisplayName())
+			}
+			e.add(ing.Measure)
+		}
+	}
+
+	byAisle := map[string][]Item{}
+	for _, key := range order {
+		e := entries[key]
+		for _, s := range e.sums {
+			e.item.Amounts = append(e.item.Amounts, s.measure(sys))
+		}
+		byAisle[e.item.Aisle] = append(byAisle[e.item.Aisle], e.item)
+	}
+	for name, items := range byAisle {
+		slices.SortFunc(items, func(a, b Item) int {
+			return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+		list.Aisles = append(list.Aisles, Aisle{Name: name, Items: items})
+	}
+	slices.SortFunc(list.Aisles, func(a, b Aisle) int { return compareAisles(a.Name, b.Name) })
+	return list
+}
+
+// identify returns the key ingredients are merged on and the name to print.
+func identify(ing detail_parse.Ingredient, catalog *ingredient_catalog.Catalog) (string, string) {
+	name := ingredient_catalog.IngredientName(ing)
+	if catalog != nil {
+		for _, n := range []string{ing.IngredientID, name} {
+			if e, ok := catalog.Lookup(n); n != "" && ok {
+				return e.ID, e.Name
+			}
+		}
+	}
+	return search_index.Key(name), name
+}
+
+func (e *entry) add(m ingredient_parse.Measure) {
+	if m.Quantity == 0 {
+		e.item.Unmeasured = true
+		return
+	}
+	unit, factor, known := unit_convert.Base(m.Unit)
+	if !known {
+		unit, factor = strings.ToLower(m.Unit), 1
+	}
+	i := slices.IndexFunc(e.sums, func(s *sum) bool { return s.unit == unit })
+	if i < 0 {
+		e.sums = append(e.sums, &sum{unit: unit, spoons: true})
+		i = len(e.sums) - 1
+	}
+	s := e.sums[i]
+	s.min += m.Quantity * factor
+	s.max += cmp.Or(m.QuantityMax, m.Quantity) * factor
+	s.ranged = s.ranged || m.QuantityMax != 0
+	s.spoons = s.spoons && (m.Unit == "tsp" || m.Unit == "tbsp")
+}
+
+// measure picks a readable unit for the sum. Spoons stay spoons, so three
+// recipes asking for a tbsp of oil give 3 tbsp rather than 44 ml.
+func (s *sum) measure(sys unit_convert.System) ingredient_parse.Measure {
+	m := ingredient_parse.Measure{Quantity: s.min, Unit: s.unit}
+	if s.ranged {
+		m.QuantityMax = s.max
+	}
+	if _, _, known := unit_convert.Base(s.unit); !known {
+		return m
+	}
+	if s.spoons {
+		_, tsp, _ := unit_convert.Base("tsp")
+		m.Quantity, m.QuantityMax, m.Unit = m.Quantity/tsp, m.QuantityMax/tsp, "tsp"
+	}
+	return unit_convert.ConvertMeasure(m, sys)
+}
+
+// Aisles maps an ingredient to the aisle it is bought in, like
+// "Tahini": "World Foods".
+type Aisles map[string]string
+
+// LoadAisles reads the user's aisles, a JSON object of ingredient to aisle.
+// An empty path means none.
+func LoadAisles(path string) (Aisles, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var aisles Aisles
+	if err := json.Unmarshal(data, &aisles); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return aisles, nil
+}
+
+func (a Aisles) normalized() map[string]string {
+	out := map[string]string{}
+	for name, aisle := range a {
+		out[search_index.Key(name)] = aisle
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package shopping_list
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/meal_fixture"
+	"go_lang/recipe/unit_convert"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	pancakes = meal_fixture.Meal("Pancakes", "100g plain flour", "2 Eggs", "300ml milk", "Salt", "25g butter", "1 tbsp sunflower oil")
+	stew     = meal_fixture.Meal("Bean Stew", "1 egg", "2 tins chopped tomatoes", "1-2 tsp sunflower oil", "400g tomatoes", "Salt")
+)
+
+func item(l *List, name string) Item {
+	for _, a := range l.Aisles {
+		for _, it := range a.Items {
+			if strings.EqualFold(it.Name, name) {
+				return it
+			}
+		}
+	}
+	return Item{}
+}
+
+func aisleNames(l *List) []string {
+	var names []string
+	for _, a := range l.Aisles {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func TestBuild(t *testing.T) {
+	l := Build([]Recipe{{Meal: pancakes, Servings: 8}, {Meal: stew}}, Options{})
+
+	assert.Equal(t, []Recipe{{Meal: pancakes, Servings: 8}, {Meal: stew, Servings: 4}}, l.Recipes)
+	assert.Equal(t, []string{"Produce", "Dairy & Eggs", "Baking", "Oils, Sauces & Condiments", "Spices & Seasonings"}, aisleNames(l))
+
+	// Eggs and egg are one item, pancakes doubled to 8 servings
+	eggs := item(l, "Eggs")
+	assert.Equal(t, []ingredient_parse.Measure{{Quantity: 5}}, eggs.Amounts)
+	assert.Equal(t, []string{"Pancakes", "Bean Stew"}, eggs.Meals)
+	assert.Equal(t, "Dairy & Eggs", eggs.Aisle)
+
+	assert.Equal(t, "200 g", item(l, "plain flour").Amount())
+	assert.Equal(t, "600 ml", item(l, "milk").Amount())
+	// Spoons stay spoons, and a range makes the sum a range
+	assert.Equal(t, "2.33-2.67 tbsp", item(l, "sunflower oil").Amount())
+	// Salt is never measured
+	salt := item(l, "Salt")
+	assert.Empty(t, salt.Amounts)
+	assert.True(t, salt.Unmeasured)
+	// Tins can't be summed with grams
+	assert.Equal(t, "2 tin + 400 g", item(l, "tomatoes").Amount())
+}
+
+func TestBuild_Units(t *testing.T) {
+	soup := meal_fixture.Meal("Soup", "1 lb potatoes", "200g potatoes", "2 cups stock", "500ml stock")
+	l := Build([]Recipe{{Meal: soup}}, Options{})
+	assert.Equal(t, "654 g", item(l, "potatoes").Amount())
+	assert.Equal(t, "973 ml", item(l, "stock").Amount())
+
+	l = Build([]Recipe{{Meal: soup}}, Options{Units: unit_convert.Imperial})
+	assert.Equal(t, "1.44 lb", item(l, "potatoes").Amount())
+	assert.Equal(t, "4.11 cup", item(l, "stock").Amount())
+
+	// A measured and an unmeasured use of one ingredient
+	l = Build([]Recipe{{Meal: meal_fixture.Meal("Chips", "1kg potatoes", "Potatoes")}}, Options{})
+	assert.Equal(t, "1 kg", item(l, "potatoes").Amount())
+	assert.True(t, item(l, "potatoes").Unmeasured)
+}
+
+func TestBuild_CatalogAndAisles(t *testing.T) {
+	catalog := ingredient_catalog.Build([]detail_parse.MealDetail{pancakes}, ingredient_catalog.Aliases{"Salted Butter": "Butter"})
+	toast := meal_fixture.Meal("Toast", "2 slices bread", "10g salted butter")
+	l := Build([]Recipe{{Meal: pancakes}, {Meal: toast}}, Options{
+		Catalog: catalog,
+		Aisles:  Aisles{"breads": "Freezer Bread", "Sunflower Oil": "Oils"},
+	})
+
+	butter := item(l, "Butter")
+	assert.Equal(t, "35 g", butter.Amount())
+	assert.Equal(t, []string{"Pancakes", "Toast"}, butter.Meals)
+	assert.Equal(t, "Freezer Bread", item(l, "bread").Aisle)
+	assert.Equal(t, "Oils", item(l, "sunflower oil").Aisle)
+	// Aisles of the user come after the built-in ones
+	assert.Equal(t, []string{"Dairy & Eggs", "Baking", "Spices & Seasonings", "Freezer Bread", "Oils"}, aisleNames(l))
+}
+
+func TestWriteFormat(t *testing.T) {
+	l := Build([]Recipe{{Meal: meal_fixture.Meal("Chips", "1kg potatoes", "2 tbsp oil", "Salt")}, {Meal: meal_fixture.Meal("Mash", "500g potatoes", "50g butter"), Servings: 2}}, Options{})
+
+	var b strings.Builder
+	assert.NoError(t, l.WriteFormat(&b, "text"))
+	assert.Equal(t, `Shopping list for:
+  Chips (4 servings)
+  Mash (2 servings)
+
+Produce
+  - 1.25 kg potatoes
+
+Dairy & Eggs
+  - 25 g butter
+
+Oils, Sauces & Condiments
+  - 2 tbsp oil
+
+Spices & Seasonings
+  - Salt
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, l.WriteFormat(&b, "markdown"))
+	assert.Contains(t, b.String(), "# Shopping list\n\n- Chips (4 servings)\n- Mash (2 servings)\n\n## Produce\n\n- [ ] 1.25 kg potatoes\n")
+
+	b.Reset()
+	assert.NoError(t, l.WriteFormat(&b, "csv"))
+	assert.Equal(t, `aisle,ingredient,quantity,quantity_max,unit,meals
+Produce,potatoes,1.25,,kg,Chips; Mash
+Dairy & Eggs,butter,25,,g,Mash
+"Oils, Sauces & Condiments",oil,2,,tbsp,Chips
+Spices & Seasonings,Salt,,,,Chips
+`, b.String())
+
+	assert.Error(t, l.WriteFormat(&b, "pdf"))
+}
+
+func TestAisleOf(t *testing.T) {
+	tests := map[string]string{
+		"Peanut Butter":        "Oils, Sauces & Condiments",
+		"Butter":               "Dairy & Eggs",
+		"Garlic Cloves":        "Produce",
+		"Cloves":               "Spices & Seasonings",
+		"Chicken Stock":        "Oils, Sauces & Condiments",
+		"Chicken Thighs":       "Meat & Fish",
+		"Frozen Peas":          "Frozen",
+		"Bay Leaves":           "Spices & Seasonings",
+		"Digestive Biscuits":   "Baking",
+		"Ground Almonds":       "Baking",
+		"Something Unheard Of": Other,
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, aisleOf(name, "", nil), name)
+	}
+}
+
+func TestLoadAisles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aisles.json")
+	aisles, err := LoadAisles("")
+	assert.NoError(t, err)
+	assert.Empty(t, aisles)
+	_, err = LoadAisles(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"Tahini": "World Foods"}`), 0644))
+	aisles, err = LoadAisles(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Aisles{"Tahini": "World Foods"}, aisles)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`["Tahini"]`), 0644))
+	_, err = LoadAisles(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,370 @@
+package static_site
+
+import (
+	"bytes"
+	"cmp"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/parsing/step_parse"
+)
+
+//go:embed templates
+var files embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+}).ParseFS(files, "templates/*.html"))
+
+// Options configure Build.
+type Options struct {
+	// Dir is where the site is written
+	Dir string
+	// From is the output directory of the crawl. The mirrored image paths of
+	// the meals are relative to it.
+	From string
+	// Catalog, if set, puts every spelling of an ingredient on one page
+	Catalog *ingredient_catalog.Catalog
+	// RemoteImages links the images that were not mirrored to the site they
+	// came from, so the site shows them only when online
+	RemoteImages bool
+	// Title is the name of the cookbook, "Cookbook" when empty
+	Title string
+}
+
+// Result counts what Build wrote.
+type Result struct {
+	Pages  int
+	Images int
+	// MissingImages were not mirrored, and left out or linked remotely
+	MissingImages int
+}
+
+// pages are the directories Build owns in Dir. They are emptied first, so a
+// meal gone from the crawl loses its page.
+var pages = []string{"letter", "country", "ingredient", "meal"}
+
+// group is a page listing meals: a letter, a country or an ingredient.
+type group struct {
+	Key   string
+	Title string
+	File  string // relative to the site root
+	Image string
+	Meals []*meal
+}
+
+type meal struct {
+	detail_parse.MealDetail
+	Name      string
+	File      string
+	Thumbnail string
+	Country   *group
+	Figures   []figure
+	Steps     []step_parse.Step
+}
+
+// figure is one ingredient on a meal page.
+type figure struct {
+	Caption string
+	Image   string
+	Page    *group
+}
+
+type site struct {
+	opts        Options
+	Meals       []*meal
+	Letters     []*group
+	Countries   []*group
+	Ingredients []*group
+	copied      map[string]string // mirrored path or URL -> src in the site, "" when missing
+	result      Result
+}
+
+func (s *site) Title() string {
+	return s.opts.Title
+}
+
+// letter is one link of the letter bar. Page is nil for a letter no meal
+// starts with.
+type letter struct {
+	Letter string
+	Page   *group
+}
+
+// Alphabet returns the letter bar, a to z and "#" when a name starts with
+// something else.
+func (s *site) Alphabet() []letter {
+	var out []letter
+	for c := 'a'; c <= 'z'; c++ {
+		l := letter{Letter: strings.ToUpper(string(c))}
+		if i := slices.IndexFunc(s.Letters, func(g *group) bool { return g.Key == string(c) }); i >= 0 {
+			l.Page = s.Letters[i]
+		}
+		out = append(out, l)
+	}
+	if i := slices.IndexFunc(s.Letters, func(g *group) bool { return g.Key == "other" }); i >= 0 {
+		out = append(out, letter{Letter: "#", Page: s.Letters[i]})
+	}
+	return out
+}
+
+// page is what every template gets. Root leads from the page back to the
+// site root, so links work when the files are opened without a server.
+type page struct {
+	Root  string
+	Title string
+	Site  *site
+	Data  any
+}
+
+// Src is the src of an image from this page: mirrored images are in the
+// site, remote ones are linked as they are.
+func (p page) Src(image string) string {
+	if strings.Contains(image, "://") {
+		return image
+	}
+	return p.Root + image
+}
+
+// With returns the page with other data, for a template used inside
+// another one.
+func (p page) With(data any) page {
+	p.Data = data
+	return p
+}
+
+// Build writes the site: an index of letters and countries, a page per
+// letter like the browse/letter pages of themealdb, a page per country flag
+// and per ingredient, and a page per meal. Images are copied from the
+// mirror in From, see image_mirror, so the site works offline.
+func Build(meals []detail_parse.MealDetail, opts Options) (Result, error) {
+	opts.Title = cmp.Or(opts.Title, "Cookbook")
+	s := &site{opts: opts, copied: map[string]string{}}
+	for _, dir := range pages {
+		if err := os.RemoveAll(filepath.Join(opts.Dir, dir)); err != nil {
+			return Result{}, err
+		}
+	}
+	if err := s.collect(meals); err != nil {
+		return Result{}, err
+	}
+
+	if err := s.render("index.html", "index.html", "", opts.Title, nil); err != nil {
+		return s.result, err
+	}
+	if err := s.render("ingredient/index.html", "ingredients.html", "../", "Ingredients", s.Ingredients); err != nil {
+		return s.result, err
+	}
+	for _, groups := range [][]*group{s.Letters, s.Countries, s.Ingredients} {
+		for _, g := range groups {
+			if err := s.render(g.File, "group.html", "../", g.Title, g); err != nil {
+				return s.result, err
+			}
+		}
+	}
+	for _, m := range s.Meals {
+		if err := s.render(m.File, "meal.html", "../", m.Name, m); err != nil {
+			return s.result, err
+		}
+	}
+	style, err := files.ReadFile("templates/style.css")
+	if err != nil {
+		return s.result, err
+	}
+	return s.result, os.WriteFile(filepath.Join(opts.Dir, "style.css"), style, 0644)
+}
+
+// collect sorts the meals into their letter, country and ingredient pages.
+func (s *site) collect(details []detail_parse.MealDetail) error {
+	letters, countries, ingredients := map[string]*group{}, map[string]*group{}, map[string]*group{}
+	files := map[string]bool{}
+	for _, d := range details {
+		m := &meal{MealDetail: d, Name: d.DisplayName(), Steps: d.Steps}
+		m.File = uniqueFile(files, "meal", cmp.Or(formatID(d.ID), ingredient_catalog.ID(m.Name), "meal"))
+		if len(m.Steps) == 0 {
+			m.Steps = step_parse.Parse(d.Receipt)
+		}
+		var err error
+		if m.Thumbnail, err = s.image(d.ThumbnailPath, d.Thumbnail); err != nil {
+			return err
+		}
+
+		key, title := letterOf(m.Name)
+		add(letters, key, title, "letter", m)
+		if d.Flag != "" {
+			m.Country = add(countries, d.Flag, cmp.Or(d.Area, d.Country()), "country", m)
+			if m.Country.Image == "" {
+				if m.Country.Image, err = s.image(d.FlagPath, d.FlagURL); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, ing := range d.Ingredents {
+			f := figure{Caption: ing.Caption}
+			if f.Image, err = s.image(ing.ImagePath, ing.ImageURL); err != nil {
+				return err
+			}
+			if id, name := s.ingredient(ing); id != "" {
+				f.Page = add(ingredients, id, name, "ingredient", m)
+				f.Page.Image = cmp.Or(f.Page.Image, f.Image)
+			}
+			m.Figures = append(m.Figures, f)
+		}
+		s.Meals = append(s.Meals, m)
+	}
+	s.Letters, s.Countries, s.Ingredients = sorted(letters), sorted(countries), sorted(ingredients)
+	return nil
+}
+
+// add puts m on the page key of groups, creating it, and returns the page.
+// A meal using an ingredient twice is listed once.
+func add(groups map[string]*group, key, title, dir string, m *meal) *group {
+	g := groups[key]
+	if g == nil {
+		g = &group{Key: key, Title: title, File: dir + "/" + key + ".html"}
+		groups[key] = g
+	}
+	if !slices.Contains(g.Meals, m) {
+		g.Meals = append(g.Meals, m)
+	}
+	return g
+}
+
+func sorted(groups map[string]*group) []*group {
+	var out []*group
+	for _, g := range groups {
+		slices.SortStableFunc(g.Meals, func(a, b *meal) int {
+			return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+		out = append(out, g)
+	}
+	slices.SortFunc(out, func(a, b *group) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), cmp.Compare(a.Key, b.Key))
+	})
+	return out
+}
+
+// ingredient returns the page key and title of an ingredient: its catalog
+// entry, else its name as a catalog ID would be.
+func (s *site) ingredient(ing detail_parse.Ingredient) (string, string) {
+	name := ingredient_catalog.IngredientName(ing)
+	if s.opts.Catalog != nil {
+		for _, n := range []string{ing.IngredientID, name} {
+			if e, ok := s.opts.Catalog.Lookup(n); n != "" && ok {
+				return e.ID, e.Name
+			}
+		}
+	}
+	return ingredient_catalog.ID(name), name
+}
+
+// letterOf files a meal under the first letter of its name, like the
+// letter pages the crawl starts from. Names starting with anything else go
+// under "#".
+func letterOf(name string) (string, string) {
+	if name != "" {
+		if c := strings.ToLower(name[:1])[0]; c >= 'a' && c <= 'z' {
+			return string(c), strings.ToUpper(string(c))
+		}
+	}
+	return "other", "#"
+}
+
+// uniqueFile returns dir/name.html, numbered when two meals share a name.
+func uniqueFile(taken map[string]bool, dir, name string) string {
+	file := dir + "/" + name + ".html"
+	for i := 2; taken[file]; i++ {
+		file = fmt.Sprintf("%s/%s-%d.html", dir, name, i)
+	}
+	taken[file] = true
+	return file
+}
+
+func formatID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// image copies a mirrored image into the site and returns its path from
+// the site root. An image that was not mirrored is linked to rawURL with
+// RemoteImages, else left out.
+func (s *site) image(mirrored, rawURL string) (string, error) {
+	key := cmp.Or(mirrored, rawURL)
+	if key == "" {
+		return "", nil
+	}
+	if src, ok := s.copied[key]; ok {
+		return src, nil
+	}
+	src := ""
+	if mirrored != "" {
+		switch err := copyFile(filepath.Join(s.opts.From, mirrored), filepath.Join(s.opts.Dir, mirrored)); {
+		case err == nil:
+			src = path.Clean(filepath.ToSlash(mirrored))
+			s.result.Images++
+		case !os.IsNotExist(err):
+			return "", err
+		}
+	}
+	if src == "" {
+		s.result.MissingImages++
+		if s.opts.RemoteImages {
+			src = rawURL
+		}
+	}
+	s.copied[key] = src
+	return src, nil
+}
+
+func copyFile(from, to string) error {
+	if filepath.Clean(from) == filepath.Clean(to) {
+		_, err := os.Stat(from)
+		return err
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (s *site) render(file, tmpl, root, title string, data any) error {
+	var b bytes.Buffer
+	if err := templates.ExecuteTemplate(&b, tmpl, page{Root: root, Title: title, Site: s, Data: data}); err != nil {
+		return fmt.Errorf("render %s: %w", file, err)
+	}
+	path := filepath.Join(s.opts.Dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	s.result.Pages++
+	return nil
+}
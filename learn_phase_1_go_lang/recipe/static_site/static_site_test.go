@@ -0,0 +1,101 @@
+package static_site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/meal_fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMeals = []detail_parse.MealDetail{
+	{
+		ReceiptName: "Apple Frangipan Tart Recipe",
+		ID:          52768,
+		Flag:        "gb",
+		FlagURL:     "https://www.themealdb.com/images/icons/flags/big/64/gb.png",
+		FlagPath:    "images/flag.png",
+		Receipt:     "Preheat the oven to 200C.\nBake for 20 minutes.",
+		Ingredents: []detail_parse.Ingredient{
+			meal_fixture.ImageIngredient("75g butter", meal_fixture.ImageURL("Butter"), "images/butter.png"),
+			meal_fixture.ImageIngredient("200g Bramley apples <peeled>", meal_fixture.ImageURL("Bramley_Apples"), ""),
+		},
+	},
+	{
+		ReceiptName: "Bakewell tart Recipe",
+		Flag:        "gb",
+		Receipt:     "Make the pastry.",
+		Ingredents:  []detail_parse.Ingredient{meal_fixture.ImageIngredient("100g butter", meal_fixture.ImageURL("Butter"), "images/butter.png")},
+	},
+	{ReceiptName: "7 Layer Dip Recipe", Flag: "us", Receipt: "Layer it."},
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestBuild(t *testing.T) {
+	from := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(from, "images"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(from, "images", "butter.png"), []byte("png"), 0644))
+	dir := filepath.Join(from, "site")
+	// A page of a meal no longer crawled is removed
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "meal"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "meal", "gone.html"), nil, 0644))
+
+	res, err := Build(testMeals, Options{Dir: dir, From: from, Title: "Tarts"})
+	assert.NoError(t, err)
+	// index, ingredient index, 3 letters, 2 countries, 2 ingredients, 3 meals
+	assert.Equal(t, Result{Pages: 12, Images: 1, MissingImages: 2}, res)
+	assert.NoFileExists(t, filepath.Join(dir, "meal", "gone.html"))
+	assert.Equal(t, "png", readFile(t, filepath.Join(dir, "images", "butter.png")))
+	assert.FileExists(t, filepath.Join(dir, "style.css"))
+
+	index := readFile(t, filepath.Join(dir, "index.html"))
+	assert.Contains(t, index, `<title>Tarts</title>`)
+	assert.Contains(t, index, `<a href="letter/a.html">A</a>`)
+	assert.Contains(t, index, `<span>C</span>`)
+	assert.Contains(t, index, `<a href="letter/other.html">#</a>`)
+	assert.Contains(t, index, `<a href="country/gb.html">United Kingdom</a> <small>2</small>`)
+
+	tart := readFile(t, filepath.Join(dir, "meal", "52768.html"))
+	assert.Contains(t, tart, `<title>Apple Frangipan Tart - Tarts</title>`)
+	assert.Contains(t, tart, `<img src="../images/butter.png" alt="" loading="lazy">`)
+	assert.Contains(t, tart, `<a href="../ingredient/butter.html">75g butter</a>`)
+	assert.Contains(t, tart, `200g Bramley apples &lt;peeled&gt;`)
+	assert.Contains(t, tart, "<li>Bake for 20 minutes.</li>")
+	// Nothing is loaded from the network
+	assert.NotContains(t, tart, "themealdb.com")
+
+	butter := readFile(t, filepath.Join(dir, "ingredient", "butter.html"))
+	assert.Contains(t, butter, `<a href="../meal/52768.html">`)
+	assert.Contains(t, butter, `<a href="../meal/bakewell-tart.html">`)
+	assert.FileExists(t, filepath.Join(dir, "meal", "7-layer-dip.html"))
+	assert.FileExists(t, filepath.Join(dir, "country", "us.html"))
+}
+
+func TestBuild_RemoteImages(t *testing.T) {
+	dir := t.TempDir()
+	res, err := Build(testMeals[:1], Options{Dir: dir, From: t.TempDir(), RemoteImages: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res.MissingImages)
+	tart := readFile(t, filepath.Join(dir, "meal", "52768.html"))
+	assert.Contains(t, tart, `<img src="https://www.themealdb.com/images/ingredients/Butter-medium.png"`)
+	assert.Contains(t, tart, `<img class="flag" src="https://www.themealdb.com/images/icons/flags/big/64/gb.png" alt="GB">`)
+	assert.Contains(t, readFile(t, filepath.Join(dir, "index.html")), "<title>Cookbook</title>")
+}
+
+func TestLetterOf(t *testing.T) {
+	key, title := letterOf("apple tart")
+	assert.Equal(t, "a", key)
+	assert.Equal(t, "A", title)
+	key, title = letterOf("Émincé")
+	assert.Equal(t, "other", key)
+	assert.Equal(t, "#", title)
+}
@@ -0,0 +1,269 @@
+package unit_convert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_parse"
+	"go_lang/parsing/step_parse"
+)
+
+// System is the unit system a recipe is converted to.
+type System string
+
+const (
+	Original System = "original" // keep the units from the caption
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+func ParseSystem(s string) (System, error) {
+	switch sys := System(strings.ToLower(s)); sys {
+	case Original, Metric, Imperial:
+		return sys, nil
+	}
+	return "", fmt.Errorf("unknown unit system %q (want original, metric or imperial)", s)
+}
+
+type dimension int
+
+const (
+	mass dimension = iota + 1
+	volume
+)
+
+type unitInfo struct {
+	dim    dimension
+	factor float64 // grams or millilitres per unit
+}
+
+// Volumes use US customary measures, which is what the cup recipes on
+// themealdb.com are written in.
+var knownUnits = map[string]unitInfo{
+	"mg":    {mass, 0.001},
+	"g":     {mass, 1},
+	"kg":    {mass, 1000},
+	"oz":    {mass, 28.349523125},
+	"lb":    {mass, 453.59237},
+	"ml":    {volume, 1},
+	"cl":    {volume, 10},
+	"l":     {volume, 1000},
+	"tsp":   {volume, 4.92892159375},
+	"tbsp":  {volume, 14.78676478125},
+	"fl oz": {volume, 29.5735295625},
+	"cup":   {volume, 236.5882365},
+	"pint":  {volume, 473.176473},
+	"quart": {volume, 946.352946},
+}
+
+// Convert converts q from one unit to another of the same kind, e.g. g to oz
+// or tsp to tbsp.
+func Convert(q float64, from, to string) (float64, error) {
+	f, ok := knownUnits[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	t, ok := knownUnits[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if f.dim != t.dim {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return q * f.factor / t.factor, nil
+}
+
+// Base returns the base unit of a unit with a fixed size, g for masses and
+// ml for volumes, and how many of it make one unit.
+func Base(unit string) (string, float64, bool) {
+	info, ok := knownUnits[unit]
+	switch {
+	case !ok:
+		return "", 0, false
+	case info.dim == mass:
+		return "g", info.factor, true
+	}
+	return "ml", info.factor, true
+}
+
+// ConvertMeasure converts m to the most readable unit of sys. Measures in
+// units without a fixed size (cloves, cans, pinches) are returned as is.
+// Spoons are used in both systems, so they only move between tsp and tbsp.
+func ConvertMeasure(m ingredient_parse.Measure, sys System) ingredient_parse.Measure {
+	info, ok := knownUnits[m.Unit]
+	if !ok || sys == Original {
+		return m
+	}
+	var to string
+	if m.Unit == "tsp" || m.Unit == "tbsp" {
+		to = pickSpoon(m.Quantity * info.factor)
+	} else {
+		to = pickUnit(info.dim, m.Quantity*info.factor, sys)
+	}
+	out := ingredient_parse.Measure{Unit: to}
+	out.Quantity, _ = Convert(m.Quantity, m.Unit, to)
+	if m.QuantityMax != 0 {
+		out.QuantityMax, _ = Convert(m.QuantityMax, m.Unit, to)
+	}
+	return out
+}
+
+// pickUnit chooses a unit for base grams or millilitres.
+func pickUnit(dim dimension, base float64, sys System) string {
+	switch {
+	case dim == mass && sys == Metric:
+		if base >= 1000 {
+			return "kg"
+		}
+		return "g"
+	case dim == mass:
+		if base >= knownUnits["lb"].factor {
+			return "lb"
+		}
+		return "oz"
+	case sys == Metric:
+		if base >= 1000 {
+			return "l"
+		}
+		return "ml"
+	case base < knownUnits["cup"].factor/4:
+		return pickSpoon(base)
+	default:
+		return "cup"
+	}
+}
+
+// pickSpoon turns 3 tsp into 1 tbsp and half a tbsp into 1.5 tsp.
+func pickSpoon(base float64) string {
+	if base < knownUnits["tbsp"].factor {
+		return "tsp"
+	}
+	return "tbsp"
+}
+
+// ConvertIngredient converts the main measure of an ingredient. When the
+// caption already gave the measure in the wanted system, as the "6oz" in
+// "175g/6oz", that one is used instead of a computed value.
+func ConvertIngredient(p ingredient_parse.Parsed, sys System) ingredient_parse.Parsed {
+	if sys == Original {
+		return p
+	}
+	if p.Alternate != nil && systemOf(p.Alternate.Unit) == sys && systemOf(p.Unit) != sys {
+		// Alternate points into the caller's Parsed, it is replaced, not written to
+		alt := p.Measure
+		p.Measure = *p.Alternate
+		p.Alternate = &alt
+		return p
+	}
+	p.Measure = ConvertMeasure(p.Measure, sys)
+	return p
+}
+
+func systemOf(unit string) System {
+	switch unit {
+	case "mg", "g", "kg", "ml", "cl", "l":
+		return Metric
+	case "oz", "lb", "fl oz", "cup", "pint", "quart":
+		return Imperial
+	}
+	return Original
+}
+
+// ConvertTemperatures rewrites the oven temperatures in an instruction text
+// to sys, read the way step_parse reads them and rounded to the nearest 5
+// degrees. Gas marks are left alone, so "200C/180C Fan/Gas 6" becomes
+// "390F/355F Fan/Gas 6" in imperial.
+func ConvertTemperatures(text string, sys System) string {
+	if sys == Original {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, r := range step_parse.Readings(text) {
+		var converted string
+		switch {
+		case r.Unit == "C" && sys == Imperial:
+			converted = fmt.Sprintf("%dF", step_parse.Fahrenheit(r.Degrees))
+		case r.Unit == "F" && sys == Metric:
+			converted = fmt.Sprintf("%dC", step_parse.Celsius(r.Degrees))
+		default:
+			continue
+		}
+		b.WriteString(text[last:r.Start])
+		b.WriteString(converted)
+		last = r.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// ScaleMeal returns a copy of meal with every ingredient quantity multiplied
+// by to/from, e.g. from a recipe for 4 to 6 servings. Captions are rewritten
+// from the scaled fields so they stay in sync.
+func ScaleMeal(meal detail_parse.MealDetail, from, to int) detail_parse.MealDetail {
+	factor := float64(to) / float64(from)
+	scaled := meal
+	scaled.Ingredents = make([]detail_parse.Ingredient, len(meal.Ingredents))
+	for i, ing := range meal.Ingredents {
+		if ing.Quantity != 0 && factor != 1 {
+			ing.Measure = scaleMeasure(ing.Measure, factor)
+			if ing.Alternate != nil {
+				alt := scaleMeasure(*ing.Alternate, factor)
+				ing.Alternate = &alt
+			}
+			ing.Caption = Format(ing.Parsed)
+		}
+		scaled.Ingredents[i] = ing
+	}
+	return scaled
+}
+
+func scaleMeasure(m ingredient_parse.Measure, factor float64) ingredient_parse.Measure {
+	m.Quantity *= factor
+	m.QuantityMax *= factor
+	return m
+}
+
+// Format renders parsed fields back into a caption, e.g. "350 g butter, melted".
+func Format(p ingredient_parse.Parsed) string {
+	var parts []string
+	if m := FormatMeasure(p.Measure); m != "" {
+		parts = append(parts, m)
+	}
+	if p.Alternate != nil {
+		parts = append(parts, "("+FormatMeasure(*p.Alternate)+")")
+	}
+	parts = append(parts, p.Name)
+	caption := strings.Join(parts, " ")
+	if p.Note != "" {
+		caption += ", " + p.Note
+	}
+	return caption
+}
+
+// FormatMeasure renders a measure like "1.5 kg", "2-3 tbsp" or "4".
+func FormatMeasure(m ingredient_parse.Measure) string {
+	q := ""
+	if m.Quantity != 0 {
+		q = FormatQuantity(m.Quantity, m.Unit)
+		if m.QuantityMax != 0 {
+			q += "-" + FormatQuantity(m.QuantityMax, m.Unit)
+		}
+	}
+	return strings.TrimSpace(q + " " + m.Unit)
+}
+
+// FormatQuantity rounds grams and millilitres to whole numbers and
+// everything else to two decimals.
+func FormatQuantity(q float64, unit string) string {
+	switch {
+	case (unit == "g" || unit == "ml") && q >= 10:
+		q = math.Round(q)
+	default:
+		q = math.Round(q*100) / 100
+	}
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
@@ -0,0 +1,88 @@
+package unit_convert
+
+import (
+	"testing"
+
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_parse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	oz, err := Convert(175, "g", "oz")
+	assert.NoError(t, err)
+	assert.InDelta(t, 6.17, oz, 0.01)
+
+	tbsp, err := Convert(3, "tsp", "tbsp")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1, tbsp, 1e-9)
+
+	_, err = Convert(1, "g", "ml")
+	assert.Error(t, err)
+	_, err = Convert(1, "clove", "g")
+	assert.Error(t, err)
+}
+
+func TestConvertIngredient(t *testing.T) {
+	tests := []struct {
+		caption string
+		sys     System
+		want    string
+	}{
+		{"175g/6oz digestive biscuits", Imperial, "6 oz (175 g) digestive biscuits"},
+		{"175g/6oz digestive biscuits", Metric, "175 g (6 oz) digestive biscuits"},
+		{"1 lb Egg Plants", Metric, "454 g Egg Plants"},
+		{"2 cups  Coconut Milk", Metric, "473 ml Coconut Milk"},
+		{"500ml Milk", Imperial, "2.11 cup Milk"},
+		{"1.5kg Chicken", Imperial, "3.31 lb Chicken"},
+		{"6 tsp Sugar", Metric, "2 tbsp Sugar"},
+		{"½ tbsp Olive Oil", Imperial, "1.5 tsp Olive Oil"},
+		{"2 cloves Garlic", Imperial, "2 clove Garlic"},
+		{"2 free-range eggs, beaten", Metric, "2 free-range eggs, beaten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption+"/"+string(tt.sys), func(t *testing.T) {
+			got := ConvertIngredient(ingredient_parse.Parse(tt.caption), tt.sys)
+			assert.Equal(t, tt.want, Format(got))
+		})
+	}
+
+	// The parsed caption is left as it was, it can be converted again
+	parsed := ingredient_parse.Parse("175g/6oz digestive biscuits")
+	for range 2 {
+		assert.Equal(t, "6 oz (175 g) digestive biscuits", Format(ConvertIngredient(parsed, Imperial)))
+	}
+}
+
+func TestConvertTemperatures(t *testing.T) {
+	text := "Preheat the oven to 200C/180C Fan/Gas 6."
+	assert.Equal(t, "Preheat the oven to 390F/355F Fan/Gas 6.", ConvertTemperatures(text, Imperial))
+	assert.Equal(t, text, ConvertTemperatures(text, Metric))
+	assert.Equal(t, "Bake at 175C for 2 Cups", ConvertTemperatures("Bake at 350°F for 2 Cups", Metric))
+	// Read like the steps are, a bare "350 degrees" is °F
+	assert.Equal(t, "Preheat the oven to 175C.", ConvertTemperatures("Preheat the oven to 350 degrees.", Metric))
+	assert.Equal(t, "Heat to 355F/fan 320F.", ConvertTemperatures("Heat to 180 degrees/fan 160C.", Imperial))
+}
+
+func TestScaleMeal(t *testing.T) {
+	meal := detail_parse.MealDetail{
+		ReceiptName: "Apple Frangipan Tart Recipe",
+		Ingredents: []detail_parse.Ingredient{
+			{Caption: "175g/6oz digestive biscuits", Parsed: ingredient_parse.Parse("175g/6oz digestive biscuits")},
+			{Caption: "4 - 6 Chicken Breasts", Parsed: ingredient_parse.Parse("4 - 6 Chicken Breasts")},
+			{Caption: "Pinch Nutmeg", Parsed: ingredient_parse.Parse("Pinch Nutmeg")},
+		},
+	}
+
+	scaled := ScaleMeal(meal, 4, 6)
+
+	assert.Equal(t, "262.5", FormatQuantity(scaled.Ingredents[0].Quantity, ""))
+	assert.Equal(t, 9.0, scaled.Ingredents[0].Alternate.Quantity)
+	assert.Equal(t, "263 g (9 oz) digestive biscuits", scaled.Ingredents[0].Caption)
+	assert.Equal(t, "6-9 Chicken Breasts", scaled.Ingredents[1].Caption)
+	assert.Equal(t, "Pinch Nutmeg", scaled.Ingredents[2].Caption)
+	// the original is left untouched
+	assert.Equal(t, 175.0, meal.Ingredents[0].Quantity)
+	assert.Equal(t, 6.0, meal.Ingredents[0].Alternate.Quantity)
+}
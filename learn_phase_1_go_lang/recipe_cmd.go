@@ -0,0 +1,101 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/recipe/unit_convert"
+	"strings"
+)
+
+// runRecipe prints one meal of a crawl, scaled to a number of
+// servings and converted to a unit system.
+func runRecipe(args []string) error {
+	fs := newFlagSet("recipe", " <meal name>")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l])")
+	servings := fs.Int("servings", 4, "number of servings to print the recipe for")
+	baseServings := fs.Int("base-servings", 4, "number of servings the original recipe makes")
+	units := fs.String("units", string(unit_convert.Original), "unit system: original, metric or imperial")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	name := strings.Join(fs.Args(), " ")
+	if name == "" {
+		fs.Usage()
+		return errUsage
+	}
+	if *servings < 1 || *baseServings < 1 {
+		return usageErrorf("servings must be at least 1")
+	}
+	sys, err := unit_convert.ParseSystem(*units)
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	path := findCrawlFile(*from, "final_items")
+	meals, err := detail_parse.LoadFinalMeals(path)
+	if err != nil {
+		return fmt.Errorf("load meals: %w", err)
+	}
+	meal, ok := detail_parse.FindMeal(meals, name)
+	if !ok {
+		return fmt.Errorf("no meal named %q in %s", name, path)
+	}
+
+	meal = unit_convert.ScaleMeal(meal, *baseServings, *servings)
+	fmt.Printf("%s (%d servings)\n", meal.DisplayName(), *servings)
+	if meal.Flag != "" {
+		fmt.Printf("Flag: %s\n", meal.Flag)
+	}
+	if meal.Category != "" || meal.Area != "" {
+		fmt.Printf("Category: %s, Area: %s\n", cmp.Or(meal.Category, "-"), cmp.Or(meal.Area, "-"))
+	}
+	if len(meal.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(meal.Tags, ", "))
+	}
+	if meal.YouTube != "" {
+		fmt.Printf("Video: %s\n", meal.YouTube)
+	}
+	fmt.Println("\nIngredients:")
+	for _, ing := range meal.Ingredents {
+		fmt.Printf("- %s\n", unit_convert.Format(unit_convert.ConvertIngredient(ing.Parsed, sys)))
+	}
+	fmt.Println("\nInstructions:")
+	if len(meal.Steps) == 0 {
+		fmt.Println(unit_convert.ConvertTemperatures(meal.Receipt, sys))
+		return nil
+	}
+	for _, step := range meal.Steps {
+		text := unit_convert.ConvertTemperatures(step.Text, sys)
+		fmt.Printf("%d. %s\n", step.Number, strings.ReplaceAll(text, "\n", "\n   "))
+		if total := step.Total(); total.MaxSeconds > 0 {
+			fmt.Printf("   Timer: %s\n", formatSeconds(total.MinSeconds, total.MaxSeconds))
+		}
+	}
+	return nil
+}
+
+// formatSeconds prints a time range like "20-25 min" or "1 h 30 min".
+func formatSeconds(min, max int) string {
+	switch {
+	case min == max:
+		return formatTime(min)
+	case max < 3600 && min%60 == 0 && max%60 == 0:
+		return fmt.Sprintf("%d-%d min", min/60, max/60)
+	}
+	return formatTime(min) + " - " + formatTime(max)
+}
+
+func formatTime(seconds int) string {
+	var parts []string
+	for _, unit := range []struct {
+		seconds int
+		name    string
+	}{{3600, "h"}, {60, "min"}, {1, "s"}} {
+		if n := seconds / unit.seconds; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, unit.name))
+			seconds -= n * unit.seconds
+		}
+	}
+	return strings.Join(parts, " ")
+}
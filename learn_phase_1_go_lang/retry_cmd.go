@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/crawl_pool"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/failures"
+	"go_lang/parsing/main_parse"
+	"go_lang/parsing/output_sink"
+	"slices"
+)
+
+// runRetryFailures fetches only the pages listed in failures.json. Meals
+// found on recovered list pages are added to the meal list and crawled
+// too; every other meal is taken from the crawl state of the last run, and
+// the output is written again in full.
+func runRetryFailures(args []string) error {
+	fs := newFlagSet("retry-failures", "")
+	out := addOutputFlags(fs)
+	crawl := addCrawlFlags(fs)
+	images := addImagesFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	manifest, err := openFailures(out.sink.Dir)
+	if err != nil {
+		return err
+	}
+	listFailures := manifest.Failures(failures.List)
+	detailFailures := manifest.Failures(failures.Details)
+	if len(listFailures)+len(detailFailures) == 0 {
+		fmt.Printf("Nothing to retry, %s is empty\n", manifest.Path())
+		return nil
+	}
+	meals, err := main_parse.LoadMeals(mealListPath(out.sink.Dir, out.sink.Format))
+	if err != nil {
+		return fmt.Errorf("load meal list: %w", err)
+	}
+	pool, err := crawl.newPool()
+	if err != nil {
+		return err
+	}
+	ex, err := crawl.extractor()
+	if err != nil {
+		return err
+	}
+	ctx, finish, err := crawl.startRun(pool, out.sink.Dir)
+	if err != nil {
+		return err
+	}
+
+	retry := map[string]bool{}
+	for _, f := range detailFailures {
+		retry[f.URL] = true
+	}
+	var listErr error
+	if len(listFailures) > 0 {
+		collect := &mealCollector{}
+		opts := main_parse.ListOptions{Extractor: ex, Failures: manifest}
+		for _, f := range listFailures {
+			opts.Pages = append(opts.Pages, f.URL)
+		}
+		_, listErr = main_parse.ThumnailPageParse(ctx, pool, collect, opts)
+		var added []main_parse.Meal
+		meals, added = mergeMeals(meals, collect.meals)
+		for _, meal := range added {
+			retry[meal.Href] = true
+		}
+		if err := writeMeals(out.sink, meals); err != nil {
+			return errors.Join(listErr, err, saveFailures(manifest), finish(listErr))
+		}
+		fmt.Printf("Recovered %d meals from %d list pages\n", len(added), len(listFailures))
+	}
+
+	if errors.Is(listErr, crawl_pool.ErrStopped) {
+		return errors.Join(listErr, saveFailures(manifest), finish(listErr))
+	}
+	opts := detail_parse.Options{Dir: out.sink.Dir, Failures: manifest, Retry: retry, Extractor: ex}
+	crawlErr := errors.Join(listErr, crawlDetails(ctx, pool, out.sink, meals, opts, *images))
+	return errors.Join(crawlErr, saveFailures(manifest), finish(crawlErr))
+}
+
+// mealCollector keeps the meals of the retried letter pages in memory, they
+// are merged into the existing list before it is written.
+type mealCollector struct {
+	meals []main_parse.Meal
+}
+
+func (c *mealCollector) WriteMeal(meal main_parse.Meal) error {
+	c.meals = append(c.meals, meal)
+	return nil
+}
+
+func (c *mealCollector) Close() error {
+	return nil
+}
+
+// mergeMeals appends the found meals that aren't in meals yet, leaving the
+// order of the meals already listed as it was.
+func mergeMeals(meals, found []main_parse.Meal) (merged, added []main_parse.Meal) {
+	known := map[string]bool{}
+	for _, meal := range meals {
+		known[meal.Href] = true
+	}
+	merged = slices.Clone(meals)
+	for _, meal := range found {
+		if !known[meal.Href] {
+			known[meal.Href] = true
+			merged = append(merged, meal)
+			added = append(added, meal)
+		}
+	}
+	return merged, added
+}
+
+func writeMeals(cfg output_sink.Config, meals []main_parse.Meal) error {
+	sink, err := output_sink.OpenMeals(cfg)
+	if err != nil {
+		return fmt.Errorf("open output: %w", err)
+	}
+	for _, meal := range meals {
+		if err := sink.WriteMeal(meal); err != nil {
+			sink.Close()
+			return fmt.Errorf("write meal list: %w", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return fmt.Errorf("write meal list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/search_index"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runSearch finds meals of a finished crawl. The index is kept next to the
+// crawl and built again when the crawl changed.
+func runSearch(args []string) error {
+	fs := newFlagSet("search", " [query]")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l]), search_index.gob is kept there too")
+	with := fs.String("with", "", "comma separated ingredients the meals must all have, e.g. \"butter,plain flour\"")
+	flagCode := fs.String("flag", "", "only meals with this flag, e.g. gb")
+	area := fs.String("area", "", "only meals from this area, e.g. British")
+	category := fs.String("category", "", "only meals of this category, e.g. Dessert")
+	limit := fs.Int("limit", 20, "most results to print, 0 for all")
+	reindex := fs.Bool("reindex", false, "build the index again even if the crawl did not change")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var terms []string
+	if q := strings.Join(fs.Args(), " "); q != "" {
+		terms = append(terms, "("+q+")")
+	}
+	for _, ing := range strings.Split(*with, ",") {
+		if ing = strings.TrimSpace(ing); ing != "" {
+			terms = append(terms, "ingredient:"+strconv.Quote(ing))
+		}
+	}
+	for field, value := range map[string]string{"flag": *flagCode, "area": *area, "category": *category} {
+		if value != "" {
+			terms = append(terms, field+":"+strconv.Quote(value))
+		}
+	}
+	if len(terms) == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	query := strings.Join(terms, " ")
+	if _, err := search_index.Parse(query); err != nil {
+		return usageErrorf("%v", err)
+	}
+
+	ix, err := openIndex(*from, *reindex)
+	if err != nil {
+		return err
+	}
+	var catalog *ingredient_catalog.Catalog
+	if c, err := ingredient_catalog.Load(filepath.Join(*from, "ingredients.json")); err == nil {
+		catalog = c
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load ingredient catalog: %w", err)
+	}
+	results, err := ix.Search(query, search_index.Options{Limit: *limit, Catalog: catalog})
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No meals found")
+		return nil
+	}
+	for i, r := range results {
+		var about []string
+		for _, s := range []string{r.Flag, r.Area, r.Category} {
+			if s != "" {
+				about = append(about, s)
+			}
+		}
+		fmt.Printf("%2d. %s (%s) %.2f\n", i+1, r.Name, strings.Join(about, ", "), r.Score)
+		if r.SourceURL != "" {
+			fmt.Printf("    %s\n", r.SourceURL)
+		}
+	}
+	return nil
+}
+
+// openIndex loads the search index of the crawl in dir, or builds and saves
+// it when there is none, it is out of date or rebuild is set.
+func openIndex(dir string, rebuild bool) (*search_index.Index, error) {
+	crawl := findCrawlFile(dir, "final_items")
+	path := filepath.Join(dir, "search_index.gob")
+	if !rebuild {
+		ix, err := search_index.Load(path)
+		if err == nil && !ix.Stale(crawl) {
+			return ix, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("loading search index, building it again", "path", path, "err", err)
+		}
+	}
+	ix, err := search_index.BuildFile(crawl)
+	if err != nil {
+		return nil, fmt.Errorf("load meal details: %w", err)
+	}
+	if err := ix.Save(path); err != nil {
+		return nil, fmt.Errorf("write search index: %w", err)
+	}
+	fmt.Printf("Indexed %d meals (%s)\n", len(ix.Docs), path)
+	return ix, nil
+}
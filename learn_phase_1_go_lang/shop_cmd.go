@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/shopping_list"
+	"go_lang/recipe/unit_convert"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// runShop merges the ingredients of several meals of a finished crawl into
+// one shopping list.
+func runShop(args []string) error {
+	fs := newFlagSet("shop", " <meal name or ID>[@servings] ...")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l])")
+	servings := fs.Int("servings", 4, "servings to cook of a meal that doesn't say, as in \"Tarte Tatin@6\"")
+	baseServings := fs.Int("base-servings", 4, "number of servings the original recipes make")
+	units := fs.String("units", string(unit_convert.Metric), "unit system of summed amounts: metric or imperial")
+	format := fs.String("format", "text", "output format: "+strings.Join(shopping_list.Formats, ", "))
+	aislesPath := fs.String("aisles", "", "JSON file mapping an ingredient to its aisle (default: aisles.json in -from)")
+	out := fs.String("out", "", "file to write the list to instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if *servings < 1 || *baseServings < 1 {
+		return usageErrorf("servings must be at least 1")
+	}
+	sys, err := unit_convert.ParseSystem(*units)
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	if !slices.Contains(shopping_list.Formats, *format) {
+		return usageErrorf("unknown format %q (want %s)", *format, strings.Join(shopping_list.Formats, ", "))
+	}
+	if *aislesPath == "" {
+		// The default file may be missing, one given with -aisles may not
+		if path := filepath.Join(*from, "aisles.json"); !missing(path) {
+			*aislesPath = path
+		}
+	}
+
+	aisles, err := shopping_list.LoadAisles(*aislesPath)
+	if err != nil {
+		return fmt.Errorf("load aisles: %w", err)
+	}
+	var catalog *ingredient_catalog.Catalog
+	if c, err := ingredient_catalog.Load(filepath.Join(*from, "ingredients.json")); err == nil {
+		catalog = c
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load ingredient catalog: %w", err)
+	}
+	path := findCrawlFile(*from, "final_items")
+	meals, err := detail_parse.LoadFinalMeals(path)
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+
+	var recipes []shopping_list.Recipe
+	for _, arg := range fs.Args() {
+		name, n := arg, *servings
+		if i := strings.LastIndex(arg, "@"); i >= 0 {
+			v, err := strconv.Atoi(arg[i+1:])
+			if err != nil || v < 1 {
+				return usageErrorf("bad servings in %q, want a meal like \"Tarte Tatin@6\"", arg)
+			}
+			name, n = arg[:i], v
+		}
+		meal, ok := detail_parse.FindMeal(meals, name)
+		if !ok {
+			return fmt.Errorf("no meal named %q in %s", name, path)
+		}
+		recipes = append(recipes, shopping_list.Recipe{Meal: meal, Servings: n})
+	}
+	list := shopping_list.Build(recipes, shopping_list.Options{
+		BaseServings: *baseServings,
+		Units:        sys,
+		Catalog:      catalog,
+		Aisles:       aisles,
+	})
+
+	if *out == "" {
+		return list.WriteFormat(os.Stdout, *format)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := list.WriteFormat(file, *format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/ingredient_catalog"
+	"go_lang/recipe/static_site"
+	"os"
+	"path/filepath"
+)
+
+// runBuildSite renders a finished crawl into a static HTML cookbook.
+func runBuildSite(args []string) error {
+	fs := newFlagSet("build-site", "")
+	from := fs.String("from", "output", "directory of the crawl (final_items.json[l]) and its mirrored images")
+	out := fs.String("out", "", "directory to write the site to (default: site in -from)")
+	title := fs.String("title", "Cookbook", "name of the cookbook, shown on every page")
+	remoteImages := fs.Bool("remote-images", false, "link images that were not mirrored to their site instead of leaving them out")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = filepath.Join(*from, "site")
+	}
+
+	var catalog *ingredient_catalog.Catalog
+	if c, err := ingredient_catalog.Load(filepath.Join(*from, "ingredients.json")); err == nil {
+		catalog = c
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("load ingredient catalog: %w", err)
+	}
+	meals, err := detail_parse.LoadFinalMeals(findCrawlFile(*from, "final_items"))
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+
+	res, err := static_site.Build(meals, static_site.Options{
+		Dir:          *out,
+		From:         *from,
+		Catalog:      catalog,
+		RemoteImages: *remoteImages,
+		Title:        *title,
+	})
+	if err != nil {
+		return fmt.Errorf("build site: %w", err)
+	}
+	fmt.Printf("Wrote %d pages and %d images of %d meals to %s\n", res.Pages, res.Images, len(meals), filepath.Join(*out, "index.html"))
+	if res.MissingImages > 0 {
+		verb := "left out"
+		if *remoteImages {
+			verb = "linked to their site"
+		}
+		fmt.Printf("%d images were not mirrored and are %s, crawl with -images to keep them\n", res.MissingImages, verb)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"go_lang/parsing/detail_parse"
+	"go_lang/parsing/main_parse"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// runStats prints a short summary of a finished crawl: how many meals made
+// it, where they come from and which ingredients are used most.
+func runStats(args []string) error {
+	fs := newFlagSet("stats", "")
+	from := fs.String("from", "output", "directory of the crawl (items.json[l] and final_items.json[l])")
+	top := fs.Int("top", 10, "number of countries and ingredients to list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	details, err := detail_parse.LoadFinalMeals(findCrawlFile(*from, "final_items"))
+	if err != nil {
+		return fmt.Errorf("load meal details: %w", err)
+	}
+	if meals, err := main_parse.LoadMeals(findCrawlFile(*from, "items")); err == nil {
+		fmt.Printf("%-22s %d\n", "Meals listed:", len(meals))
+	}
+	fmt.Printf("%-22s %d\n", "Meals with details:", len(details))
+
+	ingredients, noIngredients, noInstructions := 0, 0, 0
+	flags := map[string]int{}
+	categories := map[string]int{}
+	names := map[string]int{}
+	for _, meal := range details {
+		ingredients += len(meal.Ingredents)
+		if len(meal.Ingredents) == 0 {
+			noIngredients++
+		}
+		if strings.TrimSpace(meal.Receipt) == "" {
+			noInstructions++
+		}
+		flag := meal.Flag
+		if flag == "" {
+			flag = "(none)"
+		}
+		flags[flag]++
+		if meal.Category != "" {
+			categories[meal.Category]++
+		}
+		seen := map[string]bool{}
+		for _, ing := range meal.Ingredents {
+			name := strings.ToLower(ing.Name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names[name]++
+			}
+		}
+	}
+	fmt.Printf("%-22s %d", "Ingredients:", ingredients)
+	if len(details) > 0 {
+		fmt.Printf(" (%.1f per meal)", float64(ingredients)/float64(len(details)))
+	}
+	fmt.Println()
+	fmt.Printf("%-22s %d\n", "Without ingredients:", noIngredients)
+	fmt.Printf("%-22s %d\n", "Without instructions:", noInstructions)
+
+	fmt.Println("\nMeals per country:")
+	printTop(flags, *top)
+	if len(categories) > 0 {
+		fmt.Println("\nMeals per category:")
+		printTop(categories, *top)
+	}
+	fmt.Println("\nMost used ingredients:")
+	printTop(names, *top)
+	return nil
+}
+
+// printTop prints the n biggest counts, ties in alphabetical order.
+func printTop(counts map[string]int, n int) {
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	for _, key := range keys[:min(n, len(keys))] {
+		fmt.Printf("  %-24s %d\n", key, counts[key])
+	}
+}